@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/config"
+	"github.com/fehepe/flight-price-service/internal/fakeupstream"
+	"github.com/fehepe/flight-price-service/internal/middleware"
+)
+
+func main() {
+	addr := ":" + config.Get("FAKE_UPSTREAMS_PORT", "8080")
+
+	r := fakeupstream.NewRouter()
+	r.Use(middleware.Logging)
+
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      r,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Fake upstreams listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("ListenAndServe error: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	TypeTokenIssued    = "token.issued"
+	TypeTokenRefreshed = "token.refreshed"
+	TypeTokenRevoked   = "token.revoked"
+	TypeRefreshReused  = "token.refresh_reused"
+	TypeLoginFailed    = "login.failed"
+	TypeLoginThrottled = "login.throttled"
+	TypeAdminAction    = "admin.action"
+)
+
+// Outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Event is one audit record. Each event carries the hash of the one before
+// it, so deleting, reordering or editing records breaks the chain. With a
+// key the hashes are HMACs, so the chain cannot be rebuilt without it.
+type Event struct {
+	Seq      uint64            `json:"seq"`
+	Time     time.Time         `json:"time"`
+	Type     string            `json:"type"`
+	Outcome  string            `json:"outcome"`
+	Actor    string            `json:"actor,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"`
+	IP       string            `json:"ip,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+	PrevHash string            `json:"prev_hash"`
+	Hash     string            `json:"hash"`
+}
+
+// computeHash hashes the event without its own hash: HMAC-SHA256 with key,
+// or plain SHA-256 when key is empty.
+func (e Event) computeHash(key []byte) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("marshal audit event: %w", err)
+	}
+	if len(key) == 0 {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Sink stores or forwards audit events. Write is never called concurrently
+// and runs while other events wait, so slow sinks should queue.
+type Sink interface {
+	Write(ctx context.Context, e Event) error
+}
+
+// Resumer is implemented by sinks that keep earlier events, so a restarted
+// Logger continues their chain instead of starting a new one.
+type Resumer interface {
+	// Last returns the newest stored event, or false if there is none.
+	Last() (Event, bool, error)
+}
+
+// Logger numbers, timestamps and chains events before writing them to its
+// sink. A nil *Logger discards events.
+type Logger struct {
+	sink Sink
+	key  []byte
+	now  func() time.Time
+
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+// LoggerOption configures a Logger.
+type LoggerOption func(*Logger)
+
+// WithHMACKey chains events with HMAC-SHA256 under key instead of plain
+// SHA-256, so that only holders of the key can produce a chain that
+// verifies.
+func WithHMACKey(key []byte) LoggerOption {
+	return func(l *Logger) {
+		l.key = key
+	}
+}
+
+// NewLogger writes to sink, continuing its chain when it is a Resumer.
+func NewLogger(sink Sink, opts ...LoggerOption) (*Logger, error) {
+	l := &Logger{sink: sink, now: time.Now}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if r, ok := sink.(Resumer); ok {
+		last, found, err := r.Last()
+		if err != nil {
+			return nil, fmt.Errorf("resume audit chain: %w", err)
+		}
+		if found {
+			l.seq, l.prev = last.Seq, last.Hash
+		}
+	}
+	return l, nil
+}
+
+// Record chains and writes e. Failures are logged rather than returned:
+// auditing must not turn a successful request into an error. The chain
+// advances once the sink commits e, even if secondary sinks failed.
+func (l *Logger) Record(ctx context.Context, e Event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Seq = l.seq + 1
+	e.Time = l.now().UTC()
+	e.PrevHash = l.prev
+	hash, err := e.computeHash(l.key)
+	if err != nil {
+		log.Printf("audit %s: %v", e.Type, err)
+		return
+	}
+	e.Hash = hash
+	if err := l.sink.Write(ctx, e); err != nil {
+		log.Printf("audit %s: write: %v", e.Type, err)
+		if !errors.Is(err, ErrDelivery) {
+			return
+		}
+	}
+	l.seq, l.prev = e.Seq, e.Hash
+}
@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerChainsAndVerifies(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := NewLogger(sink)
+	l.Record(ctx, Event{Type: TypeLoginFailed, Outcome: OutcomeFailure, Actor: "alice", IP: "10.0.0.1"})
+	l.Record(ctx, Event{Type: TypeTokenIssued, Outcome: OutcomeSuccess, Actor: "alice"})
+	sink.Close()
+
+	// A restarted logger continues the chain in the file.
+	sink, _ = NewFileSink(path)
+	l, _ = NewLogger(sink)
+	l.Record(ctx, Event{Type: TypeAdminAction, Outcome: OutcomeSuccess, Actor: "root", Details: map[string]string{"path": "/admin/users"}})
+	sink.Close()
+
+	data, _ := os.ReadFile(path)
+	if n, err := Verify(bytes.NewReader(data), nil, Anchor{}); err != nil || n != 3 {
+		t.Fatalf("expected 3 verified events, got %d, %v", n, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	tampered := strings.Replace(string(data), `"actor":"alice"`, `"actor":"mallory"`, 1)
+	tests := map[string]string{
+		"edited":    tampered,
+		"removed":   lines[0] + "\n" + lines[2] + "\n",
+		"reordered": lines[1] + "\n" + lines[0] + "\n",
+	}
+	for name, log := range tests {
+		if _, err := Verify(strings.NewReader(log), nil, Anchor{}); !errors.Is(err, ErrChainBroken) {
+			t.Errorf("%s: expected ErrChainBroken, got %v", name, err)
+		}
+	}
+
+	rotated := lines[1] + "\n" + lines[2] + "\n"
+	if _, err := Verify(strings.NewReader(rotated), nil, Anchor{}); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("expected a log missing its first events to need an anchor, got %v", err)
+	}
+	var first Event
+	json.Unmarshal([]byte(lines[0]), &first)
+	if n, err := Verify(strings.NewReader(rotated), nil, Anchor{Seq: first.Seq, Hash: first.Hash}); err != nil || n != 2 {
+		t.Errorf("expected a rotated log to verify from its anchor, got %d, %v", n, err)
+	}
+}
+
+func TestKeyedChain(t *testing.T) {
+	key := []byte("audit-key")
+	var out bytes.Buffer
+	l, _ := NewLogger(NewWriterSink(&out), WithHMACKey(key))
+	l.Record(context.Background(), Event{Type: TypeLoginFailed, Outcome: OutcomeFailure, Actor: "alice"})
+	l.Record(context.Background(), Event{Type: TypeTokenIssued, Outcome: OutcomeSuccess, Actor: "alice"})
+	log := out.String()
+
+	if n, err := Verify(strings.NewReader(log), key, Anchor{}); err != nil || n != 2 {
+		t.Fatalf("expected 2 verified events, got %d, %v", n, err)
+	}
+	for name, wrong := range map[string][]byte{"no key": nil, "wrong key": []byte("other-key")} {
+		if _, err := Verify(strings.NewReader(log), wrong, Anchor{}); !errors.Is(err, ErrChainBroken) {
+			t.Errorf("%s: expected ErrChainBroken, got %v", name, err)
+		}
+	}
+
+	// Rewriting an event and recomputing the chain without the key does not verify.
+	var forged bytes.Buffer
+	forger, _ := NewLogger(NewWriterSink(&forged))
+	forger.Record(context.Background(), Event{Type: TypeLoginFailed, Outcome: OutcomeFailure, Actor: "mallory"})
+	if _, err := Verify(&forged, key, Anchor{}); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("expected a chain forged without the key to be refused, got %v", err)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		json.NewDecoder(r.Body).Decode(&e)
+		got = append(got, e)
+		if e.Actor == "reject" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	// A failed post is logged; the event stays in the chain.
+	sink := NewWebhookSink(srv.URL, nil)
+	l, _ := NewLogger(sink)
+	l.Record(context.Background(), Event{Type: TypeTokenRevoked, Actor: "reject"})
+	l.Record(context.Background(), Event{Type: TypeTokenIssued, Actor: "alice"})
+	sink.Close()
+
+	if len(got) != 2 || got[0].Actor != "reject" || got[1].Seq != 2 || got[1].PrevHash != got[0].Hash {
+		t.Errorf("unexpected deliveries %+v", got)
+	}
+}
+
+func TestMultiSink_SecondaryFailureKeepsChain(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	file, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := &failingSink{}
+	webhook := NewWebhookSink(srv.URL, nil)
+	l, _ := NewLogger(MultiSink{webhook, failing, file})
+
+	// The webhook endpoint hangs, yet recording does not wait for it.
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			l.Record(context.Background(), Event{Type: TypeAdminAction, Outcome: OutcomeSuccess})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("recording waited for the webhook")
+	}
+	close(release)
+	webhook.Close()
+	file.Close()
+
+	data, _ := os.ReadFile(path)
+	if n, err := Verify(bytes.NewReader(data), nil, Anchor{}); err != nil || n != 3 {
+		t.Errorf("expected 3 chained events despite failing secondaries, got %d, %v", n, err)
+	}
+	if failing.calls != 3 {
+		t.Errorf("expected every event offered to the failing sink, got %d", failing.calls)
+	}
+
+	// Nothing is committed when the primary sink fails.
+	var out bytes.Buffer
+	l, _ = NewLogger(MultiSink{failingResumer{}, NewWriterSink(&out)})
+	l.Record(context.Background(), Event{Type: TypeAdminAction})
+	if out.Len() != 0 {
+		t.Errorf("expected no secondary write after a primary failure, got %s", out.String())
+	}
+}
+
+type failingSink struct{ calls int }
+
+func (s *failingSink) Write(ctx context.Context, e Event) error {
+	s.calls++
+	return errors.New("sink down")
+}
+
+type failingResumer struct{}
+
+func (failingResumer) Write(ctx context.Context, e Event) error { return errors.New("disk full") }
+func (failingResumer) Last() (Event, bool, error)               { return Event{}, false, nil }
+
+func TestParseSinks(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := ParseSinks("stdout, file:" + filepath.Join(dir, "audit.log") + ",webhook:http://localhost:9/audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := sink.(MultiSink); !ok || len(m) != 3 {
+		t.Errorf("expected three sinks, got %#v", sink)
+	}
+	for _, bad := range []string{"syslog", "file:", "webhook"} {
+		if _, err := ParseSinks(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// WriterSink writes events as JSON lines, for example to stdout.
+type WriterSink struct {
+	w io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(ctx context.Context, e Event) error {
+	return json.NewEncoder(s.w).Encode(e)
+}
+
+// FileSink appends events as JSON lines to a file and resumes its chain.
+type FileSink struct {
+	path string
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &FileSink{path: path, file: f}, nil
+}
+
+func (s *FileSink) Write(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// Last returns the final event in the file.
+func (s *FileSink) Last() (Event, bool, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return Event{}, false, err
+	}
+	defer f.Close()
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err := scanner.Err(); err != nil || last == nil {
+		return Event{}, false, err
+	}
+	var e Event
+	if err := json.Unmarshal(last, &e); err != nil {
+		return Event{}, false, fmt.Errorf("parse last audit event: %w", err)
+	}
+	return e, true, nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// ErrDelivery means an event was committed to the primary sink but some
+// other sink failed to take it. The event is still part of the chain.
+var ErrDelivery = errors.New("audit delivery failed")
+
+// webhookQueue bounds the events waiting to be posted.
+const webhookQueue = 1024
+
+// WebhookSink posts each event as JSON to a URL. Events are queued and
+// posted in order by a background goroutine, so a slow endpoint never holds
+// up the requests being audited; failed posts are logged.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan Event
+	done   chan struct{}
+}
+
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	s := &WebhookSink{url: url, client: client, queue: make(chan Event, webhookQueue), done: make(chan struct{})}
+	go s.run()
+	return s
+}
+
+// Write queues e for delivery. It fails only when the queue is full.
+func (s *WebhookSink) Write(ctx context.Context, e Event) error {
+	select {
+	case s.queue <- e:
+		return nil
+	default:
+		return fmt.Errorf("webhook %s: queue full, event %d dropped", s.url, e.Seq)
+	}
+}
+
+// Close posts the queued events and stops the sink.
+func (s *WebhookSink) Close() error {
+	close(s.queue)
+	<-s.done
+	return nil
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	for e := range s.queue {
+		if err := s.post(e); err != nil {
+			log.Printf("audit %s: event %d: %v", e.Type, e.Seq, err)
+		}
+	}
+}
+
+func (s *WebhookSink) post(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post audit event: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("post audit event: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MultiSink writes every event to each of its sinks. The primary sink, the
+// first Resumer or else the first sink, is written first and decides
+// whether the event is committed: when it fails the others are not
+// written, and when only others fail the error wraps ErrDelivery.
+type MultiSink []Sink
+
+func (m MultiSink) Write(ctx context.Context, e Event) error {
+	if len(m) == 0 {
+		return nil
+	}
+	primary := m.primary()
+	if err := m[primary].Write(ctx, e); err != nil {
+		return err
+	}
+	var errs []error
+	for i, s := range m {
+		if i == primary {
+			continue
+		}
+		if err := s.Write(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrDelivery, errors.Join(errs...))
+	}
+	return nil
+}
+
+func (m MultiSink) Last() (Event, bool, error) {
+	if len(m) == 0 {
+		return Event{}, false, nil
+	}
+	if r, ok := m[m.primary()].(Resumer); ok {
+		return r.Last()
+	}
+	return Event{}, false, nil
+}
+
+func (m MultiSink) primary() int {
+	for i, s := range m {
+		if _, ok := s.(Resumer); ok {
+			return i
+		}
+	}
+	return 0
+}
+
+// ParseSinks builds the sinks in a comma-separated spec of "stdout",
+// "file:<path>" and "webhook:<url>" entries.
+func ParseSinks(spec string) (Sink, error) {
+	var sinks MultiSink
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		kind, arg, _ := strings.Cut(entry, ":")
+		switch {
+		case entry == "":
+			continue
+		case entry == "stdout":
+			sinks = append(sinks, NewWriterSink(os.Stdout))
+		case kind == "file" && arg != "":
+			f, err := NewFileSink(arg)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, f)
+		case kind == "webhook" && arg != "":
+			sinks = append(sinks, NewWebhookSink(arg, nil))
+		default:
+			return nil, fmt.Errorf("invalid audit sink %q, want stdout, file:<path> or webhook:<url>", entry)
+		}
+	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return sinks, nil
+}
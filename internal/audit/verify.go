@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrChainBroken means an audit log was edited, reordered or truncated.
+var ErrChainBroken = errors.New("audit chain broken")
+
+// Anchor is the last event before a log that does not start the chain, as
+// after old events are rotated away. It must come from a trusted record,
+// such as the end of the previous file once that was verified. The zero
+// Anchor means the log starts the chain.
+type Anchor struct {
+	Seq  uint64
+	Hash string
+}
+
+// Verify checks the hash chain of JSON-lines events read from r and returns
+// how many events it covers. key must be the key the Logger used, or empty
+// for an unkeyed chain. The first event must follow from: with the zero
+// Anchor that is event 1, so dropping events from the start is detected.
+//
+// A verified keyed log proves that every event was written by a holder of
+// key and that none was edited, removed, reordered or inserted between from
+// and the last event. An unkeyed chain only detects accidental edits, as
+// anyone can recompute it. Neither detects events removed from the end:
+// compare the last event with a copy kept elsewhere, such as a webhook sink.
+func Verify(r io.Reader, key []byte, from Anchor) (int, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	n := 0
+	prev := Event{Seq: from.Seq, Hash: from.Hash}
+	for {
+		var e Event
+		if err := dec.Decode(&e); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("parse audit event %d: %w", n+1, err)
+		}
+		hash, err := e.computeHash(key)
+		if err != nil {
+			return n, err
+		}
+		if !hmac.Equal([]byte(hash), []byte(e.Hash)) {
+			return n, fmt.Errorf("%w: event %d has been modified", ErrChainBroken, e.Seq)
+		}
+		if e.PrevHash != prev.Hash || e.Seq != prev.Seq+1 {
+			return n, fmt.Errorf("%w: event %d does not follow event %d", ErrChainBroken, e.Seq, prev.Seq)
+		}
+		prev = e
+		n++
+	}
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognise in
+// logs and by secret scanners.
+const APIKeyPrefix = "fps_"
+
+const (
+	apiKeyIDLength = 12
+	// defaultTouchInterval limits how often last-used times are written.
+	defaultTouchInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+// APIKey is a stored API key. Only a hash of the secret is kept; the key
+// itself is shown once, when it is created.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Owner      string    `json:"owner"`
+	Hash       string    `json:"hash"`
+	Scopes     []string  `json:"scopes"`
+	Tenant     string    `json:"tenant,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key can be used at now.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt.IsZero() && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// Prefix returns the public part of the key, which identifies it in lists
+// and logs.
+func (k APIKey) Prefix() string {
+	return APIKeyPrefix + k.ID
+}
+
+// APIKeyStore persists API keys. Implementations must be safe for
+// concurrent use.
+type APIKeyStore interface {
+	CreateKey(ctx context.Context, key APIKey) error
+	// GetKey fails with ErrAPIKeyNotFound for unknown IDs.
+	GetKey(ctx context.Context, id string) (APIKey, error)
+	ListKeys(ctx context.Context) ([]APIKey, error)
+	RevokeKey(ctx context.Context, id string, at time.Time) error
+	TouchKey(ctx context.Context, id string, at time.Time) error
+}
+
+// APIKeys issues and verifies API keys of the form fps_<id>_<secret>.
+type APIKeys struct {
+	store         APIKeyStore
+	owners        UserStore
+	now           func() time.Time
+	touchInterval time.Duration
+
+	mu        sync.Mutex
+	lastTouch map[string]time.Time
+}
+
+// APIKeysOption configures APIKeys.
+type APIKeysOption func(*APIKeys)
+
+// WithOwners makes every use of a key look up its owner in users: the key
+// only works while the owner exists, is enabled, stays in the key's tenant
+// and still holds every scope of the key. Without it keys are not tied to
+// their owner.
+func WithOwners(users UserStore) APIKeysOption {
+	return func(a *APIKeys) {
+		a.owners = users
+	}
+}
+
+func NewAPIKeys(store APIKeyStore, opts ...APIKeysOption) *APIKeys {
+	a := &APIKeys{store: store, now: time.Now, touchInterval: defaultTouchInterval, lastTouch: make(map[string]time.Time)}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Create stores a new key for tenant and returns it with its record. A zero
+// ttl means the key does not expire.
+func (a *APIKeys) Create(ctx context.Context, name, owner, tenant string, scopes []string, ttl time.Duration) (string, APIKey, error) {
+	idBytes := make([]byte, apiKeyIDLength/2)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", APIKey{}, fmt.Errorf("generate api key id: %w", err)
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	id := hex.EncodeToString(idBytes)
+	raw := APIKeyPrefix + id + "_" + secret
+
+	now := a.now().UTC()
+	key := APIKey{
+		ID:        id,
+		Name:      name,
+		Owner:     owner,
+		Hash:      hashToken(raw),
+		Scopes:    scopes,
+		Tenant:    tenant,
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		key.ExpiresAt = now.Add(ttl)
+	}
+	if err := a.store.CreateKey(ctx, key); err != nil {
+		return "", APIKey{}, err
+	}
+	return raw, key, nil
+}
+
+// Get returns the key with the given ID, failing with ErrAPIKeyNotFound.
+func (a *APIKeys) Get(ctx context.Context, id string) (APIKey, error) {
+	return a.store.GetKey(ctx, id)
+}
+
+// List returns every key, including revoked and expired ones.
+func (a *APIKeys) List(ctx context.Context) ([]APIKey, error) {
+	return a.store.ListKeys(ctx)
+}
+
+// Revoke disables a key immediately.
+func (a *APIKeys) Revoke(ctx context.Context, id string) error {
+	return a.store.RevokeKey(ctx, id, a.now().UTC())
+}
+
+// Verify checks a raw key and returns claims naming the key as subject and
+// carrying its scopes and tenant. It satisfies middleware.TokenVerifier.
+func (a *APIKeys) Verify(ctx context.Context, raw string) (*Claims, error) {
+	id, ok := parseAPIKeyID(raw)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := a.store.GetKey(ctx, id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	} else if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashToken(raw))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := a.now()
+	if !key.Active(now) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err := a.checkOwner(ctx, key); err != nil {
+		return nil, err
+	}
+	a.touch(ctx, id, now)
+
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: apiKeySubjectPrefix + key.ID},
+		Scopes:           key.Scopes,
+		Tenant:           key.Tenant,
+		Method:           AuthAPIKey,
+	}
+	if !key.ExpiresAt.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(key.ExpiresAt)
+	}
+	return claims, nil
+}
+
+// checkOwner refuses a key whose owner could no longer create it: deleted,
+// disabled, moved to another tenant or without one of the key's scopes.
+func (a *APIKeys) checkOwner(ctx context.Context, key APIKey) error {
+	if a.owners == nil {
+		return nil
+	}
+	owner, err := a.owners.Get(ctx, key.Owner)
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrInvalidAPIKey
+	} else if err != nil {
+		return fmt.Errorf("api key %s: look up owner: %w", key.ID, err)
+	}
+	if owner.Disabled || (owner.Tenant != "" && owner.Tenant != key.Tenant) {
+		return ErrInvalidAPIKey
+	}
+	held := ScopesForRoles(owner.Roles)
+	for _, scope := range key.Scopes {
+		if !slices.Contains(held, scope) {
+			return ErrInvalidAPIKey
+		}
+	}
+	return nil
+}
+
+// apiKeySubjectPrefix starts the subject of claims verified from API keys.
+// It only names the key; IsAPIKey relies on the claims' Method.
+const apiKeySubjectPrefix = "apikey:"
+
+// ReservedUsername reports whether username could be mistaken for the
+// subject of an API key, so it must not be given to a user.
+func ReservedUsername(username string) bool {
+	return strings.HasPrefix(username, apiKeySubjectPrefix)
+}
+
+// IsAPIKey reports whether the claims were verified from an API key.
+func (c *Claims) IsAPIKey() bool {
+	return c.Method == AuthAPIKey
+}
+
+// touch records that a key was used, at most once per touch interval per
+// key, so busy clients do not turn every request into a write. Failures are
+// logged, not returned: last-used times are informational.
+func (a *APIKeys) touch(ctx context.Context, id string, now time.Time) {
+	a.mu.Lock()
+	if now.Sub(a.lastTouch[id]) < a.touchInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastTouch[id] = now
+	a.mu.Unlock()
+
+	if err := a.store.TouchKey(ctx, id, now.UTC()); err != nil {
+		log.Printf("api key %s: record last use: %v", id, err)
+	}
+}
+
+// parseAPIKeyID returns the ID part of fps_<id>_<secret>.
+func parseAPIKeyID(raw string) (string, bool) {
+	rest, ok := strings.CutPrefix(raw, APIKeyPrefix)
+	if !ok || len(rest) < apiKeyIDLength+2 || rest[apiKeyIDLength] != '_' {
+		return "", false
+	}
+	id := rest[:apiKeyIDLength]
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func apiKeyStores(t *testing.T) map[string]APIKeyStore {
+	t.Helper()
+	ctx := context.Background()
+	file, err := OpenAPIKeyStore(ctx, "file:"+filepath.Join(t.TempDir(), "api-keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]APIKeyStore{"file": file}
+	if sqlite, err := OpenAPIKeyStore(ctx, "sqlite:"+filepath.Join(t.TempDir(), "auth.db")); err == nil {
+		t.Cleanup(func() { sqlite.(*SQLStore).Close() })
+		stores["sqlite"] = sqlite
+	}
+	return stores
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range apiKeyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+			keys := NewAPIKeys(store)
+			keys.now = func() time.Time { return now }
+
+			raw, key, err := keys.Create(ctx, "nightly job", "alice", "acme", []string{"flights:search"}, 24*time.Hour)
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if !strings.HasPrefix(raw, key.Prefix()+"_") || strings.Contains(key.Hash, raw) {
+				t.Fatalf("unexpected key %q for %+v", raw, key)
+			}
+
+			claims, err := keys.Verify(ctx, raw)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if claims.Subject != "apikey:"+key.ID || !reflect.DeepEqual(claims.Scopes, []string{"flights:search"}) || claims.Tenant != "acme" {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+			if !claims.IsAPIKey() {
+				t.Error("expected verified claims to be marked as an API key")
+			}
+			stored, _ := store.GetKey(ctx, key.ID)
+			if !stored.LastUsedAt.Equal(now) {
+				t.Errorf("expected last use to be recorded, got %v", stored.LastUsedAt)
+			}
+
+			for name, bad := range map[string]string{
+				"wrong secret": raw[:len(raw)-4] + "AAAA",
+				"unknown id":   APIKeyPrefix + "000000000000_" + raw[len(key.Prefix())+1:],
+				"malformed":    "fps_short",
+				"jwt":          "eyJhbGciOi.x.y",
+			} {
+				if _, err := keys.Verify(ctx, bad); !errors.Is(err, ErrInvalidAPIKey) {
+					t.Errorf("%s: expected ErrInvalidAPIKey, got %v", name, err)
+				}
+			}
+
+			now = now.Add(25 * time.Hour)
+			if _, err := keys.Verify(ctx, raw); !errors.Is(err, ErrInvalidAPIKey) {
+				t.Errorf("expected an expired key to be refused, got %v", err)
+			}
+
+			forever, key2, _ := keys.Create(ctx, "service", "alice", "", []string{"flights:search"}, 0)
+			if err := keys.Revoke(ctx, key2.ID); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+			if _, err := keys.Verify(ctx, forever); !errors.Is(err, ErrInvalidAPIKey) {
+				t.Errorf("expected a revoked key to be refused, got %v", err)
+			}
+			if err := keys.Revoke(ctx, "000000000000"); !errors.Is(err, ErrAPIKeyNotFound) {
+				t.Errorf("expected ErrAPIKeyNotFound, got %v", err)
+			}
+
+			list, err := keys.List(ctx)
+			if err != nil || len(list) != 2 || list[0].ID != key.ID || list[1].RevokedAt.IsZero() {
+				t.Errorf("unexpected list %+v, %v", list, err)
+			}
+		})
+	}
+}
+
+func TestAPIKeysThrottleLastUsed(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewFileKeyStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	keys := NewAPIKeys(store)
+	keys.now = func() time.Time { return now }
+	raw, key, _ := keys.Create(ctx, "job", "alice", "", []string{"flights:search"}, 0)
+
+	keys.Verify(ctx, raw)
+	first := now
+	now = now.Add(10 * time.Second)
+	keys.Verify(ctx, raw)
+	if stored, _ := store.GetKey(ctx, key.ID); !stored.LastUsedAt.Equal(first) {
+		t.Errorf("expected the second use within a minute not to be written, got %v", stored.LastUsedAt)
+	}
+	now = now.Add(time.Minute)
+	keys.Verify(ctx, raw)
+	if stored, _ := store.GetKey(ctx, key.ID); !stored.LastUsedAt.Equal(now) {
+		t.Errorf("expected a later use to be written, got %v", stored.LastUsedAt)
+	}
+}
+
+func TestIsAPIKeyIgnoresSubject(t *testing.T) {
+	claims := &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "apikey:0123abcd4567"}}
+	if claims.IsAPIKey() {
+		t.Error("expected a subject shaped like a key not to make the claims an API key")
+	}
+	if !ReservedUsername(claims.Subject) || ReservedUsername("alice") {
+		t.Error("expected only usernames shaped like a key subject to be reserved")
+	}
+}
+
+func TestAPIKeysFollowOwner(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewFileKeyStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	users, _ := NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+	users.Create(ctx, User{Username: "alice", Roles: []string{RoleOperator}, Tenant: "acme"})
+	keys := NewAPIKeys(store, WithOwners(users))
+	raw, _, _ := keys.Create(ctx, "job", "alice", "acme", []string{ScopeCacheRead}, 0)
+	orphan, _, _ := keys.Create(ctx, "job", "", "", []string{ScopeFlightsSearch}, 0)
+
+	tests := []struct {
+		name   string
+		change func(*User)
+		wantOK bool
+	}{
+		{"active owner", func(*User) {}, true},
+		{"disabled owner", func(u *User) { u.Disabled = true }, false},
+		{"re-enabled owner", func(u *User) { u.Disabled = false }, true},
+		{"owner lost the scope", func(u *User) { u.Roles = []string{RoleUser} }, false},
+		{"owner regained the scope", func(u *User) { u.Roles = []string{RoleAdmin} }, true},
+		{"owner moved tenant", func(u *User) { u.Tenant = "globex" }, false},
+	}
+	for _, tt := range tests {
+		user, _ := users.Get(ctx, "alice")
+		tt.change(&user)
+		if err := users.Update(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		_, err := keys.Verify(ctx, raw)
+		if tt.wantOK && err != nil {
+			t.Errorf("%s: expected the key to work, got %v", tt.name, err)
+		} else if !tt.wantOK && !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("%s: expected ErrInvalidAPIKey, got %v", tt.name, err)
+		}
+	}
+
+	users.Delete(ctx, "alice")
+	if _, err := keys.Verify(ctx, raw); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("expected a deleted owner's key to be refused, got %v", err)
+	}
+	if _, err := keys.Verify(ctx, orphan); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("expected a key without an owner to be refused, got %v", err)
+	}
+}
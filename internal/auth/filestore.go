@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStore keeps users in a JSON file. Every change rewrites the file
+// atomically, which suits the few dozen accounts of internal consumers but
+// not frequent writes from several replicas; use SQLStore for that.
+type FileStore struct {
+	path  string
+	users map[string]User
+	mu    sync.RWMutex
+}
+
+// NewFileStore loads users from path. A missing file is treated as empty and
+// created on the first write.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, users: make(map[string]User)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read user file: %w", err)
+	}
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("parse user file %s: %w", path, err)
+	}
+	for _, u := range users {
+		s.users[u.Username] = u
+	}
+	return s, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, username string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (s *FileStore) List(ctx context.Context) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sorted(), nil
+}
+
+func (s *FileStore) Create(ctx context.Context, user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.Username]; ok {
+		return ErrUserExists
+	}
+	return s.commit(user.Username, &user)
+}
+
+func (s *FileStore) Update(ctx context.Context, user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.Username]; !ok {
+		return ErrUserNotFound
+	}
+	return s.commit(user.Username, &user)
+}
+
+func (s *FileStore) RecordLoginFailure(ctx context.Context, username string, maxFailures int, lockUntil, at time.Time) error {
+	return s.modify(username, func(u *User) {
+		u.FailedLogins++
+		if maxFailures > 0 && u.FailedLogins >= maxFailures {
+			u.FailedLogins = 0
+			u.LockedUntil = lockUntil
+		}
+		u.UpdatedAt = at
+	})
+}
+
+func (s *FileStore) RecordLogin(ctx context.Context, username string, at time.Time) error {
+	return s.modify(username, func(u *User) {
+		u.FailedLogins = 0
+		u.LockedUntil = time.Time{}
+		u.LastLoginAt = at
+		u.UpdatedAt = at
+	})
+}
+
+// modify applies fn to the stored user under the lock, so concurrent
+// changes to other fields are not lost.
+func (s *FileStore) modify(username string, fn func(*User)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	fn(&u)
+	return s.commit(username, &u)
+}
+
+func (s *FileStore) Delete(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; !ok {
+		return ErrUserNotFound
+	}
+	return s.commit(username, nil)
+}
+
+// commit applies a change, deleting the user when it is nil, and writes the
+// file. The in-memory map is only changed once the write succeeded.
+func (s *FileStore) commit(username string, user *User) error {
+	prev, existed := s.users[username]
+	if user == nil {
+		delete(s.users, username)
+	} else {
+		s.users[username] = *user
+	}
+	if err := s.write(); err != nil {
+		if existed {
+			s.users[username] = prev
+		} else {
+			delete(s.users, username)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) write() error {
+	if err := writeJSONFile(s.path, s.sorted()); err != nil {
+		return fmt.Errorf("write user file: %w", err)
+	}
+	return nil
+}
+
+// writeJSONFile replaces path with v as indented JSON, readable only by the
+// owner. It writes a temporary file and renames it, so readers never see a
+// partial file.
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) sorted() []User {
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
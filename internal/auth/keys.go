@@ -0,0 +1,401 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// Key is a JWT key identified by ID, the kid header of tokens it signs.
+// Asymmetric keys built from a public key can only verify.
+type Key struct {
+	ID        string
+	Algorithm string
+	private   any
+	public    any
+}
+
+// NewHMACKey returns an HS256 key for a shared secret. Its ID is derived
+// from the secret so that it stays stable across restarts.
+func NewHMACKey(secret []byte) Key {
+	sum := sha256.Sum256(secret)
+	return Key{ID: "hs-" + base64.RawURLEncoding.EncodeToString(sum[:8]), Algorithm: AlgHS256, private: secret, public: secret}
+}
+
+// NewPrivateKey returns a signing key for an RSA, P-256 ECDSA or Ed25519
+// private key. An empty id is replaced by a thumbprint of the public key.
+func NewPrivateKey(id string, priv crypto.Signer) (Key, error) {
+	k, err := NewPublicKey(id, priv.Public())
+	if err != nil {
+		return Key{}, err
+	}
+	k.private = priv
+	return k, nil
+}
+
+// NewPublicKey returns a verification key for an RSA, P-256 ECDSA or Ed25519
+// public key. An empty id is replaced by a thumbprint of the key.
+func NewPublicKey(id string, pub crypto.PublicKey) (Key, error) {
+	var alg string
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		alg = AlgRS256
+	case *ecdsa.PublicKey:
+		if p.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("unsupported ECDSA curve %s, want P-256", p.Curve.Params().Name)
+		}
+		alg = AlgES256
+	case ed25519.PublicKey:
+		alg = AlgEdDSA
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", pub)
+	}
+	if id == "" {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return Key{}, fmt.Errorf("marshal public key: %w", err)
+		}
+		sum := sha256.Sum256(der)
+		id = base64.RawURLEncoding.EncodeToString(sum[:12])
+	}
+	return Key{ID: id, Algorithm: alg, public: pub}, nil
+}
+
+// ParseKeyPEM parses a PEM private key (PKCS#8, PKCS#1 or SEC 1) or public
+// key (PKIX).
+func ParseKeyPEM(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("parse public key: %w", err)
+		}
+		return NewPublicKey(id, pub)
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("parse RSA private key: %w", err)
+		}
+		return NewPrivateKey(id, priv)
+	case "EC PRIVATE KEY":
+		priv, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("parse EC private key: %w", err)
+		}
+		return NewPrivateKey(id, priv)
+	case "PRIVATE KEY":
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("parse private key: %w", err)
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return Key{}, fmt.Errorf("unsupported private key type %T", priv)
+		}
+		return NewPrivateKey(id, signer)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// CanSign reports whether the key holds private material.
+func (k Key) CanSign() bool {
+	return k.private != nil
+}
+
+func (k Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// KeySet signs tokens with one key and verifies them with any of its keys,
+// chosen by the kid header. Keeping retired keys in the set lets tokens
+// they signed stay valid until they expire.
+type KeySet struct {
+	now func() time.Time
+
+	mu      sync.RWMutex
+	signing Key
+	keys    map[string]Key
+	// legacy verifies tokens without a kid, which were signed by the shared
+	// secret before keys had IDs.
+	legacy *Key
+	// hmac is the ID of the key for the current shared secret, if any.
+	hmac string
+	// retired holds when keys replaced by RotateHMAC stop verifying.
+	retired map[string]time.Time
+}
+
+// NewKeySet returns a set that signs with signing and also accepts verify.
+func NewKeySet(signing Key, verify ...Key) (*KeySet, error) {
+	if !signing.CanSign() {
+		return nil, fmt.Errorf("key %s has no private key to sign with", signing.ID)
+	}
+	s := &KeySet{now: time.Now, signing: signing, keys: make(map[string]Key), retired: make(map[string]time.Time)}
+	for _, k := range append([]Key{signing}, verify...) {
+		if _, dup := s.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		s.keys[k.ID] = k
+		if k.Algorithm == AlgHS256 && s.legacy == nil {
+			s.legacy = &k
+			s.hmac = k.ID
+		}
+	}
+	return s, nil
+}
+
+// RotateHMAC replaces the key of the shared secret with one for secret. The
+// new key signs if the old one did and is otherwise accepted for
+// verification only. The old key keeps verifying for grace, the lifetime
+// of the tokens it signed. An empty secret retires the shared secret,
+// unless it is the signing key.
+func (s *KeySet) RotateHMAC(secret []byte, grace time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for id, until := range s.retired {
+		if !now.Before(until) {
+			delete(s.keys, id)
+			delete(s.retired, id)
+		}
+	}
+
+	old, hadOld := s.keys[s.hmac]
+	if len(secret) == 0 {
+		if hadOld && s.signing.ID == old.ID {
+			return errors.New("cannot remove the shared secret that signs tokens")
+		}
+		if hadOld {
+			s.retired[old.ID] = now.Add(grace)
+		}
+		s.hmac = ""
+		return nil
+	}
+
+	key := NewHMACKey(secret)
+	if hadOld && key.ID == old.ID {
+		return nil
+	}
+	s.keys[key.ID] = key
+	delete(s.retired, key.ID)
+	s.hmac = key.ID
+	if hadOld {
+		s.retired[old.ID] = now.Add(grace)
+		if s.signing.ID == old.ID {
+			s.signing = key
+		}
+	}
+	if s.legacy == nil {
+		s.legacy = &key
+	}
+	return nil
+}
+
+// Sign signs claims with the signing key and sets the kid header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t := jwt.NewWithClaims(s.signing.method(), claims)
+	t.Header["kid"] = s.signing.ID
+	signed, err := t.SignedString(s.signing.private)
+	if err != nil {
+		return "", fmt.Errorf("sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Verify checks the token's signature against the key named by its kid,
+// refusing any algorithm other than that key's, and validates exp, nbf and
+// iat.
+func (s *KeySet) Verify(_ context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		key, err := s.lookup(t.Header["kid"])
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", t.Method.Alg(), key.ID)
+		}
+		return key.public, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (s *KeySet) lookup(kid any) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == nil {
+		if s.legacy == nil || s.expired(s.legacy.ID) {
+			return Key{}, fmt.Errorf("%w: token has no kid", ErrUnknownKey)
+		}
+		return *s.legacy, nil
+	}
+	id, _ := kid.(string)
+	key, ok := s.keys[id]
+	if !ok || s.expired(id) {
+		return Key{}, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// expired reports whether a key replaced by RotateHMAC is past its grace.
+func (s *KeySet) expired(id string) bool {
+	until, ok := s.retired[id]
+	return ok && !s.now().Before(until)
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set. Shared secrets are never
+// published, so an HS256-only set yields no keys.
+func (s *KeySet) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range append([]Key{s.signing}, s.verifyOnly()...) {
+		if jwk, ok := k.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// verifyOnly returns every key but the signing key, in a stable order.
+func (s *KeySet) verifyOnly() []Key {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		if id != s.signing.ID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	keys := make([]Key, len(ids))
+	for i, id := range ids {
+		keys[i] = s.keys[id]
+	}
+	return keys
+}
+
+func (k Key) jwk() (JWK, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Kid: k.ID, Alg: k.Algorithm, Use: "sig"}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// Key returns the verification key described by the JWK.
+func (j JWK) Key() (Key, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+	var pub crypto.PublicKey
+	switch j.Kty {
+	case "RSA":
+		n, err := b64(j.N)
+		if err != nil {
+			return Key{}, fmt.Errorf("jwk %s: invalid n: %w", j.Kid, err)
+		}
+		e, err := b64(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return Key{}, fmt.Errorf("jwk %s: invalid e", j.Kid)
+		}
+		pub = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		if j.Crv != "P-256" {
+			return Key{}, fmt.Errorf("jwk %s: unsupported curve %q", j.Kid, j.Crv)
+		}
+		x, errX := b64(j.X)
+		y, errY := b64(j.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return Key{}, fmt.Errorf("jwk %s: invalid coordinates", j.Kid)
+		}
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return Key{}, fmt.Errorf("jwk %s: %w", j.Kid, err)
+		}
+		pub = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	case "OKP":
+		x, err := b64(j.X)
+		if j.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return Key{}, fmt.Errorf("jwk %s: unsupported or invalid OKP key", j.Kid)
+		}
+		pub = ed25519.PublicKey(x)
+	default:
+		return Key{}, fmt.Errorf("jwk %s: unsupported key type %q", j.Kid, j.Kty)
+	}
+	key, err := NewPublicKey(j.Kid, pub)
+	if err != nil {
+		return Key{}, err
+	}
+	if j.Alg != "" && j.Alg != key.Algorithm {
+		return Key{}, fmt.Errorf("jwk %s: unsupported algorithm %s", j.Kid, j.Alg)
+	}
+	return key, nil
+}
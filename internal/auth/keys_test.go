@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func generateKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{AlgRS256: rsaKey, AlgES256: ecKey, AlgEdDSA: edKey}
+}
+
+func testClaims() Claims {
+	return Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "alice",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+	ctx := context.Background()
+	for alg, priv := range generateKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			key, err := NewPrivateKey("", priv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.Algorithm != alg || key.ID == "" {
+				t.Fatalf("unexpected key %s/%s", key.ID, key.Algorithm)
+			}
+			keys, _ := NewKeySet(key)
+			token, err := keys.Sign(testClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := keys.Verify(ctx, token)
+			if err != nil || claims.Subject != "alice" {
+				t.Errorf("expected the token to verify, got %v, %v", claims, err)
+			}
+
+			// A verifier holding only the public key accepts it too.
+			pub, _ := NewPublicKey(key.ID, priv.Public())
+			other, _ := NewKeySet(NewHMACKey([]byte("other")), pub)
+			if _, err := other.Verify(ctx, token); err != nil {
+				t.Errorf("expected the public key to verify, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	ctx := context.Background()
+	signers := generateKeys(t)
+	old, _ := NewPrivateKey("old", signers[AlgRS256])
+	current, _ := NewPrivateKey("current", signers[AlgEdDSA])
+
+	before, _ := NewKeySet(old)
+	oldToken, _ := before.Sign(testClaims())
+
+	after, _ := NewKeySet(current, old)
+	if _, err := after.Verify(ctx, oldToken); err != nil {
+		t.Errorf("expected a token from the retired key to verify, got %v", err)
+	}
+
+	retired, _ := NewKeySet(current)
+	if _, err := retired.Verify(ctx, oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected a token from a removed key to fail, got %v", err)
+	}
+}
+
+func TestKeySetRotateHMAC(t *testing.T) {
+	ctx := context.Background()
+	keys, _ := NewKeySet(NewHMACKey([]byte("first")))
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	oldToken, _ := keys.Sign(testClaims())
+
+	if err := keys.RotateHMAC([]byte("second"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	newToken, _ := keys.Sign(testClaims())
+	rotated, _ := NewKeySet(NewHMACKey([]byte("second")))
+	if _, err := rotated.Verify(ctx, newToken); err != nil {
+		t.Errorf("expected new tokens to be signed with the new secret, got %v", err)
+	}
+	if _, err := keys.Verify(ctx, oldToken); err != nil {
+		t.Errorf("expected the previous secret to verify during the grace period, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := keys.Verify(ctx, oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected the previous secret to be refused after the grace period, got %v", err)
+	}
+	if _, err := keys.Verify(ctx, newToken); err != nil {
+		t.Errorf("expected the new secret to keep verifying, got %v", err)
+	}
+	if err := keys.RotateHMAC(nil, time.Hour); err == nil {
+		t.Error("expected removing the signing secret to be refused")
+	}
+}
+
+func TestKeySetRejects(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, _ := NewPrivateKey("rsa", generateKeys(t)[AlgRS256])
+	secret := NewHMACKey([]byte("secret"))
+	keys, _ := NewKeySet(rsaKey, secret)
+
+	legacy, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("secret"))
+	if _, err := keys.Verify(ctx, legacy); err != nil {
+		t.Errorf("expected a token without kid to verify with the shared secret, got %v", err)
+	}
+
+	// HS256 signed with the RSA public key, presented under the RSA kid.
+	der, _ := x509.MarshalPKIXPublicKey(rsaKey.public)
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	confused.Header["kid"] = "rsa"
+	confusedToken, _ := confused.SignedString(pubPEM)
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	unknown.Header["kid"] = "nope"
+	unknownToken, _ := unknown.SignedString([]byte("secret"))
+
+	expired := testClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	expiredToken, _ := keys.Sign(expired)
+
+	for name, token := range map[string]string{
+		"algorithm confusion": confusedToken,
+		"unknown kid":         unknownToken,
+		"expired":             expiredToken,
+		"garbage":             "not.a.token",
+	} {
+		if _, err := keys.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	if _, err := NewKeySet(Key{ID: "pub", Algorithm: AlgRS256, public: rsaKey.public}); err == nil {
+		t.Error("expected a public key to be refused as signing key")
+	}
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	for alg, priv := range generateKeys(t) {
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ParseKeyPEM("", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		if err != nil || key.Algorithm != alg || !key.CanSign() {
+			t.Errorf("%s private: got %+v, %v", alg, key, err)
+		}
+
+		der, _ = x509.MarshalPKIXPublicKey(priv.Public())
+		pub, err := ParseKeyPEM("", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		if err != nil || pub.CanSign() || pub.ID != key.ID {
+			t.Errorf("%s public: expected a verify-only key with the same kid, got %+v, %v", alg, pub, err)
+		}
+	}
+
+	if _, err := ParseKeyPEM("", []byte("not pem")); err == nil {
+		t.Error("expected an error for non-PEM input")
+	}
+}
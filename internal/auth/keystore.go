@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileKeyStore keeps API keys in a JSON file, rewritten atomically on every
+// change like FileStore.
+type FileKeyStore struct {
+	path string
+	keys map[string]APIKey
+	mu   sync.RWMutex
+}
+
+// NewFileKeyStore loads keys from path. A missing file is treated as empty
+// and created on the first write.
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	s := &FileKeyStore{path: path, keys: make(map[string]APIKey)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read api key file: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parse api key file %s: %w", path, err)
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s, nil
+}
+
+func (s *FileKeyStore) CreateKey(ctx context.Context, key APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.ID]; ok {
+		return fmt.Errorf("api key %s already exists", key.ID)
+	}
+	return s.commit(key.ID, key, false)
+}
+
+func (s *FileKeyStore) GetKey(ctx context.Context, id string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+func (s *FileKeyStore) ListKeys(ctx context.Context) ([]APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sorted(), nil
+}
+
+func (s *FileKeyStore) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	return s.update(id, func(k *APIKey) { k.RevokedAt = at })
+}
+
+func (s *FileKeyStore) TouchKey(ctx context.Context, id string, at time.Time) error {
+	return s.update(id, func(k *APIKey) { k.LastUsedAt = at })
+}
+
+func (s *FileKeyStore) update(id string, change func(*APIKey)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	change(&k)
+	return s.commit(id, k, true)
+}
+
+// commit stores key and writes the file, restoring the previous state if
+// the write fails.
+func (s *FileKeyStore) commit(id string, key APIKey, existed bool) error {
+	prev := s.keys[id]
+	s.keys[id] = key
+	if err := writeJSONFile(s.path, s.sorted()); err != nil {
+		if existed {
+			s.keys[id] = prev
+		} else {
+			delete(s.keys, id)
+		}
+		return fmt.Errorf("write api key file: %w", err)
+	}
+	return nil
+}
+
+// sorted returns the keys oldest first.
+func (s *FileKeyStore) sorted() []APIKey {
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// OpenAPIKeyStore opens the API key store named by dsn, in the same forms as
+// OpenUserStore. A SQL database can hold both users and keys.
+func OpenAPIKeyStore(ctx context.Context, dsn string) (APIKeyStore, error) {
+	switch {
+	case strings.HasPrefix(dsn, "file:"):
+		return NewFileKeyStore(strings.TrimPrefix(dsn, "file:"))
+	case strings.HasPrefix(dsn, "sqlite:"):
+		return OpenSQLStore(ctx, DialectSQLite, strings.TrimPrefix(dsn, "sqlite:"))
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return OpenSQLStore(ctx, DialectPostgres, dsn)
+	default:
+		return nil, fmt.Errorf("unsupported api key store %q; expected file:, sqlite: or postgres://", dsn)
+	}
+}
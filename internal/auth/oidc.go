@@ -0,0 +1,369 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultJWKSCache = time.Hour
+	// jwksRefetchInterval limits how often an unknown kid triggers a fetch,
+	// so tokens with made-up kids cannot hammer the issuer.
+	jwksRefetchInterval = time.Minute
+	// jwksFetchTimeout bounds discovery plus the JWKS download, which run
+	// detached from the request that started them.
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// ErrNoRoles means an external token mapped to no local role.
+var ErrNoRoles = errors.New("token grants no local role")
+
+// OIDCConfig describes a trusted external issuer.
+type OIDCConfig struct {
+	// Issuer must equal the iss claim and is where discovery starts.
+	Issuer string `json:"issuer"`
+	// Audience lists accepted aud values; a token must carry at least one.
+	Audience []string `json:"audience"`
+	// UsernameClaim names the claim used as the local subject, "sub" by default.
+	UsernameClaim string `json:"username_claim"`
+	// RoleClaim is a dotted path to a string or string list claim, such as
+	// "groups" or "realm_access.roles".
+	RoleClaim string `json:"role_claim"`
+	// Roles maps values of RoleClaim to local roles. Other values are dropped.
+	Roles map[string]string `json:"roles"`
+	// Tenant assigns the issuer's tokens to a tenant. TenantClaim, a dotted
+	// path like RoleClaim, takes the tenant from the token instead; tokens
+	// without it fall back to Tenant.
+	Tenant      string `json:"tenant"`
+	TenantClaim string `json:"tenant_claim"`
+	// AllowUnmapped accepts tokens that map to no local role.
+	AllowUnmapped bool `json:"allow_unmapped"`
+	// JWKSCacheMinutes is how long fetched keys are used before refetching.
+	JWKSCacheMinutes int `json:"jwks_cache_minutes"`
+}
+
+// LoadOIDCConfigs reads a JSON list of issuers.
+func LoadOIDCConfigs(path string) ([]OIDCConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read oidc issuers: %w", err)
+	}
+	var configs []OIDCConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("unmarshal oidc issuers: %w", err)
+	}
+	return configs, nil
+}
+
+// OIDCVerifier verifies tokens from one external issuer. The JWKS location
+// is discovered on first use and keys are cached, then refetched after the
+// cache period or when a token names an unknown kid. Concurrent requests
+// share one fetch, and mu is never held across it.
+type OIDCVerifier struct {
+	cfg     OIDCConfig
+	client  *http.Client
+	now     func() time.Time
+	fetches singleflight.Group
+
+	mu        sync.Mutex
+	jwksURI   string
+	keys      map[string]Key
+	fetchedAt time.Time
+}
+
+func NewOIDCVerifier(cfg OIDCConfig, client *http.Client) (*OIDCVerifier, error) {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	if cfg.Issuer == "" {
+		return nil, errors.New("oidc issuer is required")
+	}
+	if len(cfg.Audience) == 0 {
+		return nil, fmt.Errorf("oidc issuer %s: audience is required", cfg.Issuer)
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "sub"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &OIDCVerifier{cfg: cfg, client: client, now: time.Now}, nil
+}
+
+// Issuer returns the issuer URL tokens must carry.
+func (v *OIDCVerifier) Issuer() string {
+	return v.cfg.Issuer
+}
+
+// Verify checks the signature, iss, aud, exp and nbf of an external token
+// and maps it to local claims.
+func (v *OIDCVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	raw := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{AlgRS256, AlgES256, AlgEdDSA}))
+	_, err := parser.ParseWithClaims(token, raw, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", t.Method.Alg(), key.ID)
+		}
+		return key.public, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if iss, _ := raw["iss"].(string); strings.TrimSuffix(iss, "/") != v.cfg.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if !raw.VerifyExpiresAt(v.now().Unix(), true) {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalidToken)
+	}
+	if !v.audienceAllowed(raw) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return v.claims(raw)
+}
+
+func (v *OIDCVerifier) audienceAllowed(raw jwt.MapClaims) bool {
+	for _, aud := range v.cfg.Audience {
+		if raw.VerifyAudience(aud, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *OIDCVerifier) claims(raw jwt.MapClaims) (*Claims, error) {
+	subject, _ := raw[v.cfg.UsernameClaim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.cfg.UsernameClaim)
+	}
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject, Issuer: v.cfg.Issuer},
+		Roles:            v.roles(raw),
+		Method:           AuthOIDC,
+	}
+	if len(claims.Roles) == 0 && !v.cfg.AllowUnmapped {
+		return nil, ErrNoRoles
+	}
+	claims.Scopes = ScopesForRoles(claims.Roles)
+	claims.Tenant = v.cfg.Tenant
+	if tenant, _ := claimValue(raw, v.cfg.TenantClaim).(string); tenant != "" {
+		claims.Tenant = tenant
+	}
+	claims.ID, _ = raw["jti"].(string)
+	claims.SessionID, _ = raw["sid"].(string)
+	if exp, ok := raw["exp"].(float64); ok {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Unix(int64(exp), 0))
+	}
+	return claims, nil
+}
+
+// roles maps the values of the role claim to local roles.
+func (v *OIDCVerifier) roles(raw jwt.MapClaims) []string {
+	var external []string
+	switch val := claimValue(raw, v.cfg.RoleClaim).(type) {
+	case string:
+		external = []string{val}
+	case []any:
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				external = append(external, s)
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	var roles []string
+	for _, ext := range external {
+		if role, ok := v.cfg.Roles[ext]; ok && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// claimValue follows a dotted path into nested claims. It returns nil when
+// path is empty or does not lead anywhere.
+func claimValue(raw jwt.MapClaims, path string) any {
+	if path == "" {
+		return nil
+	}
+	var value any = map[string]any(raw)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	return value
+}
+
+// key returns the issuer key for kid, fetching the JWKS when the cache is
+// empty or expired, or when kid is unknown and the last fetch is old enough.
+// A failed fetch keeps serving the cached keys.
+func (v *OIDCVerifier) key(ctx context.Context, kid string) (Key, error) {
+	v.mu.Lock()
+	keys := v.keys
+	age := v.now().Sub(v.fetchedAt)
+	v.mu.Unlock()
+
+	if keys == nil || age > v.cacheTTL() {
+		fetched, err := v.refresh(ctx)
+		if err != nil {
+			if keys == nil {
+				return Key{}, err
+			}
+			log.Printf("oidc %s: using cached keys: %v", v.cfg.Issuer, err)
+		} else {
+			keys = fetched
+		}
+	} else if _, ok := keys[kid]; !ok && age > jwksRefetchInterval {
+		if fetched, err := v.refresh(ctx); err != nil {
+			log.Printf("oidc %s: refetch keys for kid %q: %v", v.cfg.Issuer, kid, err)
+		} else {
+			keys = fetched
+		}
+	}
+
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, nil
+		}
+	}
+	key, ok := keys[kid]
+	if !ok {
+		return Key{}, fmt.Errorf("%w: %q from %s", ErrUnknownKey, kid, v.cfg.Issuer)
+	}
+	return key, nil
+}
+
+// refresh runs one fetch for all concurrent callers. The fetch is detached
+// from ctx and bounded by jwksFetchTimeout, so a caller that gives up does
+// not fail the others waiting on it; the caller itself stops waiting when
+// ctx is done.
+func (v *OIDCVerifier) refresh(ctx context.Context) (map[string]Key, error) {
+	ch := v.fetches.DoChan("jwks", func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+		defer cancel()
+		return v.fetchKeys(fetchCtx)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(map[string]Key), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (v *OIDCVerifier) cacheTTL() time.Duration {
+	if v.cfg.JWKSCacheMinutes > 0 {
+		return time.Duration(v.cfg.JWKSCacheMinutes) * time.Minute
+	}
+	return defaultJWKSCache
+}
+
+// fetchKeys discovers the JWKS URI if needed and replaces the cached keys,
+// taking mu only to read and store them. Keys of unsupported types are
+// skipped.
+func (v *OIDCVerifier) fetchKeys(ctx context.Context) (map[string]Key, error) {
+	v.mu.Lock()
+	jwksURI := v.jwksURI
+	v.mu.Unlock()
+
+	if jwksURI == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, v.cfg.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		if strings.TrimSuffix(discovery.Issuer, "/") != v.cfg.Issuer || discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("oidc discovery: issuer %q does not match or jwks_uri missing", discovery.Issuer)
+		}
+		jwksURI = discovery.JWKSURI
+	}
+
+	var set JWKS
+	if err := v.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := make(map[string]Key, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+		keys[key.ID] = key
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.jwksURI = jwksURI
+	v.keys = keys
+	v.fetchedAt = v.now()
+	return keys, nil
+}
+
+func (v *OIDCVerifier) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Verifiers routes each token to the verifier for its issuer: a configured
+// external issuer, or the local key set for everything else.
+type Verifiers struct {
+	local   *KeySet
+	issuers map[string]*OIDCVerifier
+}
+
+func NewVerifiers(local *KeySet, external ...*OIDCVerifier) *Verifiers {
+	v := &Verifiers{local: local, issuers: make(map[string]*OIDCVerifier)}
+	for _, ov := range external {
+		v.issuers[ov.Issuer()] = ov
+	}
+	return v
+}
+
+func (v *Verifiers) Verify(ctx context.Context, token string) (*Claims, error) {
+	var unverified jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &unverified); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if ov, ok := v.issuers[strings.TrimSuffix(unverified.Issuer, "/")]; ok {
+		return ov.Verify(ctx, token)
+	}
+	return v.local.Verify(ctx, token)
+}
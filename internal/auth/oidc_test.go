@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/pkg/utils"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// fakeIssuer is a stand-in OIDC provider serving discovery and a JWKS.
+type fakeIssuer struct {
+	*httptest.Server
+	keys      atomic.Pointer[KeySet]
+	jwksCalls atomic.Int32
+	// gate, when set before the first request, holds JWKS responses until closed.
+	gate chan struct{}
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	f := &fakeIssuer{}
+	f.rotate(t, "k1")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"issuer": f.URL, "jwks_uri": f.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.jwksCalls.Add(1)
+		if f.gate != nil {
+			<-f.gate
+		}
+		utils.RespondJSON(w, http.StatusOK, f.keys.Load().JWKS())
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// rotate replaces the issuer's signing key with a new one named kid.
+func (f *fakeIssuer) rotate(t *testing.T, kid string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := NewPrivateKey(kid, priv)
+	keys, _ := NewKeySet(key)
+	f.keys.Store(keys)
+}
+
+func (f *fakeIssuer) token(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	base := jwt.MapClaims{
+		"iss":    f.URL,
+		"aud":    "flight-service",
+		"sub":    "u-123",
+		"email":  "ana@example.com",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"flight-admins", "everyone"},
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(base, k)
+		} else {
+			base[k] = v
+		}
+	}
+	token, err := f.keys.Load().Sign(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newTestOIDCVerifier(t *testing.T, f *fakeIssuer) *OIDCVerifier {
+	t.Helper()
+	v, err := NewOIDCVerifier(OIDCConfig{
+		Issuer:        f.URL,
+		Audience:      []string{"flight-service"},
+		UsernameClaim: "email",
+		RoleClaim:     "groups",
+		Roles:         map[string]string{"flight-admins": "admin", "travel": "user"},
+	}, f.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	f := newFakeIssuer(t)
+	v := newTestOIDCVerifier(t, f)
+	ctx := context.Background()
+
+	claims, err := v.Verify(ctx, f.token(t, nil))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Subject != "ana@example.com" || !reflect.DeepEqual(claims.Roles, []string{"admin"}) {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   error
+	}{
+		{"wrong audience", jwt.MapClaims{"aud": "other-service"}, ErrInvalidToken},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://evil.example.com"}, ErrInvalidToken},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}, ErrInvalidToken},
+		{"no expiry", jwt.MapClaims{"exp": nil}, ErrInvalidToken},
+		{"not yet valid", jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()}, ErrInvalidToken},
+		{"missing username", jwt.MapClaims{"email": nil}, ErrInvalidToken},
+		{"no mapped role", jwt.MapClaims{"groups": []string{"everyone"}}, ErrNoRoles},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(ctx, f.token(t, tt.claims)); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if calls := f.jwksCalls.Load(); calls != 1 {
+		t.Errorf("expected the JWKS to be fetched once and cached, got %d fetches", calls)
+	}
+}
+
+func TestOIDCVerifierRefetchesOnUnknownKid(t *testing.T) {
+	f := newFakeIssuer(t)
+	v := newTestOIDCVerifier(t, f)
+	now := time.Now()
+	v.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, f.token(t, nil)); err != nil {
+		t.Fatal(err)
+	}
+	f.rotate(t, "k2")
+	rotated := f.token(t, nil)
+
+	if _, err := v.Verify(ctx, rotated); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected unknown kids not to trigger a fetch right away, got %v", err)
+	}
+	now = now.Add(2 * jwksRefetchInterval)
+	if _, err := v.Verify(ctx, rotated); err != nil {
+		t.Errorf("expected the new key to be fetched, got %v", err)
+	}
+	if calls := f.jwksCalls.Load(); calls != 2 {
+		t.Errorf("expected 2 JWKS fetches, got %d", calls)
+	}
+}
+
+func TestOIDCVerifierSharesDetachedFetch(t *testing.T) {
+	f := newFakeIssuer(t)
+	f.gate = make(chan struct{})
+	v := newTestOIDCVerifier(t, f)
+	token := f.token(t, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(ctx, token)
+		first <- err
+	}()
+	for f.jwksCalls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	const waiters = 5
+	errs := make(chan error, waiters)
+	for range waiters {
+		go func() {
+			_, err := v.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+
+	// The caller that started the fetch gives up without waiting for it.
+	cancel()
+	select {
+	case err := <-first:
+		if err == nil {
+			t.Error("expected the cancelled caller to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the cancelled caller to return while the fetch is still running")
+	}
+
+	close(f.gate)
+	for range waiters {
+		if err := <-errs; err != nil {
+			t.Errorf("expected the other callers to get the fetched keys, got %v", err)
+		}
+	}
+	if calls := f.jwksCalls.Load(); calls != 1 {
+		t.Errorf("expected one shared JWKS fetch, got %d", calls)
+	}
+}
+
+func TestVerifiersRouteByIssuer(t *testing.T) {
+	f := newFakeIssuer(t)
+	local, _ := NewKeySet(NewHMACKey([]byte("secret")))
+	verifiers := NewVerifiers(local, newTestOIDCVerifier(t, f))
+	ctx := context.Background()
+
+	if claims, err := verifiers.Verify(ctx, f.token(t, nil)); err != nil || claims.Issuer != f.URL {
+		t.Errorf("expected the external token to verify, got %+v, %v", claims, err)
+	}
+	localToken, _ := local.Sign(testClaims())
+	if claims, err := verifiers.Verify(ctx, localToken); err != nil || claims.Subject != "alice" {
+		t.Errorf("expected the local token to verify, got %+v, %v", claims, err)
+	}
+}
+
+func TestLoadOIDCConfigs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "issuers.json")
+	os.WriteFile(path, []byte(`[{"issuer":"https://sso.example.com/","audience":["flight-service"],"role_claim":"realm_access.roles","roles":{"travel":"user"}}]`), 0o600)
+
+	configs, err := LoadOIDCConfigs(path)
+	if err != nil || len(configs) != 1 {
+		t.Fatalf("got %+v, %v", configs, err)
+	}
+	v, err := NewOIDCVerifier(configs[0], nil)
+	if err != nil || v.Issuer() != "https://sso.example.com" {
+		t.Errorf("expected the trailing slash to be trimmed, got %v, %v", v, err)
+	}
+
+	nested := jwt.MapClaims{"realm_access": map[string]any{"roles": []any{"travel", "other"}}}
+	if roles := v.roles(nested); !reflect.DeepEqual(roles, []string{"user"}) {
+		t.Errorf("expected nested roles to map, got %v", roles)
+	}
+}
+
+func TestOIDCTenant(t *testing.T) {
+	v, _ := NewOIDCVerifier(OIDCConfig{
+		Issuer: "https://sso.example.com", Audience: []string{"flight-service"},
+		Tenant: "partner", TenantClaim: "org.id", AllowUnmapped: true,
+	}, nil)
+	tests := []struct {
+		name string
+		raw  jwt.MapClaims
+		want string
+	}{
+		{"from claim", jwt.MapClaims{"sub": "alice", "org": map[string]any{"id": "acme"}}, "acme"},
+		{"fallback", jwt.MapClaims{"sub": "alice"}, "partner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.claims(tt.raw)
+			if err != nil || claims.Tenant != tt.want {
+				t.Errorf("expected tenant %q, got %+v, %v", tt.want, claims, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms accepted by NewPasswordHasher.
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// ErrUnknownHash is returned for a stored hash in no supported format.
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Argon2Params are the argon2id cost parameters. They are encoded into every
+// hash, so changing them only affects new hashes.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for argon2id.
+var DefaultArgon2Params = Argon2Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+
+// PasswordHasher hashes new passwords with one algorithm. Verification
+// accepts any supported algorithm, so the choice can change over time.
+type PasswordHasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// NewPasswordHasher returns a hasher for the named algorithm with default costs.
+func NewPasswordHasher(algorithm string) (PasswordHasher, error) {
+	switch algorithm {
+	case "", AlgorithmArgon2id:
+		return PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2: DefaultArgon2Params}, nil
+	case AlgorithmBcrypt:
+		return PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.DefaultCost}, nil
+	default:
+		return PasswordHasher{}, fmt.Errorf("unknown password hash algorithm %q", algorithm)
+	}
+}
+
+// Hash returns an encoded hash of password. Argon2id hashes use the PHC
+// string format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func (h PasswordHasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("bcrypt: %w", err)
+		}
+		return string(hash), nil
+	case AlgorithmArgon2id:
+		p := h.Argon2
+		salt := make([]byte, p.SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("argon2id salt: %w", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		return "", fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+	}
+}
+
+// VerifyPassword reports whether password matches the encoded hash, in
+// constant time with respect to the password.
+func VerifyPassword(encoded, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2id(encoded, password)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownHash
+	}
+}
+
+func verifyArgon2id(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("%w: unsupported argon2 version", ErrUnknownHash)
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnknownHash, err)
+	}
+	got := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	hashers := []PasswordHasher{
+		{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}},
+		{Algorithm: AlgorithmBcrypt, BcryptCost: 4},
+	}
+	for _, h := range hashers {
+		t.Run(h.Algorithm, func(t *testing.T) {
+			hash, err := h.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("hash: %v", err)
+			}
+			if strings.Contains(hash, "correct horse") {
+				t.Fatal("hash contains the password")
+			}
+			if ok, err := VerifyPassword(hash, "correct horse"); !ok || err != nil {
+				t.Errorf("expected the password to verify, got %v, %v", ok, err)
+			}
+			if ok, err := VerifyPassword(hash, "battery staple"); ok || err != nil {
+				t.Errorf("expected a wrong password to fail cleanly, got %v, %v", ok, err)
+			}
+			other, _ := h.Hash("correct horse")
+			if other == hash {
+				t.Error("expected a fresh salt for every hash")
+			}
+		})
+	}
+}
+
+func TestVerifyPasswordRejectsUnknownFormats(t *testing.T) {
+	for _, hash := range []string{"pass", "$argon2id$v=19$broken", "$md5$abc"} {
+		if _, err := VerifyPassword(hash, "pass"); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("%q: expected ErrUnknownHash, got %v", hash, err)
+		}
+	}
+}
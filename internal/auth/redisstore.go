@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	refreshKeyPrefix        = "auth:refresh:"
+	revokedTokenKeyPrefix   = "auth:revoked:jti:"
+	revokedSessionKeyPrefix = "auth:revoked:sid:"
+)
+
+// useRefreshScript increments the use count of an existing refresh token
+// and returns the new count with the token's session.
+var useRefreshScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return false end
+local uses = redis.call('HINCRBY', KEYS[1], 'uses', 1)
+return {uses, redis.call('HGET', KEYS[1], 'user'), redis.call('HGET', KEYS[1], 'sid')}
+`)
+
+// RedisSessionStore is a SessionStore on Redis. Used refresh tokens are kept
+// until they expire so that reuse can be detected.
+type RedisSessionStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisSessionStore(client redis.UniversalClient) *RedisSessionStore {
+	return &RedisSessionStore{client: client}
+}
+
+func (s *RedisSessionStore) SaveRefresh(ctx context.Context, hash string, session RefreshSession, ttl time.Duration) error {
+	key := refreshKeyPrefix + hash
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user", session.Username, "sid", session.SessionID, "uses", 0)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("save refresh token: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) UseRefresh(ctx context.Context, hash string) (RefreshSession, error) {
+	res, err := useRefreshScript.Run(ctx, s.client, []string{refreshKeyPrefix + hash}).Slice()
+	if err == redis.Nil {
+		return RefreshSession{}, ErrInvalidRefreshToken
+	} else if err != nil {
+		return RefreshSession{}, fmt.Errorf("use refresh token: %w", err)
+	}
+	uses, _ := res[0].(int64)
+	user, _ := res[1].(string)
+	sid, _ := res[2].(string)
+	session := RefreshSession{Username: user, SessionID: sid}
+	if uses > 1 {
+		return session, ErrRefreshTokenReused
+	}
+	return session, nil
+}
+
+func (s *RedisSessionStore) RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if err := s.client.Set(ctx, revokedTokenKeyPrefix+jti, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("revoke token: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	if err := s.client.Set(ctx, revokedSessionKeyPrefix+sessionID, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	return nil
+}
+
+// Revoked checks both keys in one pipeline. They are checked separately
+// rather than with a multi-key EXISTS, which a cluster rejects when the keys
+// live in different slots.
+func (s *RedisSessionStore) Revoked(ctx context.Context, jti, sessionID string) (bool, error) {
+	cmds, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if jti != "" {
+			pipe.Exists(ctx, revokedTokenKeyPrefix+jti)
+		}
+		if sessionID != "" {
+			pipe.Exists(ctx, revokedSessionKeyPrefix+sessionID)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	for _, cmd := range cmds {
+		if cmd.(*redis.IntCmd).Val() > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Scopes name the operations a token or API key allows.
+const (
+	ScopeFlightsSearch = "flights:search"
+	ScopeProvidersRead = "providers:read"
+	ScopeCacheRead     = "cache:read"
+	ScopeCacheWrite    = "cache:write"
+	ScopeUsersManage   = "users:manage"
+	ScopeAPIKeysManage = "api-keys:manage"
+)
+
+// Roles group scopes for users.
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleUser     = "user"
+)
+
+// roleScopes lists the scopes each role grants.
+var roleScopes = map[string][]string{
+	RoleAdmin: {
+		ScopeFlightsSearch, ScopeProvidersRead, ScopeCacheRead, ScopeCacheWrite,
+		ScopeUsersManage, ScopeAPIKeysManage,
+	},
+	RoleOperator: {ScopeFlightsSearch, ScopeProvidersRead, ScopeCacheRead, ScopeCacheWrite},
+	RoleUser:     {ScopeFlightsSearch},
+}
+
+// KnownRole reports whether role is defined.
+func KnownRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+// KnownScope reports whether scope is granted by any role.
+func KnownScope(scope string) bool {
+	return slices.Contains(roleScopes[RoleAdmin], scope)
+}
+
+// ScopesForRoles returns the sorted union of the scopes of roles. Unknown
+// roles grant nothing.
+func ScopesForRoles(roles []string) []string {
+	set := make(map[string]bool)
+	for _, role := range roles {
+		for _, scope := range roleScopes[role] {
+			set[scope] = true
+		}
+	}
+	scopes := make([]string, 0, len(set))
+	for scope := range set {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// HasScope reports whether the claims grant scope.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// HasRole reports whether the claims carry role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// EnsureAdmin grants the admin role to username when no user holds it, so
+// stores created before roles existed keep an administrator. It does
+// nothing if username does not exist.
+func EnsureAdmin(ctx context.Context, store UserStore, username string) (bool, error) {
+	users, err := store.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, u := range users {
+		if slices.Contains(u.Roles, RoleAdmin) {
+			return false, nil
+		}
+	}
+	user, err := store.Get(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	user.Roles = append(user.Roles, RoleAdmin)
+	user.UpdatedAt = time.Now().UTC()
+	if err := store.Update(ctx, user); err != nil {
+		return false, fmt.Errorf("grant admin to %s: %w", username, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestScopesForRoles(t *testing.T) {
+	tests := []struct {
+		roles []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{[]string{RoleUser}, []string{ScopeFlightsSearch}},
+		{[]string{RoleUser, RoleOperator}, []string{ScopeCacheRead, ScopeCacheWrite, ScopeFlightsSearch, ScopeProvidersRead}},
+		{[]string{"unknown"}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := ScopesForRoles(tt.roles); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ScopesForRoles(%v) = %v, want %v", tt.roles, got, tt.want)
+		}
+	}
+	if !KnownScope(ScopeUsersManage) || KnownScope("flights:delete") {
+		t.Error("KnownScope disagrees with the admin role")
+	}
+}
+
+func TestEnsureAdmin(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+	store.Create(ctx, User{Username: "alice", Roles: []string{RoleUser}})
+	store.Create(ctx, User{Username: "bob"})
+
+	if promoted, err := EnsureAdmin(ctx, store, "nobody"); promoted || err != nil {
+		t.Errorf("expected unknown users to be ignored, got %v, %v", promoted, err)
+	}
+	if promoted, err := EnsureAdmin(ctx, store, "alice"); !promoted || err != nil {
+		t.Fatalf("expected alice to be promoted, got %v, %v", promoted, err)
+	}
+	alice, _ := store.Get(ctx, "alice")
+	if !slices.Contains(alice.Roles, RoleAdmin) || !slices.Contains(alice.Roles, RoleUser) {
+		t.Errorf("expected existing roles to be kept and admin added, got %v", alice.Roles)
+	}
+	if promoted, _ := EnsureAdmin(ctx, store, "bob"); promoted {
+		t.Error("expected no promotion once an admin exists")
+	}
+}
+
+func TestIssuedTokensCarryRolesScopesAndTenant(t *testing.T) {
+	keys, _ := NewKeySet(NewHMACKey([]byte("secret")))
+	token, _, err := NewTokenIssuer(keys, "test", time.Hour).Issue(User{Username: "alice", Roles: []string{RoleUser}, Tenant: "acme"}, "sid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := keys.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.HasRole(RoleUser) || !claims.HasScope(ScopeFlightsSearch) || claims.HasScope(ScopeCacheWrite) || claims.Tenant != "acme" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
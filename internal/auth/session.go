@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused means a rotated refresh token was presented
+	// again. The whole session is revoked, since either the client or an
+	// attacker holds a stolen copy.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshSession is what is stored for a refresh token.
+type RefreshSession struct {
+	Username  string
+	SessionID string
+}
+
+// SessionStore keeps refresh tokens and the revocation denylist.
+type SessionStore interface {
+	// SaveRefresh stores a refresh token, by hash, for ttl.
+	SaveRefresh(ctx context.Context, hash string, session RefreshSession, ttl time.Duration) error
+	// UseRefresh marks a refresh token used and returns its session. It fails
+	// with ErrInvalidRefreshToken for unknown or expired tokens and with
+	// ErrRefreshTokenReused, along with the session, for used ones.
+	UseRefresh(ctx context.Context, hash string) (RefreshSession, error)
+	// RevokeToken denies the access token jti for ttl.
+	RevokeToken(ctx context.Context, jti string, ttl time.Duration) error
+	// RevokeSession denies every token of a session for ttl.
+	RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error
+	// Revoked reports whether the token jti or its session is denied.
+	Revoked(ctx context.Context, jti, sessionID string) (bool, error)
+}
+
+// TokenPair is what a login or refresh returns.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	// Claims are those of the access token.
+	Claims Claims
+}
+
+// Sessions issues access and refresh tokens. Each login starts a session;
+// refreshing rotates the refresh token, and presenting a rotated token again
+// revokes the session.
+type Sessions struct {
+	issuer     *TokenIssuer
+	store      SessionStore
+	users      UserStore
+	refreshTTL time.Duration
+}
+
+func NewSessions(issuer *TokenIssuer, store SessionStore, users UserStore, refreshTTL time.Duration) *Sessions {
+	return &Sessions{issuer: issuer, store: store, users: users, refreshTTL: refreshTTL}
+}
+
+// Start begins a session for an authenticated user.
+func (s *Sessions) Start(ctx context.Context, user User) (TokenPair, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return s.issue(ctx, RefreshSession{Username: user.Username, SessionID: sessionID}, user)
+}
+
+// Refresh exchanges a refresh token for a new pair. The user must still
+// exist and be enabled, and the new token carries the user's current roles and tenant.
+func (s *Sessions) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	session, err := s.store.UseRefresh(ctx, hashToken(refreshToken))
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if rerr := s.store.RevokeSession(ctx, session.SessionID, s.revocationTTL()); rerr != nil {
+			return TokenPair{}, fmt.Errorf("revoke reused session: %w", rerr)
+		}
+		return TokenPair{}, err
+	} else if err != nil {
+		return TokenPair{}, err
+	}
+
+	if revoked, err := s.store.Revoked(ctx, "", session.SessionID); err != nil {
+		return TokenPair{}, err
+	} else if revoked {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	user, err := s.users.Get(ctx, session.Username)
+	if errors.Is(err, ErrUserNotFound) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	} else if err != nil {
+		return TokenPair{}, err
+	}
+	if user.Disabled {
+		return TokenPair{}, ErrAccountDisabled
+	}
+	return s.issue(ctx, session, user)
+}
+
+// Logout revokes the presented access token and every other token of its
+// session.
+func (s *Sessions) Logout(ctx context.Context, claims *Claims) error {
+	if claims.ID != "" {
+		if err := s.store.RevokeToken(ctx, claims.ID, s.issuer.TTL()); err != nil {
+			return err
+		}
+	}
+	if claims.SessionID != "" {
+		return s.store.RevokeSession(ctx, claims.SessionID, s.revocationTTL())
+	}
+	return nil
+}
+
+// Revoked reports whether an access token has been revoked.
+func (s *Sessions) Revoked(ctx context.Context, claims *Claims) (bool, error) {
+	if claims.ID == "" && claims.SessionID == "" {
+		return false, nil
+	}
+	return s.store.Revoked(ctx, claims.ID, claims.SessionID)
+}
+
+func (s *Sessions) issue(ctx context.Context, session RefreshSession, user User) (TokenPair, error) {
+	access, claims, err := s.issuer.Issue(user, session.SessionID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err := s.store.SaveRefresh(ctx, hashToken(refresh), session, s.refreshTTL); err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: s.issuer.TTL(), Claims: claims}, nil
+}
+
+// revocationTTL outlives every token a session can have issued so far.
+func (s *Sessions) revocationTTL() time.Duration {
+	return max(s.refreshTTL, s.issuer.TTL())
+}
+
+// hashToken returns the key a refresh token is stored under, so that the
+// store never holds usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
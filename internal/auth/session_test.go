@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestSessions(t *testing.T) (*Sessions, UserStore, *miniredis.Miniredis) {
+	t.Helper()
+	users, err := NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.Create(context.Background(), User{Username: "alice"})
+	mr := miniredis.RunT(t)
+	store := NewRedisSessionStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	keys, _ := NewKeySet(NewHMACKey([]byte("secret")))
+	return NewSessions(NewTokenIssuer(keys, "test", time.Hour), store, users, 24*time.Hour), users, mr
+}
+
+func TestSessionsRefresh(t *testing.T) {
+	ctx := context.Background()
+	s, users, mr := newTestSessions(t)
+
+	pair, err := s.Start(ctx, User{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(refreshKeyPrefix + pair.RefreshToken) {
+		t.Error("refresh token stored in plain text")
+	}
+
+	rotated, err := s.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	user, _ := users.Get(ctx, "alice")
+	user.Disabled = true
+	users.Update(ctx, user)
+	if _, err := s.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("expected ErrAccountDisabled, got %v", err)
+	}
+
+	mr.FastForward(25 * time.Hour)
+	if _, err := s.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected an expired token to be invalid, got %v", err)
+	}
+}
+
+func TestSessionsReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newTestSessions(t)
+
+	first, _ := s.Start(ctx, User{Username: "alice"})
+	second, err := s.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := s.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected the rest of the session to be revoked, got %v", err)
+	}
+}
+
+func TestSessionsRevokedFailsWhenRedisIsDown(t *testing.T) {
+	ctx := context.Background()
+	s, _, mr := newTestSessions(t)
+	mr.Close()
+
+	if _, err := s.Revoked(ctx, &Claims{SessionID: "sid"}); err == nil {
+		t.Error("expected an error so that callers can fail closed")
+	}
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQL dialects supported by SQLStore.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// SQLStore keeps users and API keys in SQLite or Postgres tables, created
+// on open.
+type SQLStore struct {
+	db      *sql.DB
+	dialect string
+}
+
+// OpenSQLStore connects to the database and makes sure the tables exist. SQLite requires a cgo-enabled build.
+func OpenSQLStore(ctx context.Context, dialect, dsn string) (*SQLStore, error) {
+	driver := map[string]string{DialectSQLite: "sqlite3", DialectPostgres: "pgx"}[dialect]
+	if driver == "" {
+		return nil, fmt.Errorf("unknown sql dialect %q", dialect)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s user store: %w", dialect, err)
+	}
+	s := &SQLStore{db: db, dialect: dialect}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLStore) migrate(ctx context.Context) error {
+	timestamp := "TIMESTAMP"
+	if s.dialect == DialectPostgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS users (
+	username      TEXT PRIMARY KEY,
+	password_hash TEXT NOT NULL,
+	disabled      BOOLEAN NOT NULL DEFAULT FALSE,
+	roles         TEXT NOT NULL DEFAULT '',
+	tenant        TEXT NOT NULL DEFAULT '',
+	failed_logins INTEGER NOT NULL DEFAULT 0,
+	locked_until  `+timestamp+` NULL,
+	last_login_at `+timestamp+` NULL,
+	created_at    `+timestamp+` NOT NULL,
+	updated_at    `+timestamp+` NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("create users table: %w", err)
+	}
+	// Tables created before roles and tenants existed lack the columns.
+	for _, column := range []string{"roles", "tenant"} {
+		if err := s.addTextColumn(ctx, "users", column); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS api_keys (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	owner        TEXT NOT NULL,
+	hash         TEXT NOT NULL,
+	scopes       TEXT NOT NULL,
+	tenant       TEXT NOT NULL DEFAULT '',
+	created_at   `+timestamp+` NOT NULL,
+	expires_at   `+timestamp+` NULL,
+	last_used_at `+timestamp+` NULL,
+	revoked_at   `+timestamp+` NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("create api_keys table: %w", err)
+	}
+	return s.addTextColumn(ctx, "api_keys", "tenant")
+}
+
+// addTextColumn adds a column of empty text to table unless it exists.
+func (s *SQLStore) addTextColumn(ctx context.Context, table, column string) error {
+	if _, err := s.db.ExecContext(ctx, "SELECT "+column+" FROM "+table+" LIMIT 1"); err == nil {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, "ALTER TABLE "+table+" ADD COLUMN "+column+" TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("add %s.%s column: %w", table, column, err)
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+const userColumns = "username, password_hash, disabled, roles, tenant, failed_logins, locked_until, last_login_at, created_at, updated_at"
+
+func (s *SQLStore) Get(ctx context.Context, username string) (User, error) {
+	row := s.db.QueryRowContext(ctx, s.bind("SELECT "+userColumns+" FROM users WHERE username = ?"), username)
+	u, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("get user %s: %w", username, err)
+	}
+	return u, nil
+}
+
+func (s *SQLStore) List(ctx context.Context) ([]User, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list users: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLStore) Create(ctx context.Context, user User) error {
+	if _, err := s.Get(ctx, user.Username); err == nil {
+		return ErrUserExists
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		user.Username, user.PasswordHash, user.Disabled, strings.Join(user.Roles, " "), user.Tenant, user.FailedLogins,
+		nullTime(user.LockedUntil), nullTime(user.LastLoginAt), user.CreatedAt.UTC(), user.UpdatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("create user %s: %w", user.Username, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Update(ctx context.Context, user User) error {
+	res, err := s.db.ExecContext(ctx, s.bind(`UPDATE users SET password_hash = ?, disabled = ?, roles = ?, tenant = ?, failed_logins = ?,
+	locked_until = ?, last_login_at = ?, updated_at = ? WHERE username = ?`),
+		user.PasswordHash, user.Disabled, strings.Join(user.Roles, " "), user.Tenant, user.FailedLogins,
+		nullTime(user.LockedUntil), nullTime(user.LastLoginAt), user.UpdatedAt.UTC(), user.Username)
+	if err != nil {
+		return fmt.Errorf("update user %s: %w", user.Username, err)
+	}
+	return requireRow(res)
+}
+
+func (s *SQLStore) RecordLoginFailure(ctx context.Context, username string, maxFailures int, lockUntil, at time.Time) error {
+	// The right-hand sides see the row before the update, so the count is
+	// incremented and checked in one statement.
+	res, err := s.db.ExecContext(ctx, s.bind(`UPDATE users SET
+	failed_logins = CASE WHEN ? > 0 AND failed_logins + 1 >= ? THEN 0 ELSE failed_logins + 1 END,
+	locked_until = CASE WHEN ? > 0 AND failed_logins + 1 >= ? THEN ? ELSE locked_until END,
+	updated_at = ? WHERE username = ?`),
+		maxFailures, maxFailures, maxFailures, maxFailures, lockUntil.UTC(), at.UTC(), username)
+	if err != nil {
+		return fmt.Errorf("record failed login for %s: %w", username, err)
+	}
+	return requireRow(res)
+}
+
+func (s *SQLStore) RecordLogin(ctx context.Context, username string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, s.bind(`UPDATE users SET failed_logins = 0, locked_until = NULL,
+	last_login_at = ?, updated_at = ? WHERE username = ?`), at.UTC(), at.UTC(), username)
+	if err != nil {
+		return fmt.Errorf("record login for %s: %w", username, err)
+	}
+	return requireRow(res)
+}
+
+func (s *SQLStore) Delete(ctx context.Context, username string) error {
+	res, err := s.db.ExecContext(ctx, s.bind("DELETE FROM users WHERE username = ?"), username)
+	if err != nil {
+		return fmt.Errorf("delete user %s: %w", username, err)
+	}
+	return requireRow(res)
+}
+
+const apiKeyColumns = "id, name, owner, hash, scopes, tenant, created_at, expires_at, last_used_at, revoked_at"
+
+func (s *SQLStore) CreateKey(ctx context.Context, key APIKey) error {
+	_, err := s.db.ExecContext(ctx, s.bind("INSERT INTO api_keys ("+apiKeyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		key.ID, key.Name, key.Owner, key.Hash, strings.Join(key.Scopes, " "), key.Tenant, key.CreatedAt.UTC(),
+		nullTime(key.ExpiresAt), nullTime(key.LastUsedAt), nullTime(key.RevokedAt))
+	if err != nil {
+		return fmt.Errorf("create api key %s: %w", key.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) GetKey(ctx context.Context, id string) (APIKey, error) {
+	row := s.db.QueryRowContext(ctx, s.bind("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?"), id)
+	k, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("get api key %s: %w", id, err)
+	}
+	return k, nil
+}
+
+func (s *SQLStore) ListKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list api keys: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLStore) RevokeKey(ctx context.Context, id string, at time.Time) error {
+	return s.updateKey(ctx, "revoked_at", id, at)
+}
+
+func (s *SQLStore) TouchKey(ctx context.Context, id string, at time.Time) error {
+	return s.updateKey(ctx, "last_used_at", id, at)
+}
+
+func (s *SQLStore) updateKey(ctx context.Context, column, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, s.bind("UPDATE api_keys SET "+column+" = ? WHERE id = ?"), at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("update api key %s: %w", id, err)
+	}
+	if err := requireRow(res); errors.Is(err, ErrUserNotFound) {
+		return ErrAPIKeyNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func scanAPIKey(row scanner) (APIKey, error) {
+	var k APIKey
+	var scopes string
+	var expires, lastUsed, revoked sql.NullTime
+	if err := row.Scan(&k.ID, &k.Name, &k.Owner, &k.Hash, &scopes, &k.Tenant, &k.CreatedAt, &expires, &lastUsed, &revoked); err != nil {
+		return APIKey{}, err
+	}
+	k.Scopes = strings.Fields(scopes)
+	k.ExpiresAt = expires.Time
+	k.LastUsedAt = lastUsed.Time
+	k.RevokedAt = revoked.Time
+	return k, nil
+}
+
+// bind rewrites ? placeholders as $1, $2, ... for Postgres.
+func (s *SQLStore) bind(query string) string {
+	if s.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (User, error) {
+	var u User
+	var roles string
+	var lockedUntil, lastLogin sql.NullTime
+	if err := row.Scan(&u.Username, &u.PasswordHash, &u.Disabled, &roles, &u.Tenant, &u.FailedLogins, &lockedUntil, &lastLogin, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return User{}, err
+	}
+	u.Roles = strings.Fields(roles)
+	u.LockedUntil = lockedUntil.Time
+	u.LastLoginAt = lastLogin.Time
+	return u, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
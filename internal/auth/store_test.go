@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestUserStores runs the same scenario against every store that can run
+// without external services. SQLite needs cgo.
+func TestUserStores(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]func(t *testing.T) UserStore{
+		"file": func(t *testing.T) UserStore {
+			s, err := OpenUserStore(ctx, "file:"+filepath.Join(t.TempDir(), "users.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		"sqlite": func(t *testing.T) UserStore {
+			s, err := OpenUserStore(ctx, "sqlite:"+filepath.Join(t.TempDir(), "users.db"))
+			if err != nil {
+				t.Skipf("sqlite unavailable: %v", err)
+			}
+			t.Cleanup(func() { s.(*SQLStore).Close() })
+			return s
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+			alice := User{Username: "alice", PasswordHash: "h1", Roles: []string{RoleAdmin, RoleUser}, Tenant: "acme", CreatedAt: now, UpdatedAt: now}
+
+			if err := s.Create(ctx, alice); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if err := s.Create(ctx, alice); !errors.Is(err, ErrUserExists) {
+				t.Errorf("expected ErrUserExists, got %v", err)
+			}
+			s.Create(ctx, User{Username: "bob", PasswordHash: "h2", CreatedAt: now, UpdatedAt: now})
+
+			alice.Disabled = true
+			alice.FailedLogins = 3
+			alice.LockedUntil = now.Add(time.Minute)
+			if err := s.Update(ctx, alice); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			got, err := s.Get(ctx, "alice")
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if !got.Disabled || got.FailedLogins != 3 || !got.LockedUntil.Equal(alice.LockedUntil) || !got.LastLoginAt.IsZero() ||
+				!reflect.DeepEqual(got.Roles, alice.Roles) || got.Tenant != alice.Tenant {
+				t.Errorf("unexpected user after update: %+v", got)
+			}
+
+			// Login bookkeeping touches only its own columns.
+			s.RecordLoginFailure(ctx, "alice", 5, now.Add(time.Hour), now)
+			if got, _ := s.Get(ctx, "alice"); got.FailedLogins != 4 || !got.LockedUntil.Equal(alice.LockedUntil) || !got.Disabled {
+				t.Errorf("expected one more failure only, got %+v", got)
+			}
+			s.RecordLoginFailure(ctx, "alice", 5, now.Add(time.Hour), now)
+			if got, _ := s.Get(ctx, "alice"); got.FailedLogins != 0 || !got.LockedUntil.Equal(now.Add(time.Hour)) {
+				t.Errorf("expected the fifth failure to lock the account, got %+v", got)
+			}
+			if err := s.RecordLogin(ctx, "alice", now); err != nil {
+				t.Fatalf("record login: %v", err)
+			}
+			if got, _ := s.Get(ctx, "alice"); !got.LockedUntil.IsZero() || !got.LastLoginAt.Equal(now) ||
+				!got.Disabled || got.Tenant != alice.Tenant || !reflect.DeepEqual(got.Roles, alice.Roles) {
+				t.Errorf("unexpected user after login: %+v", got)
+			}
+			if err := s.RecordLogin(ctx, "nobody", now); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("expected ErrUserNotFound recording a login of a missing user, got %v", err)
+			}
+
+			users, err := s.List(ctx)
+			if err != nil || len(users) != 2 || users[0].Username != "alice" {
+				t.Errorf("unexpected list %+v, err %v", users, err)
+			}
+
+			if err := s.Delete(ctx, "alice"); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if _, err := s.Get(ctx, "alice"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("expected ErrUserNotFound after delete, got %v", err)
+			}
+			if err := s.Update(ctx, alice); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("expected ErrUserNotFound updating a deleted user, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+	s, _ := NewFileStore(path)
+	s.Create(ctx, User{Username: "alice", PasswordHash: "h1"})
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if _, err := reopened.Get(ctx, "alice"); err != nil {
+		t.Errorf("expected alice to survive a reopen, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const throttleKeyPrefix = "auth:throttle:"
+
+// throttleFailScript counts a failure and, from the threshold on, locks the
+// subject for base doubled for every further failure, up to max. Both keys
+// share a hash tag so the script also runs on a cluster.
+var throttleFailScript = redis.NewScript(`
+local window, threshold, base, max = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local n = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], window)
+if n < threshold then return 0 end
+local lock = math.min(base * 2 ^ math.min(n - threshold, 30), max)
+lock = math.floor(lock)
+redis.call('SET', KEYS[2], n, 'PX', lock)
+return lock
+`)
+
+// LoginThrottle slows password guessing across replicas. A client IP or a
+// username with too many recent failures is locked out, for longer with
+// every further failure. IPs get a higher threshold, since many users can
+// share one.
+type LoginThrottle struct {
+	client        redis.UniversalClient
+	ipFailures    int
+	userFailures  int
+	base, max     time.Duration
+	failureMemory time.Duration
+}
+
+// ThrottleOption configures a LoginThrottle.
+type ThrottleOption func(*LoginThrottle)
+
+// WithThrottleThresholds sets how many failures from one IP and for one
+// username are allowed before lockouts start.
+func WithThrottleThresholds(ip, user int) ThrottleOption {
+	return func(t *LoginThrottle) {
+		t.ipFailures = ip
+		t.userFailures = user
+	}
+}
+
+// WithThrottleLockout sets the first lockout, which doubles with every
+// further failure up to max, and how long failures are remembered.
+func WithThrottleLockout(base, max, memory time.Duration) ThrottleOption {
+	return func(t *LoginThrottle) {
+		t.base = base
+		t.max = max
+		t.failureMemory = memory
+	}
+}
+
+func NewLoginThrottle(client redis.UniversalClient, opts ...ThrottleOption) *LoginThrottle {
+	t := &LoginThrottle{
+		client:        client,
+		ipFailures:    20,
+		userFailures:  5,
+		base:          time.Second,
+		max:           15 * time.Minute,
+		failureMemory: time.Hour,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Check returns how long ip or username remains locked out, 0 if neither is.
+func (t *LoginThrottle) Check(ctx context.Context, ip, username string) (time.Duration, error) {
+	var wait time.Duration
+	for _, subject := range t.subjects(ip, username) {
+		ttl, err := t.client.PTTL(ctx, subject.key("lock")).Result()
+		if err != nil {
+			return 0, fmt.Errorf("check login throttle: %w", err)
+		}
+		wait = max(wait, ttl)
+	}
+	return wait, nil
+}
+
+// Fail records a failed login and returns the lockout it caused, if any.
+func (t *LoginThrottle) Fail(ctx context.Context, ip, username string) (time.Duration, error) {
+	var wait time.Duration
+	for _, subject := range t.subjects(ip, username) {
+		ms, err := throttleFailScript.Run(ctx, t.client, []string{subject.key("failures"), subject.key("lock")},
+			t.failureMemory.Milliseconds(), subject.threshold, t.base.Milliseconds(), t.max.Milliseconds()).Int64()
+		if err != nil {
+			return 0, fmt.Errorf("record failed login: %w", err)
+		}
+		wait = max(wait, time.Duration(ms)*time.Millisecond)
+	}
+	return wait, nil
+}
+
+// Succeed forgets the username's failures. The IP's are kept, so that one
+// valid account does not reset an attacker's count.
+func (t *LoginThrottle) Succeed(ctx context.Context, username string) error {
+	subject := t.subjects("", username)[0]
+	if err := t.client.Del(ctx, subject.key("failures"), subject.key("lock")).Err(); err != nil {
+		return fmt.Errorf("reset login throttle: %w", err)
+	}
+	return nil
+}
+
+type throttleSubject struct {
+	id        string
+	threshold int
+}
+
+// key returns a key of the subject, hash-tagged so its keys share a slot.
+func (s throttleSubject) key(kind string) string {
+	return throttleKeyPrefix + "{" + s.id + "}:" + kind
+}
+
+func (t *LoginThrottle) subjects(ip, username string) []throttleSubject {
+	var subjects []throttleSubject
+	if username = strings.ToLower(strings.TrimSpace(username)); username != "" {
+		subjects = append(subjects, throttleSubject{id: "user:" + username, threshold: t.userFailures})
+	}
+	if ip != "" {
+		subjects = append(subjects, throttleSubject{id: "ip:" + ip, threshold: t.ipFailures})
+	}
+	return subjects
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	throttle := NewLoginThrottle(redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		WithThrottleThresholds(4, 2),
+		WithThrottleLockout(time.Second, 5*time.Second, time.Hour),
+	)
+
+	// The username locks at its second failure and doubles up to the cap.
+	for i, want := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		wait, err := throttle.Fail(ctx, "10.0.0.1", "Alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 3 && wait != want {
+			t.Errorf("failure %d: expected %v, got %v", i+1, want, wait)
+		}
+	}
+	if wait, _ := throttle.Check(ctx, "10.0.0.2", "alice"); wait <= 0 {
+		t.Error("expected the username to be locked from any IP")
+	}
+	// The IP passed its own threshold of 4 on the fourth failure.
+	if wait, _ := throttle.Check(ctx, "10.0.0.1", "bob"); wait <= 0 {
+		t.Error("expected the IP to be locked for any username")
+	}
+
+	if err := throttle.Succeed(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := throttle.Check(ctx, "10.0.0.2", "alice"); wait != 0 {
+		t.Errorf("expected a success to clear the username, got %v", wait)
+	}
+	if wait, _ := throttle.Check(ctx, "10.0.0.1", ""); wait <= 0 {
+		t.Error("expected a success to keep the IP lockout")
+	}
+
+	mr.FastForward(6 * time.Second)
+	if wait, _ := throttle.Check(ctx, "10.0.0.1", "bob"); wait != 0 {
+		t.Errorf("expected the lockout to expire, got %v", wait)
+	}
+}
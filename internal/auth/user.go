@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrAccountDisabled    = errors.New("account disabled")
+	ErrAccountLocked      = errors.New("account locked")
+)
+
+// User is an account allowed to request tokens.
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	Disabled     bool      `json:"disabled"`
+	Roles        []string  `json:"roles"`
+	Tenant       string    `json:"tenant,omitempty"`
+	FailedLogins int       `json:"failed_logins"`
+	LockedUntil  time.Time `json:"locked_until,omitempty"`
+	LastLoginAt  time.Time `json:"last_login_at,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Locked reports whether the account is locked out at now.
+func (u User) Locked(now time.Time) bool {
+	return now.Before(u.LockedUntil)
+}
+
+// UserStore persists users. Implementations must be safe for concurrent use.
+type UserStore interface {
+	Get(ctx context.Context, username string) (User, error)
+	List(ctx context.Context) ([]User, error)
+	// Create fails with ErrUserExists if the username is taken.
+	Create(ctx context.Context, user User) error
+	// Update replaces a user and fails with ErrUserNotFound if it is missing.
+	Update(ctx context.Context, user User) error
+	Delete(ctx context.Context, username string) error
+	// RecordLoginFailure counts a failed login at at. When the count reaches
+	// maxFailures (0 never does), it is reset and the account locked until
+	// lockUntil. Other fields are left alone.
+	RecordLoginFailure(ctx context.Context, username string, maxFailures int, lockUntil, at time.Time) error
+	// RecordLogin clears failed logins and any lock and sets the last login.
+	RecordLogin(ctx context.Context, username string, at time.Time) error
+}
+
+const (
+	defaultMaxFailures = 5
+	defaultLockout     = 15 * time.Minute
+)
+
+// Authenticator checks credentials against a UserStore and locks accounts
+// after repeated failures.
+type Authenticator struct {
+	store       UserStore
+	maxFailures int
+	lockout     time.Duration
+	now         func() time.Time
+	// dummyHash is verified for unknown users so that the response time does
+	// not reveal whether a username exists.
+	dummyHash string
+}
+
+// AuthenticatorOption configures an Authenticator.
+type AuthenticatorOption func(*Authenticator)
+
+// WithLockout locks an account for d after maxFailures consecutive failed
+// logins. A maxFailures of 0 disables lockout.
+func WithLockout(maxFailures int, d time.Duration) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.maxFailures = maxFailures
+		a.lockout = d
+	}
+}
+
+func NewAuthenticator(store UserStore, hasher PasswordHasher, opts ...AuthenticatorOption) *Authenticator {
+	a := &Authenticator{
+		store:       store,
+		maxFailures: defaultMaxFailures,
+		lockout:     defaultLockout,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.dummyHash, _ = hasher.Hash("dummy password")
+	return a
+}
+
+// Authenticate returns the user if password is correct. Locked accounts are
+// rejected before the password is checked; disabled accounts only after, so
+// a wrong password never reveals that an account is disabled.
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) (User, error) {
+	user, err := a.store.Get(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		VerifyPassword(a.dummyHash, password)
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	now := a.now()
+	if user.Locked(now) {
+		return User{}, ErrAccountLocked
+	}
+
+	ok, err := VerifyPassword(user.PasswordHash, password)
+	if err != nil {
+		return User{}, fmt.Errorf("verify password for %s: %w", username, err)
+	}
+	if !ok {
+		if err := a.store.RecordLoginFailure(ctx, username, a.maxFailures, now.Add(a.lockout), now); err != nil {
+			return User{}, fmt.Errorf("record failed login for %s: %w", username, err)
+		}
+		return User{}, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return User{}, ErrAccountDisabled
+	}
+
+	if err := a.store.RecordLogin(ctx, username, now); err != nil {
+		return User{}, fmt.Errorf("record login for %s: %w", username, err)
+	}
+	user.FailedLogins = 0
+	user.LockedUntil = time.Time{}
+	user.LastLoginAt = now
+	user.UpdatedAt = now
+	return user, nil
+}
+
+// Bootstrap creates the given user as an admin when the store is empty, so a
+// fresh deployment has an account to log in with. It does nothing otherwise.
+func Bootstrap(ctx context.Context, store UserStore, hasher PasswordHasher, username, password string) (bool, error) {
+	if username == "" || password == "" {
+		return false, nil
+	}
+	users, err := store.List(ctx)
+	if err != nil || len(users) > 0 {
+		return false, err
+	}
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().UTC()
+	err = store.Create(ctx, User{Username: username, PasswordHash: hash, Roles: []string{RoleAdmin}, CreatedAt: now, UpdatedAt: now})
+	return err == nil, err
+}
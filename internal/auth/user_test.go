@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testHasher = PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: 4}
+
+func TestAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Bootstrap(ctx, store, testHasher, "alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := testHasher.Hash("secret")
+	store.Create(ctx, User{Username: "bob", PasswordHash: hash, Disabled: true})
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := NewAuthenticator(store, testHasher, WithLockout(2, time.Minute))
+	a.now = func() time.Time { return now }
+
+	steps := []struct {
+		name     string
+		user     string
+		password string
+		advance  time.Duration
+		want     error
+	}{
+		{"unknown user", "mallory", "secret", 0, ErrInvalidCredentials},
+		{"disabled with wrong password", "bob", "nope", 0, ErrInvalidCredentials},
+		{"disabled", "bob", "secret", 0, ErrAccountDisabled},
+		{"first failure", "alice", "nope", 0, ErrInvalidCredentials},
+		{"second failure locks", "alice", "nope", 0, ErrInvalidCredentials},
+		{"locked", "alice", "secret", 30 * time.Second, ErrAccountLocked},
+		{"lock expired", "alice", "secret", time.Minute, nil},
+		{"counter reset by success", "alice", "nope", 0, ErrInvalidCredentials},
+		{"still unlocked", "alice", "secret", 0, nil},
+	}
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		_, err := a.Authenticate(ctx, s.user, s.password)
+		if !errors.Is(err, s.want) {
+			t.Errorf("%s: got %v, want %v", s.name, err, s.want)
+		}
+	}
+}
+
+func TestBootstrapOnlySeedsEmptyStore(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+
+	if created, err := Bootstrap(ctx, store, testHasher, "", ""); created || err != nil {
+		t.Errorf("expected no user without credentials, got %v, %v", created, err)
+	}
+	if created, err := Bootstrap(ctx, store, testHasher, "admin", "pw"); !created || err != nil {
+		t.Errorf("expected the first user to be created, got %v, %v", created, err)
+	}
+	if created, err := Bootstrap(ctx, store, testHasher, "other", "pw"); created || err != nil {
+		t.Errorf("expected a non-empty store to be left alone, got %v, %v", created, err)
+	}
+}
+
+func TestAuthenticator_KeepsConcurrentAdminChanges(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+	hash, _ := testHasher.Hash("secret")
+	store.Create(ctx, User{Username: "alice", PasswordHash: hash, Roles: []string{RoleUser}})
+	a := NewAuthenticator(store, testHasher)
+
+	// An admin changes the account while a login is being verified.
+	racing := &racingStore{UserStore: store, during: func() {
+		u, _ := store.Get(ctx, "alice")
+		u.Roles = []string{RoleAdmin}
+		store.Update(ctx, u)
+	}}
+	a.store = racing
+	if _, err := a.Authenticate(ctx, "alice", "nope"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate(ctx, "alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := store.Get(ctx, "alice"); len(u.Roles) != 1 || u.Roles[0] != RoleAdmin || u.LastLoginAt.IsZero() {
+		t.Errorf("expected the admin's change to survive the logins, got %+v", u)
+	}
+}
+
+// racingStore runs during after each Get, between the read and the write
+// of a login.
+type racingStore struct {
+	UserStore
+	during func()
+}
+
+func (s *racingStore) Get(ctx context.Context, username string) (User, error) {
+	u, err := s.UserStore.Get(ctx, username)
+	s.during()
+	return u, err
+}
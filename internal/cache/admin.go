@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const scanBatch = 500
+
+// TierStats holds hit and miss counters for one cache tier.
+type TierStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// Stats describes the cache contents and how well it is serving requests.
+// Local is only set when an in-memory tier sits in front of Redis.
+type Stats struct {
+	Entries     int64      `json:"entries"`
+	MemoryBytes int64      `json:"memory_bytes"`
+	HitRatio    float64    `json:"hit_ratio"`
+	Redis       TierStats  `json:"redis"`
+	Local       *TierStats `json:"local,omitempty"`
+}
+
+func newTierStats(hits, misses int64) TierStats {
+	return TierStats{Hits: hits, Misses: misses, HitRatio: ratio(hits, hits+misses)}
+}
+
+func ratio(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// RoutePattern returns a glob matching the current keys for a route and
+// departure date. Empty arguments match any value.
+func RoutePattern(origin, destination, date string) string {
+	part := func(s string) string {
+		if s == "" {
+			return "*"
+		}
+		return strings.ToUpper(s)
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:*", KeyPrefix(), part(origin), part(destination), part(date))
+}
+
+// Keys scans for matching keys. On a cluster every master is scanned, since
+// SCAN only covers the node it runs on.
+func (c *FlightCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	var err error
+	if cluster, ok := c.client.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			found, err := scan(ctx, node, pattern)
+			mu.Lock()
+			defer mu.Unlock()
+			keys = append(keys, found...)
+			return err
+		})
+	} else {
+		keys, err = scan(ctx, c.client, pattern)
+	}
+	if err = c.breaker.observe(ctx, err); err != nil {
+		return nil, fmt.Errorf("cache scan error: %w", err)
+	}
+	return keys, nil
+}
+
+func scan(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, pattern, scanBatch).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func (c *FlightCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := c.breaker.allow(); err != nil {
+		return 0, err
+	}
+	ttl, err := c.client.PTTL(ctx, key).Result()
+	if err = c.breaker.observe(ctx, err); err != nil {
+		return 0, fmt.Errorf("cache ttl error: %w", err)
+	}
+	return ttl, nil
+}
+
+// Delete removes keys with one DEL per key in a pipeline, so keys spread
+// over several cluster slots can be deleted together.
+func (c *FlightCache) Delete(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := c.breaker.allow(); err != nil {
+		return 0, err
+	}
+	cmds, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	if err = c.breaker.observe(ctx, err); err != nil {
+		return 0, fmt.Errorf("cache delete error: %w", err)
+	}
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.(*redis.IntCmd).Val()
+	}
+	return n, nil
+}
+
+// Flush removes every key in the flight namespace, across key versions,
+// without touching anything else stored in the same Redis database.
+func (c *FlightCache) Flush(ctx context.Context) (int64, error) {
+	keys, err := c.Keys(ctx, KeyNamespace+":*")
+	if err != nil {
+		return 0, err
+	}
+	var removed int64
+	for start := 0; start < len(keys); start += scanBatch {
+		end := min(start+scanBatch, len(keys))
+		n, err := c.Delete(ctx, keys[start:end]...)
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// Stats counts cached searches; provider markers share the key prefix but
+// are not entries.
+func (c *FlightCache) Stats(ctx context.Context) (Stats, error) {
+	keys, err := c.Keys(ctx, KeyPrefix()+":*")
+	if err != nil {
+		return Stats{}, err
+	}
+	keys = slices.DeleteFunc(keys, IsMarkerKey)
+	redisStats := newTierStats(c.hits.Load(), c.misses.Load())
+	return Stats{
+		Entries:     int64(len(keys)),
+		MemoryBytes: c.usedMemory(ctx),
+		HitRatio:    redisStats.HitRatio,
+		Redis:       redisStats,
+	}, nil
+}
+
+// usedMemory sums used_memory from INFO memory over every master. It is
+// informational only, so servers that do not report it yield 0.
+func (c *FlightCache) usedMemory(ctx context.Context) int64 {
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return nodeMemory(ctx, c.client)
+	}
+	var total atomic.Int64
+	cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		total.Add(nodeMemory(ctx, node))
+		return nil
+	})
+	return total.Load()
+}
+
+func nodeMemory(ctx context.Context, client redis.Cmdable) int64 {
+	info, err := client.Info(ctx, "memory").Result()
+	if err != nil {
+		return 0
+	}
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "used_memory:"); ok {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestFlightCacheAdmin(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	c := NewFlightCache(mr.Addr(), "", 0, time.Minute, time.Minute)
+
+	search := func(origin, destination, date string) string {
+		d, _ := time.Parse("2006-01-02", date)
+		return SearchKey(models.FlightSearch{Origin: origin, Destination: destination, DepartureDate: d})
+	}
+	jfkLax := search("JFK", "LAX", "2026-12-01")
+	jfkSfo := search("JFK", "SFO", "2026-12-01")
+	jfkLaxLater := search("JFK", "LAX", "2026-12-02")
+	for _, key := range []string{jfkLax, jfkSfo, jfkLaxLater} {
+		if err := c.Set(ctx, key, []models.FlightOffer{{Provider: "MockAir", Price: 80}}, 0); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+	}
+	mr.Set("unrelated", "value")
+	c.Set(ctx, Namespace("").RecoveryKey("MockAir"), nil, 0)
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    int
+	}{
+		{"route and date", RoutePattern("jfk", "lax", "2026-12-01"), 1},
+		{"route", RoutePattern("JFK", "LAX", ""), 2},
+		{"date", RoutePattern("", "", "2026-12-01"), 2},
+		{"everything", RoutePattern("", "", ""), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := c.Keys(ctx, tt.pattern)
+			if err != nil {
+				t.Fatalf("keys: %v", err)
+			}
+			if len(keys) != tt.want {
+				t.Errorf("got %d keys %v, want %d", len(keys), keys, tt.want)
+			}
+		})
+	}
+
+	if ttl, err := c.TTL(ctx, jfkLax); err != nil || ttl <= 0 || ttl > 2*time.Minute {
+		t.Errorf("unexpected ttl %v, err %v", ttl, err)
+	}
+
+	if _, found, err := c.Peek(ctx, jfkLax); !found || err != nil {
+		t.Errorf("expected peek to find %s, got %v", jfkLax, err)
+	}
+	c.Peek(ctx, search("SFO", "JFK", "2026-12-01"))
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.Entries != 3 || stats.Redis.Hits != 0 || stats.Redis.Misses != 0 {
+		t.Errorf("expected 3 entries without the recovery marker and no counted peeks, got %+v", stats)
+	}
+
+	if n, err := c.Delete(ctx, jfkSfo); err != nil || n != 1 {
+		t.Errorf("delete removed %d, err %v; want 1", n, err)
+	}
+	if n, err := c.Flush(ctx); err != nil || n != 3 {
+		t.Errorf("flush removed %d, err %v; want 3", n, err)
+	}
+	if !mr.Exists("unrelated") {
+		t.Error("flush removed a key outside the flight namespace")
+	}
+
+	stats, err = c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.Entries != 0 {
+		t.Errorf("expected no entries after flush, got %d", stats.Entries)
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrUnavailable is returned while Redis is unreachable. Callers are
+// expected to bypass the cache and serve from providers directly.
+var ErrUnavailable = errors.New("cache unavailable")
+
+const defaultBypassCooldown = 5 * time.Second
+
+// breaker stops calling Redis for a cooldown after a connection failure,
+// so a Redis outage costs one timeout per cooldown instead of one per
+// request.
+type breaker struct {
+	cooldown  time.Duration
+	openUntil atomic.Int64
+}
+
+// allow returns ErrUnavailable while the breaker is open.
+func (b *breaker) allow() error {
+	if time.Now().UnixNano() < b.openUntil.Load() {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// observe opens the breaker when err shows Redis is unreachable and returns
+// err wrapped with ErrUnavailable. Misses, server replies such as WRONGTYPE
+// and failures of a caller whose ctx was cancelled or ran out of time pass
+// through unchanged: they say nothing about Redis.
+func (b *breaker) observe(ctx context.Context, err error) error {
+	var reply redis.Error
+	if err == nil || errors.Is(err, redis.Nil) || errors.As(err, &reply) || errors.Is(err, context.Canceled) || ctx.Err() != nil {
+		return err
+	}
+	now := time.Now()
+	if prev := b.openUntil.Swap(now.Add(b.cooldown).UnixNano()); prev < now.UnixNano() {
+		log.Printf("redis unavailable, bypassing cache for %v: %v", b.cooldown, err)
+	}
+	return fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
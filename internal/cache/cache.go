@@ -2,8 +2,9 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/fehepe/flight-price-service/internal/config"
@@ -11,56 +12,233 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// Status describes how a response relates to the cache.
+type Status string
+
+const (
+	StatusFresh Status = "fresh"
+	StatusStale Status = "stale"
+	StatusMiss  Status = "miss"
+	// StatusBypass means the cache was unavailable and providers were queried directly.
+	StatusBypass Status = "bypass"
+)
+
+// NegativeKind marks an entry that records the absence of offers.
+type NegativeKind string
+
+const (
+	// NegativeNoOffers records that a provider answered with no offers.
+	NegativeNoOffers NegativeKind = "no_offers"
+	// NegativeFailed records that a provider call failed.
+	NegativeFailed NegativeKind = "failed"
+	// NegativeMarker records only when it was stored, such as a provider's
+	// latest failure or recovery.
+	NegativeMarker NegativeKind = "marker"
+)
+
+// Entry is a cached search result. It is served as-is until FreshUntil,
+// served while being refreshed in the background until StaleUntil, and
+// evicted after that.
+type Entry struct {
+	Offers     []models.FlightOffer `json:"offers"`
+	StoredAt   time.Time            `json:"stored_at"`
+	FreshUntil time.Time            `json:"fresh_until"`
+	StaleUntil time.Time            `json:"stale_until"`
+	Negative   NegativeKind         `json:"negative,omitempty"`
+}
+
+// NewEntry stamps offers with fresh and stale deadlines relative to now.
+func NewEntry(offers []models.FlightOffer, now time.Time, freshTTL, staleTTL time.Duration) Entry {
+	return Entry{
+		Offers:     offers,
+		StoredAt:   now,
+		FreshUntil: now.Add(freshTTL),
+		StaleUntil: now.Add(freshTTL + staleTTL),
+	}
+}
+
+// NewNegativeEntry returns an entry recording the absence of offers. It is
+// never served stale: once ttl elapses the provider is asked again.
+func NewNegativeEntry(kind NegativeKind, now time.Time, ttl time.Duration) Entry {
+	return Entry{
+		StoredAt:   now,
+		FreshUntil: now.Add(ttl),
+		StaleUntil: now.Add(ttl),
+		Negative:   kind,
+	}
+}
+
+// Status reports whether the entry is fresh or stale at the given time.
+func (e Entry) Status(now time.Time) Status {
+	if now.Before(e.FreshUntil) {
+		return StatusFresh
+	}
+	return StatusStale
+}
+
 type FlightCache struct {
-	client     *redis.Client
+	client     redis.UniversalClient
 	defaultTTL time.Duration
+	staleTTL   time.Duration
+	format     Format
+	breaker    breaker
+
+	hits, misses atomic.Int64
+}
+
+// FlightCacheOption configures optional FlightCache settings.
+type FlightCacheOption func(*FlightCache)
+
+// WithFormat sets how entries are encoded in Redis. Entries are always
+// decoded according to their own header.
+func WithFormat(f Format) FlightCacheOption {
+	return func(c *FlightCache) {
+		c.format = f
+	}
+}
+
+// WithBypassCooldown sets how long Redis is skipped after a connection
+// failure before it is tried again.
+func WithBypassCooldown(d time.Duration) FlightCacheOption {
+	return func(c *FlightCache) {
+		c.breaker.cooldown = d
+	}
 }
 
 type FlightCacher interface {
-	Get(ctx context.Context, key string) ([]models.FlightOffer, bool, error)
-	Set(ctx context.Context, key string, offers []models.FlightOffer) error
+	Get(ctx context.Context, key string) (Entry, bool, error)
+	// Peek reads key like Get but leaves hit and miss counters and the
+	// in-memory tier alone, for inspection by administrators.
+	Peek(ctx context.Context, key string) (Entry, bool, error)
+	// Set stores offers as fresh for ttl, or for the cache's default TTL when ttl is 0.
+	Set(ctx context.Context, key string, offers []models.FlightOffer, ttl time.Duration) error
+	// SetNegative stores a negative entry of the given kind for ttl.
+	SetNegative(ctx context.Context, key string, kind NegativeKind, ttl time.Duration) error
+
+	// Keys lists the keys matching a glob pattern such as RoutePattern returns.
+	Keys(ctx context.Context, pattern string) ([]string, error)
+	// TTL returns the time left before key is evicted.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Delete removes keys and returns how many existed.
+	Delete(ctx context.Context, keys ...string) (int64, error)
+	// Flush removes every flight search key and returns how many existed.
+	Flush(ctx context.Context) (int64, error)
+	// Stats reports entry count, memory use and hit/miss counters.
+	Stats(ctx context.Context) (Stats, error)
 }
 
+// NewFlightCacheFromConfig builds the Redis cache, fronted by an in-memory
+// tier unless LOCAL_CACHE_MAX_ENTRIES is 0.
 func NewFlightCacheFromConfig() FlightCacher {
-	return NewFlightCache(
-		config.Get("REDIS_HOST", "localhost")+":"+config.Get("REDIS_PORT", "6379"),
-		config.Get("REDIS_PASSWORD", ""),
-		config.GetEnvInt("REDIS_DB", 0),
+	format, err := ParseFormat(config.Get("CACHE_CODEC", ""), config.Get("CACHE_COMPRESSION", ""))
+	if err != nil {
+		log.Fatalf("invalid cache format: %v", err)
+	}
+	format.CompressMin = config.GetEnvInt("CACHE_COMPRESS_MIN_BYTES", defaultCompressMin)
+
+	client, err := NewRedisClient(RedisConfigFromEnv())
+	if err != nil {
+		log.Fatalf("invalid redis configuration: %v", err)
+	}
+	remote := NewFlightCacheWithClient(
+		client,
 		time.Duration(config.GetEnvInt("REDIS_DEFAULT_TTL", 30))*time.Second,
+		time.Duration(config.GetEnvInt("REDIS_STALE_TTL", 300))*time.Second,
+		WithFormat(format),
+		WithBypassCooldown(time.Duration(config.GetEnvInt("CACHE_BYPASS_COOLDOWN", 5))*time.Second),
 	)
+
+	maxEntries := config.GetEnvInt("LOCAL_CACHE_MAX_ENTRIES", 1000)
+	if maxEntries <= 0 {
+		return remote
+	}
+	return NewTieredCache(remote, maxEntries, time.Duration(config.GetEnvInt("LOCAL_CACHE_TTL", 10))*time.Second)
 }
 
-func NewFlightCache(addr, password string, db int, defaultTTL time.Duration) *FlightCache {
+// NewFlightCache returns a cache on a single Redis node whose entries are
+// fresh for defaultTTL and may then be served stale for a further staleTTL.
+func NewFlightCache(addr, password string, db int, defaultTTL, staleTTL time.Duration, opts ...FlightCacheOption) *FlightCache {
 	client := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       db,
 	})
-	return &FlightCache{
+	return NewFlightCacheWithClient(client, defaultTTL, staleTTL, opts...)
+}
+
+// NewFlightCacheWithClient is NewFlightCache for an existing client, such as
+// a cluster or sentinel client from NewRedisClient.
+func NewFlightCacheWithClient(client redis.UniversalClient, defaultTTL, staleTTL time.Duration, opts ...FlightCacheOption) *FlightCache {
+	c := &FlightCache{
 		client:     client,
 		defaultTTL: defaultTTL,
+		staleTTL:   staleTTL,
+		format:     DefaultFormat,
+	}
+	c.breaker.cooldown = defaultBypassCooldown
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *FlightCache) Get(ctx context.Context, key string) (Entry, bool, error) {
+	entry, found, err := c.Peek(ctx, key)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
 	}
+	return entry, found, nil
 }
 
-func (c *FlightCache) Get(ctx context.Context, key string) ([]models.FlightOffer, bool, error) {
-	val, err := c.client.Get(ctx, key).Result()
+func (c *FlightCache) Peek(ctx context.Context, key string) (Entry, bool, error) {
+	if err := c.breaker.allow(); err != nil {
+		return Entry{}, false, err
+	}
+	val, err := c.client.Get(ctx, key).Bytes()
+	err = c.breaker.observe(ctx, err)
 	if err == redis.Nil {
-		return nil, false, nil
+		return Entry{}, false, nil
 	} else if err != nil {
-		return nil, false, fmt.Errorf("cache get error: %w", err)
+		return Entry{}, false, fmt.Errorf("cache get error: %w", err)
 	}
 
-	var offers []models.FlightOffer
-	if err := json.Unmarshal([]byte(val), &offers); err != nil {
-		return nil, false, fmt.Errorf("unmarshal error: %w", err)
+	entry, err := c.format.Decode(val)
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("cache decode %s error: %w", key, err)
 	}
-	return offers, true, nil
+	return entry, true, nil
 }
 
-func (c *FlightCache) Set(ctx context.Context, key string, offers []models.FlightOffer) error {
-	data, err := json.Marshal(offers)
+func (c *FlightCache) Set(ctx context.Context, key string, offers []models.FlightOffer, ttl time.Duration) error {
+	return c.setEntry(ctx, key, NewEntry(offers, time.Now(), c.freshTTL(ttl), c.staleTTL))
+}
+
+func (c *FlightCache) SetNegative(ctx context.Context, key string, kind NegativeKind, ttl time.Duration) error {
+	return c.setEntry(ctx, key, NewNegativeEntry(kind, time.Now(), ttl))
+}
+
+func (c *FlightCache) freshTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return c.defaultTTL
+	}
+	return ttl
+}
+
+func (c *FlightCache) setEntry(ctx context.Context, key string, entry Entry) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	data, err := c.format.Encode(entry)
 	if err != nil {
-		return fmt.Errorf("marshal error: %w", err)
+		return err
+	}
+	if err := c.breaker.observe(ctx, c.client.Set(ctx, key, data, time.Until(entry.StaleUntil)).Err()); err != nil {
+		return fmt.Errorf("cache set error: %w", err)
 	}
-	return c.client.Set(ctx, key, data, c.defaultTTL).Err()
+	return nil
 }
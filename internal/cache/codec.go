@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// formatVersion is the first byte of every stored value. It is bumped when
+// the header layout itself changes.
+const formatVersion byte = 1
+
+// headerLen is the version byte followed by the codec and compressor IDs.
+const headerLen = 3
+
+// defaultCompressMin is the payload size below which compression is skipped;
+// small entries such as negative ones gain nothing from it.
+const defaultCompressMin = 512
+
+// Codec serializes cache entries.
+type Codec interface {
+	Name() string
+	ID() byte
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Compressor compresses encoded entries.
+type Compressor interface {
+	Name() string
+	ID() byte
+	Compress(src []byte) []byte
+	Decompress(src []byte) ([]byte, error)
+}
+
+var (
+	JSONCodec    Codec = jsonCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+
+	NoCompression     Compressor = noCompression{}
+	SnappyCompression Compressor = snappyCompression{}
+	ZstdCompression   Compressor = zstdCompression{}
+
+	codecs      = []Codec{JSONCodec, MsgpackCodec}
+	compressors = []Compressor{NoCompression, SnappyCompression, ZstdCompression}
+)
+
+// Format encodes entries with a codec and compressor and prefixes the result
+// with a header naming both. Decoding follows the header rather than the
+// configured format, so replicas configured differently can read each
+// other's entries as long as they know every codec involved.
+type Format struct {
+	Codec       Codec
+	Compressor  Compressor
+	CompressMin int
+}
+
+// DefaultFormat is msgpack without compression.
+var DefaultFormat = Format{Codec: MsgpackCodec, Compressor: NoCompression, CompressMin: defaultCompressMin}
+
+// ParseFormat looks up a codec and compressor by name, as used in
+// CACHE_CODEC and CACHE_COMPRESSION.
+func ParseFormat(codec, compression string) (Format, error) {
+	f := DefaultFormat
+	if codec != "" {
+		c, ok := lookup(codecs, func(c Codec) bool { return c.Name() == codec })
+		if !ok {
+			return Format{}, fmt.Errorf("unknown cache codec %q", codec)
+		}
+		f.Codec = c
+	}
+	if compression != "" {
+		c, ok := lookup(compressors, func(c Compressor) bool { return c.Name() == compression })
+		if !ok {
+			return Format{}, fmt.Errorf("unknown cache compression %q", compression)
+		}
+		f.Compressor = c
+	}
+	return f, nil
+}
+
+func (f Format) Encode(entry Entry) ([]byte, error) {
+	body, err := f.Codec.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("%s marshal error: %w", f.Codec.Name(), err)
+	}
+	compressor := f.Compressor
+	if len(body) < f.CompressMin {
+		compressor = NoCompression
+	}
+	body = compressor.Compress(body)
+
+	out := make([]byte, 0, headerLen+len(body))
+	out = append(out, formatVersion, f.Codec.ID(), compressor.ID())
+	return append(out, body...), nil
+}
+
+func (f Format) Decode(data []byte) (Entry, error) {
+	if len(data) < headerLen {
+		return Entry{}, errors.New("cache value too short")
+	}
+	if data[0] != formatVersion {
+		return Entry{}, fmt.Errorf("unsupported cache format version %d", data[0])
+	}
+	codec, ok := lookup(codecs, func(c Codec) bool { return c.ID() == data[1] })
+	if !ok {
+		return Entry{}, fmt.Errorf("unknown cache codec id %d", data[1])
+	}
+	compressor, ok := lookup(compressors, func(c Compressor) bool { return c.ID() == data[2] })
+	if !ok {
+		return Entry{}, fmt.Errorf("unknown cache compression id %d", data[2])
+	}
+
+	body, err := compressor.Decompress(data[headerLen:])
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s decompress error: %w", compressor.Name(), err)
+	}
+	var entry Entry
+	if err := codec.Unmarshal(body, &entry); err != nil {
+		return Entry{}, fmt.Errorf("%s unmarshal error: %w", codec.Name(), err)
+	}
+	return entry, nil
+}
+
+func lookup[T any](list []T, match func(T) bool) (T, bool) {
+	for _, v := range list {
+		if match(v) {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) ID() byte                           { return 1 }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// msgpackCodec reuses the json tags so both codecs agree on field names and
+// omitempty.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+func (msgpackCodec) ID() byte     { return 2 }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+type noCompression struct{}
+
+func (noCompression) Name() string                          { return "none" }
+func (noCompression) ID() byte                              { return 0 }
+func (noCompression) Compress(src []byte) []byte            { return src }
+func (noCompression) Decompress(src []byte) ([]byte, error) { return src, nil }
+
+// snappyCompression writes the Snappy block format through s2, which is
+// faster than the reference implementation and interoperable with it.
+type snappyCompression struct{}
+
+func (snappyCompression) Name() string                          { return "snappy" }
+func (snappyCompression) ID() byte                              { return 1 }
+func (snappyCompression) Compress(src []byte) []byte            { return s2.EncodeSnappy(nil, src) }
+func (snappyCompression) Decompress(src []byte) ([]byte, error) { return s2.Decode(nil, src) }
+
+type zstdCompression struct{}
+
+// The zstd encoder and decoder are safe for concurrent EncodeAll and
+// DecodeAll calls, so one of each is shared and built on first use.
+var (
+	zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	})
+	zstdDecoder = sync.OnceValue(func() *zstd.Decoder {
+		dec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+		return dec
+	})
+)
+
+func (zstdCompression) Name() string { return "zstd" }
+func (zstdCompression) ID() byte     { return 2 }
+
+func (zstdCompression) Compress(src []byte) []byte {
+	return zstdEncoder().EncodeAll(src, nil)
+}
+
+func (zstdCompression) Decompress(src []byte) ([]byte, error) {
+	return zstdDecoder().DecodeAll(src, nil)
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func sampleEntry(offers int) Entry {
+	list := make([]models.FlightOffer, offers)
+	for i := range list {
+		list[i] = models.FlightOffer{
+			Provider:    "Amadeus",
+			Price:       float64(180+i*7) + 0.99,
+			Duration:    fmt.Sprintf("%dh %dm", 5+i%4, (i*13)%60),
+			Origin:      "JFK",
+			Destination: "LAX",
+			Date:        "2026-12-01",
+		}
+	}
+	return NewEntry(list, time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC), 30*time.Second, 5*time.Minute)
+}
+
+var benchFormats = []Format{
+	{Codec: JSONCodec, Compressor: NoCompression},
+	{Codec: JSONCodec, Compressor: SnappyCompression},
+	{Codec: JSONCodec, Compressor: ZstdCompression},
+	{Codec: MsgpackCodec, Compressor: NoCompression},
+	{Codec: MsgpackCodec, Compressor: SnappyCompression},
+	{Codec: MsgpackCodec, Compressor: ZstdCompression},
+}
+
+func formatName(f Format) string {
+	return f.Codec.Name() + "+" + f.Compressor.Name()
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	entries := map[string]Entry{
+		"offers":   sampleEntry(20),
+		"empty":    NewEntry(nil, time.Now().UTC(), time.Minute, time.Minute),
+		"negative": NewNegativeEntry(NegativeFailed, time.Now().UTC(), time.Minute),
+	}
+	for _, f := range benchFormats {
+		for name, entry := range entries {
+			t.Run(formatName(f)+"/"+name, func(t *testing.T) {
+				data, err := f.Encode(entry)
+				if err != nil {
+					t.Fatalf("encode: %v", err)
+				}
+				// Decoding is driven by the header, whatever the reader's format.
+				got, err := DefaultFormat.Decode(data)
+				if err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if !reflect.DeepEqual(normalizeEntry(got), normalizeEntry(entry)) {
+					t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, entry)
+				}
+			})
+		}
+	}
+}
+
+// normalizeEntry makes entries comparable across codecs, which may decode
+// an empty slice as nil and times without a monotonic reading or location.
+func normalizeEntry(e Entry) Entry {
+	if len(e.Offers) == 0 {
+		e.Offers = nil
+	}
+	e.StoredAt = e.StoredAt.UTC().Round(0)
+	e.FreshUntil = e.FreshUntil.UTC().Round(0)
+	e.StaleUntil = e.StaleUntil.UTC().Round(0)
+	return e
+}
+
+func TestFormatDecodeErrors(t *testing.T) {
+	valid, _ := DefaultFormat.Encode(sampleEntry(1))
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", []byte{formatVersion}},
+		{"unknown version", append([]byte{9}, valid[1:]...)},
+		{"unknown codec", append([]byte{formatVersion, 99}, valid[2:]...)},
+		{"unknown compression", append([]byte{formatVersion, valid[1], 99}, valid[3:]...)},
+		{"legacy json", mustJSON(t, sampleEntry(1))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DefaultFormat.Decode(tt.data); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if _, err := ParseFormat("protobuf", ""); err == nil {
+		t.Error("expected unknown codec to fail")
+	}
+	if _, err := ParseFormat("", "lz4"); err == nil {
+		t.Error("expected unknown compression to fail")
+	}
+	f, err := ParseFormat("json", "zstd")
+	if err != nil || f.Codec != JSONCodec || f.Compressor != ZstdCompression {
+		t.Errorf("unexpected format %+v, err %v", f, err)
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// The benchmarks report stored-bytes, the size of the value written to
+// Redis, alongside the usual time and allocation figures. Compare with:
+//
+//	go test ./internal/cache -run '^$' -bench Format -benchmem
+func BenchmarkFormatEncode(b *testing.B) {
+	for _, offers := range []int{5, 50, 200} {
+		entry := sampleEntry(offers)
+		for _, f := range benchFormats {
+			b.Run(fmt.Sprintf("%s/offers=%d", formatName(f), offers), func(b *testing.B) {
+				var data []byte
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					data, _ = f.Encode(entry)
+				}
+				b.ReportMetric(float64(len(data)), "stored-bytes")
+			})
+		}
+	}
+}
+
+func BenchmarkFormatDecode(b *testing.B) {
+	for _, offers := range []int{5, 50, 200} {
+		entry := sampleEntry(offers)
+		for _, f := range benchFormats {
+			data, err := f.Encode(entry)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("%s/offers=%d", formatName(f), offers), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := f.Decode(data); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(len(data)), "stored-bytes")
+			})
+		}
+	}
+}
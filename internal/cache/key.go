@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+const (
+	// KeyNamespace prefixes every flight search cache key.
+	KeyNamespace = "flights"
+	// KeyVersion is bumped by hand when the meaning of cached data changes
+	// without its shape changing. Version 2 moved values from plain JSON to
+	// the headered Format encoding; version 3 stopped filing searches without
+	// a cabin or currency under the economy and USD keys.
+	KeyVersion = 3
+
+	dateLayout = "2006-01-02"
+)
+
+// schemaVersion fingerprints the shape of the cached types, so adding or
+// renaming a field moves every key to a new namespace instead of serving
+// JSON written by an older build.
+var schemaVersion = fingerprint(reflect.TypeOf(Entry{}), reflect.TypeOf(models.FlightOffer{}), reflect.TypeOf(models.FlightSearch{}))
+
+// Namespace keeps one tenant's entries apart from everyone else's. The zero
+// value is the shared namespace. Keys of every namespace share KeyPrefix and
+// the route layout, so listing, purging and flushing by route covers them all.
+type Namespace string
+
+// SearchKey returns the canonical cache key for a search. The route and
+// departure date stay readable so keys can be listed and purged by route;
+// the trailing hash covers every search dimension.
+func SearchKey(search models.FlightSearch) string {
+	return Namespace("").SearchKey(search)
+}
+
+// ProviderKey returns the cache key for one provider's results for a search.
+func ProviderKey(search models.FlightSearch, provider string) string {
+	return Namespace("").ProviderKey(search, provider)
+}
+
+// RecoveryKey returns the key marking when a provider last answered
+// successfully. Failed entries stored before that moment are ignored.
+func RecoveryKey(provider string) string {
+	return Namespace("").RecoveryKey(provider)
+}
+
+// SearchKey returns the search key within n. The namespace leads the
+// trailing hash, as in "acme.1a2b3c4d5e6f7a8b".
+func (n Namespace) SearchKey(search models.FlightSearch) string {
+	s := Normalize(search)
+
+	returnDate := ""
+	if s.IsRoundTrip() {
+		returnDate = s.ReturnDate.Format(dateLayout)
+	}
+	canonical := strings.Join([]string{
+		s.Origin,
+		s.Destination,
+		s.DepartureDate.Format(dateLayout),
+		returnDate,
+		s.Cabin,
+		s.Currency,
+	}, "|")
+	sum := sha256.Sum256([]byte(canonical))
+
+	return fmt.Sprintf("%s:%s:%s:%s:%s",
+		KeyPrefix(),
+		s.Origin,
+		s.Destination,
+		s.DepartureDate.Format(dateLayout),
+		n.qualify(hex.EncodeToString(sum[:8])),
+	)
+}
+
+// ProviderKey returns the key for one provider's results within n.
+func (n Namespace) ProviderKey(search models.FlightSearch, provider string) string {
+	return n.SearchKey(search) + ":" + provider
+}
+
+// RecoveryKey returns the recovery marker of a provider within n, since each
+// tenant calls providers with its own credentials.
+func (n Namespace) RecoveryKey(provider string) string {
+	return KeyPrefix() + ":recovered:" + n.qualify(provider)
+}
+
+// FailureKey returns the marker of a provider's latest failure within n. It
+// tells a later success whether any failed entry may need invalidating.
+func (n Namespace) FailureKey(provider string) string {
+	return KeyPrefix() + ":failed:" + n.qualify(provider)
+}
+
+// IsMarkerKey reports whether key is a provider recovery or failure marker
+// rather than cached search results.
+func IsMarkerKey(key string) bool {
+	return strings.HasPrefix(key, KeyPrefix()+":recovered:") || strings.HasPrefix(key, KeyPrefix()+":failed:")
+}
+
+func (n Namespace) qualify(s string) string {
+	if n == "" {
+		return s
+	}
+	return string(n) + "." + s
+}
+
+// KeyPrefix returns the namespace shared by all keys of the current version and schema.
+func KeyPrefix() string {
+	return fmt.Sprintf("%s:v%d:%s", KeyNamespace, KeyVersion, schemaVersion)
+}
+
+// Normalize returns the search with codes upper-cased and dates truncated
+// to the day in UTC, so equivalent searches compare equal. An empty cabin or
+// currency stays empty: providers then pick their own default, which need
+// not be economy or USD.
+func Normalize(search models.FlightSearch) models.FlightSearch {
+	n := models.FlightSearch{
+		Origin:        strings.ToUpper(strings.TrimSpace(search.Origin)),
+		Destination:   strings.ToUpper(strings.TrimSpace(search.Destination)),
+		DepartureDate: day(search.DepartureDate),
+		Cabin:         strings.ToLower(search.Cabin),
+		Currency:      strings.ToUpper(search.Currency),
+	}
+	if search.IsRoundTrip() {
+		n.ReturnDate = day(search.ReturnDate)
+	}
+	return n
+}
+
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func fingerprint(types ...reflect.Type) string {
+	var b strings.Builder
+	for _, t := range types {
+		b.WriteString(t.String())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fmt.Fprintf(&b, ";%s %s %s", f.Name, f.Type, f.Tag.Get("json"))
+		}
+		b.WriteString("\n")
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestSearchKey(t *testing.T) {
+	date := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	base := models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: date}
+
+	equivalent := []models.FlightSearch{
+		{Origin: "jfk", Destination: "lax", DepartureDate: date.Add(15 * time.Hour)},
+	}
+	for _, s := range equivalent {
+		if SearchKey(s) != SearchKey(base) {
+			t.Errorf("expected %+v to share the key of %+v", s, base)
+		}
+	}
+	explicit := models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: date, Cabin: models.CabinEconomy, Currency: "USD"}
+	if SearchKey(explicit) != SearchKey(models.FlightSearch{Origin: "jfk", Destination: "LAX", DepartureDate: date, Cabin: "ECONOMY", Currency: "usd"}) {
+		t.Error("expected cabin and currency to be compared case-insensitively")
+	}
+
+	different := []models.FlightSearch{
+		{Origin: "JFK", Destination: "LAX", DepartureDate: date.AddDate(0, 0, 1)},
+		{Origin: "JFK", Destination: "LAX", DepartureDate: date, ReturnDate: date.AddDate(0, 0, 7)},
+		{Origin: "JFK", Destination: "LAX", DepartureDate: date, Cabin: models.CabinBusiness},
+		{Origin: "JFK", Destination: "LAX", DepartureDate: date, Currency: "EUR"},
+		// Providers choose their own default cabin and currency.
+		{Origin: "JFK", Destination: "LAX", DepartureDate: date, Cabin: models.CabinEconomy},
+		{Origin: "JFK", Destination: "LAX", DepartureDate: date, Currency: "USD"},
+		{Origin: "LAX", Destination: "JFK", DepartureDate: date},
+	}
+	seen := map[string]bool{SearchKey(base): true}
+	for _, s := range different {
+		k := SearchKey(s)
+		if seen[k] {
+			t.Errorf("expected a distinct key for %+v, got %s", s, k)
+		}
+		seen[k] = true
+	}
+
+	key := SearchKey(base)
+	if !strings.HasPrefix(key, KeyPrefix()+":JFK:LAX:2025-06-01:") {
+		t.Errorf("expected key to be namespaced by version and route, got %s", key)
+	}
+}
+
+func TestNamespaceKeys(t *testing.T) {
+	search := models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}
+	acme := Namespace("acme")
+
+	if acme.SearchKey(search) == SearchKey(search) || acme.RecoveryKey("Amadeus") == RecoveryKey("Amadeus") {
+		t.Error("expected tenant keys to differ from shared keys")
+	}
+	if !strings.HasPrefix(acme.ProviderKey(search, "Amadeus"), KeyPrefix()+":JFK:LAX:2025-06-01:acme.") {
+		t.Errorf("expected the route layout to be kept, got %s", acme.ProviderKey(search, "Amadeus"))
+	}
+	if SearchKey(search) != Namespace("").SearchKey(search) {
+		t.Error("expected the zero namespace to be the shared one")
+	}
+}
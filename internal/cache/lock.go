@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Locker is implemented by caches that can hand out a short-lived lease on a
+// key, so that only one replica refreshes an expired entry at a time.
+type Locker interface {
+	// Lock tries to take the lease on key for ttl. When acquired is false
+	// another holder owns it; unlock is then nil.
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(), acquired bool, err error)
+}
+
+// releaseScript deletes the lease only if it is still held by our token, so
+// a slow holder never releases a lease that expired and was re-acquired.
+var releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+func lockKey(key string) string {
+	return "lock:" + key
+}
+
+func (c *FlightCache) Lock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, false, err
+	}
+	token, err := randomToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := c.client.SetNX(ctx, lockKey(key), token, ttl).Result()
+	if err = c.breaker.observe(ctx, err); err != nil {
+		return nil, false, fmt.Errorf("cache lock error: %w", err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// The request context may already be done; releasing must still happen.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := releaseScript.Run(ctx, c.client, []string{lockKey(key)}, token).Err(); err != nil {
+			log.Printf("cache unlock %s error: %v", key, err)
+		}
+	}
+	return unlock, true, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("lock token error: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -2,34 +2,186 @@ package cache
 
 import (
 	"context"
+	"path"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/fehepe/flight-price-service/internal/cache"
 	"github.com/fehepe/flight-price-service/pkg/models"
 )
 
+const (
+	defaultFreshTTL = 30 * time.Second
+	defaultStaleTTL = 5 * time.Minute
+)
+
 type MockCache struct {
-	store map[string][]models.FlightOffer
-	mu    sync.RWMutex
+	store    map[string]cache.Entry
+	locks    map[string]time.Time
+	popular  map[string]float64
+	freshTTL time.Duration
+	staleTTL time.Duration
+	mu       sync.RWMutex
+
+	hits, misses atomic.Int64
 }
 
 func NewMockCache() *MockCache {
+	return NewMockCacheWithTTL(defaultFreshTTL, defaultStaleTTL)
+}
+
+// NewMockCacheWithTTL returns a mock cache with custom fresh and stale windows.
+func NewMockCacheWithTTL(freshTTL, staleTTL time.Duration) *MockCache {
 	return &MockCache{
-		store: make(map[string][]models.FlightOffer),
+		store:    make(map[string]cache.Entry),
+		locks:    make(map[string]time.Time),
+		popular:  make(map[string]float64),
+		freshTTL: freshTTL,
+		staleTTL: staleTTL,
+	}
+}
+
+func (m *MockCache) Get(ctx context.Context, key string) (cache.Entry, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.store[key]
+	if !ok || !time.Now().Before(entry.StaleUntil) {
+		m.misses.Add(1)
+		return cache.Entry{}, false, nil
+	}
+	m.hits.Add(1)
+	return entry, true, nil
+}
+
+func (m *MockCache) Peek(ctx context.Context, key string) (cache.Entry, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.store[key]
+	if !ok || !time.Now().Before(entry.StaleUntil) {
+		return cache.Entry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+func (m *MockCache) Set(ctx context.Context, key string, offers []models.FlightOffer, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ttl <= 0 {
+		ttl = m.freshTTL
+	}
+	m.store[key] = cache.NewEntry(offers, time.Now(), ttl, m.staleTTL)
+	return nil
+}
+
+func (m *MockCache) SetNegative(ctx context.Context, key string, kind cache.NegativeKind, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store[key] = cache.NewNegativeEntry(kind, time.Now(), ttl)
+	return nil
+}
+
+func (m *MockCache) Lock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if expiry, held := m.locks[key]; held && time.Now().Before(expiry) {
+		return nil, false, nil
+	}
+	m.locks[key] = time.Now().Add(ttl)
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.locks, key)
+	}, true, nil
+}
+
+func (m *MockCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []string
+	now := time.Now()
+	for key, entry := range m.store {
+		if ok, _ := path.Match(pattern, key); ok && now.Before(entry.StaleUntil) {
+			keys = append(keys, key)
+		}
 	}
+	return keys, nil
 }
 
-func (m *MockCache) Get(ctx context.Context, key string) ([]models.FlightOffer, bool, error) {
+func (m *MockCache) TTL(ctx context.Context, key string) (time.Duration, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	data, ok := m.store[key]
-	return data, ok, nil
+	entry, ok := m.store[key]
+	if !ok {
+		return 0, nil
+	}
+	return max(time.Until(entry.StaleUntil), 0), nil
+}
+
+func (m *MockCache) Delete(ctx context.Context, keys ...string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for _, key := range keys {
+		if _, ok := m.store[key]; ok {
+			delete(m.store, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MockCache) Flush(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for key := range m.store {
+		if strings.HasPrefix(key, cache.KeyNamespace+":") {
+			delete(m.store, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MockCache) Stats(ctx context.Context) (cache.Stats, error) {
+	keys, _ := m.Keys(ctx, cache.KeyPrefix()+":*")
+	hits, misses := m.hits.Load(), m.misses.Load()
+	var ratio float64
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	keys = slices.DeleteFunc(keys, cache.IsMarkerKey)
+	return cache.Stats{
+		Entries:  int64(len(keys)),
+		HitRatio: ratio,
+		Redis:    cache.TierStats{Hits: hits, Misses: misses, HitRatio: ratio},
+	}, nil
 }
 
-func (m *MockCache) Set(ctx context.Context, key string, offers []models.FlightOffer) error {
+func (m *MockCache) Track(ctx context.Context, search models.FlightSearch) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.store[key] = offers
+	m.popular[cache.SearchMember(search)]++
 	return nil
 }
+
+func (m *MockCache) Popular(ctx context.Context, n int) ([]cache.PopularSearch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return cache.RankPopular(m.popular, n, time.Now()), nil
+}
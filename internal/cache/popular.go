@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/pkg/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// popularPrefix names the daily sorted sets counting searches. It sits
+	// outside KeyNamespace so flushing the cache keeps the popularity data.
+	// The hash tag keeps every bucket in one cluster slot so they can be
+	// summed with ZUNION.
+	popularPrefix = "popular:{" + KeyNamespace + "}"
+	// popularDays is how many daily buckets are summed when ranking.
+	popularDays = 2
+	popularTTL  = (popularDays + 1) * 24 * time.Hour
+)
+
+// PopularSearch is a search and how often it was requested recently.
+type PopularSearch struct {
+	Search models.FlightSearch `json:"search"`
+	Count  float64             `json:"count"`
+}
+
+// PopularityTracker is implemented by caches that count searches so the
+// most requested ones can be kept warm.
+type PopularityTracker interface {
+	// Track counts one request for the search.
+	Track(ctx context.Context, search models.FlightSearch) error
+	// Popular returns up to n upcoming searches, most requested first.
+	Popular(ctx context.Context, n int) ([]PopularSearch, error)
+}
+
+// SearchMember encodes a normalized search as a sorted set member.
+func SearchMember(search models.FlightSearch) string {
+	n := Normalize(search)
+	returnDate := ""
+	if n.IsRoundTrip() {
+		returnDate = n.ReturnDate.Format(dateLayout)
+	}
+	return strings.Join([]string{n.Origin, n.Destination, n.DepartureDate.Format(dateLayout), returnDate, n.Cabin, n.Currency}, "|")
+}
+
+// ParseSearchMember decodes a member written by SearchMember.
+func ParseSearchMember(member string) (models.FlightSearch, error) {
+	parts := strings.Split(member, "|")
+	if len(parts) != 6 {
+		return models.FlightSearch{}, fmt.Errorf("invalid search member %q", member)
+	}
+	departure, err := time.Parse(dateLayout, parts[2])
+	if err != nil {
+		return models.FlightSearch{}, fmt.Errorf("invalid search member %q: %w", member, err)
+	}
+	search := models.FlightSearch{Origin: parts[0], Destination: parts[1], DepartureDate: departure, Cabin: parts[4], Currency: parts[5]}
+	if parts[3] != "" {
+		if search.ReturnDate, err = time.Parse(dateLayout, parts[3]); err != nil {
+			return models.FlightSearch{}, fmt.Errorf("invalid search member %q: %w", member, err)
+		}
+	}
+	return search, nil
+}
+
+// RankPopular drops searches departing before today, orders the rest by
+// count and keeps at most n. Ties are broken by member so ranking is stable.
+func RankPopular(counts map[string]float64, n int, now time.Time) []PopularSearch {
+	today := now.UTC().Truncate(24 * time.Hour)
+	type ranked struct {
+		member string
+		PopularSearch
+	}
+	var all []ranked
+	for member, count := range counts {
+		search, err := ParseSearchMember(member)
+		if err != nil || search.DepartureDate.Before(today) {
+			continue
+		}
+		all = append(all, ranked{member, PopularSearch{Search: search, Count: count}})
+	}
+	slices.SortFunc(all, func(a, b ranked) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.member, b.member)
+	})
+	out := make([]PopularSearch, 0, min(n, len(all)))
+	for _, r := range all[:min(n, len(all))] {
+		out = append(out, r.PopularSearch)
+	}
+	return out
+}
+
+func popularKey(day time.Time) string {
+	return popularPrefix + ":" + day.UTC().Format(dateLayout)
+}
+
+func (c *FlightCache) Track(ctx context.Context, search models.FlightSearch) error {
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+	key := popularKey(time.Now())
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZIncrBy(ctx, key, 1, SearchMember(search))
+		pipe.Expire(ctx, key, popularTTL)
+		return nil
+	})
+	if err = c.breaker.observe(ctx, err); err != nil {
+		return fmt.Errorf("cache track error: %w", err)
+	}
+	return nil
+}
+
+// Popular sums the counts of the last popularDays daily buckets.
+func (c *FlightCache) Popular(ctx context.Context, n int) ([]PopularSearch, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	keys := make([]string, popularDays)
+	for i := range keys {
+		keys[i] = popularKey(now.AddDate(0, 0, -i))
+	}
+	members, err := c.client.ZUnionWithScores(ctx, redis.ZStore{Keys: keys}).Result()
+	if err = c.breaker.observe(ctx, err); err != nil {
+		return nil, fmt.Errorf("cache popular error: %w", err)
+	}
+	counts := make(map[string]float64, len(members))
+	for _, m := range members {
+		if member, ok := m.Member.(string); ok {
+			counts[member] = m.Score
+		}
+	}
+	return RankPopular(counts, n, now), nil
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestSearchMemberRoundTrip(t *testing.T) {
+	day := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	tests := []models.FlightSearch{
+		{Origin: "jfk", Destination: "lax", DepartureDate: day},
+		{Origin: "JFK", Destination: "LHR", DepartureDate: day, ReturnDate: day.AddDate(0, 0, 7), Cabin: models.CabinBusiness, Currency: "eur"},
+	}
+	for _, search := range tests {
+		got, err := ParseSearchMember(SearchMember(search))
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if SearchKey(got) != SearchKey(search) {
+			t.Errorf("round trip changed the search: %+v -> %+v", search, got)
+		}
+	}
+}
+
+func TestFlightCachePopular(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	c := NewFlightCache(mr.Addr(), "", 0, time.Minute, time.Minute)
+
+	upcoming := time.Now().AddDate(0, 0, 3)
+	track := func(origin, destination string, date time.Time, times int) {
+		for i := 0; i < times; i++ {
+			if err := c.Track(ctx, models.FlightSearch{Origin: origin, Destination: destination, DepartureDate: date}); err != nil {
+				t.Fatalf("track: %v", err)
+			}
+		}
+	}
+	track("MIA", "MAD", upcoming, 1)
+	track("JFK", "LAX", upcoming, 3)
+	track("BOS", "SFO", time.Now().AddDate(0, 0, -2), 5)
+
+	popular, err := c.Popular(ctx, 10)
+	if err != nil {
+		t.Fatalf("popular: %v", err)
+	}
+	if len(popular) != 2 {
+		t.Fatalf("expected past departures to be dropped, got %+v", popular)
+	}
+	if popular[0].Search.Origin != "JFK" || popular[0].Count != 3 {
+		t.Errorf("expected JFK-LAX to rank first, got %+v", popular[0])
+	}
+}
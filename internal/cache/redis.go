@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisMode selects the Redis deployment the client connects to.
+type RedisMode string
+
+const (
+	// RedisModeAuto picks sentinel when a master name is set, cluster for
+	// several addresses and a single node otherwise.
+	RedisModeAuto       RedisMode = ""
+	RedisModeStandalone RedisMode = "standalone"
+	RedisModeCluster    RedisMode = "cluster"
+	RedisModeSentinel   RedisMode = "sentinel"
+)
+
+// RedisConfig describes how to reach Redis. For sentinel, Addrs lists the
+// sentinels; for cluster, any subset of the nodes.
+type RedisConfig struct {
+	Mode             RedisMode
+	Addrs            []string
+	MasterName       string
+	Username         string
+	Password         string
+	SentinelUsername string
+	SentinelPassword string
+	DB               int
+
+	TLS                   bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSServerName         string
+	TLSInsecureSkipVerify bool
+
+	PoolSize     int
+	MinIdleConns int
+	MaxRetries   int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+}
+
+// RedisConfigFromEnv reads the REDIS_* variables. REDIS_ADDRS takes a
+// comma-separated list and falls back to REDIS_HOST:REDIS_PORT. Zero pool
+// and timeout values keep the go-redis defaults.
+func RedisConfigFromEnv() RedisConfig {
+	addrs := splitList(config.Get("REDIS_ADDRS", ""))
+	if len(addrs) == 0 {
+		addrs = []string{config.Get("REDIS_HOST", "localhost") + ":" + config.Get("REDIS_PORT", "6379")}
+	}
+	millis := func(key string) time.Duration {
+		return time.Duration(config.GetEnvInt(key, 0)) * time.Millisecond
+	}
+	return RedisConfig{
+		Mode:             RedisMode(config.Get("REDIS_MODE", "")),
+		Addrs:            addrs,
+		MasterName:       config.Get("REDIS_MASTER_NAME", ""),
+		Username:         config.Get("REDIS_USERNAME", ""),
+		Password:         config.Get("REDIS_PASSWORD", ""),
+		SentinelUsername: config.Get("REDIS_SENTINEL_USERNAME", ""),
+		SentinelPassword: config.Get("REDIS_SENTINEL_PASSWORD", ""),
+		DB:               config.GetEnvInt("REDIS_DB", 0),
+
+		TLS:                   config.GetEnvBool("REDIS_TLS", false),
+		TLSCAFile:             config.Get("REDIS_TLS_CA_FILE", ""),
+		TLSCertFile:           config.Get("REDIS_TLS_CERT_FILE", ""),
+		TLSKeyFile:            config.Get("REDIS_TLS_KEY_FILE", ""),
+		TLSServerName:         config.Get("REDIS_TLS_SERVER_NAME", ""),
+		TLSInsecureSkipVerify: config.GetEnvBool("REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+
+		PoolSize:     config.GetEnvInt("REDIS_POOL_SIZE", 0),
+		MinIdleConns: config.GetEnvInt("REDIS_MIN_IDLE_CONNS", 0),
+		MaxRetries:   config.GetEnvInt("REDIS_MAX_RETRIES", 0),
+		DialTimeout:  millis("REDIS_DIAL_TIMEOUT_MS"),
+		ReadTimeout:  millis("REDIS_READ_TIMEOUT_MS"),
+		WriteTimeout: millis("REDIS_WRITE_TIMEOUT_MS"),
+		PoolTimeout:  millis("REDIS_POOL_TIMEOUT_MS"),
+	}
+}
+
+// NewRedisClient builds a client for a single node, a cluster or a
+// sentinel-managed master, depending on cfg.
+func NewRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, fmt.Errorf("redis: no address configured")
+	}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		DB:               cfg.DB,
+		TLSConfig:        tlsConfig,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		MaxRetries:       cfg.MaxRetries,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		PoolTimeout:      cfg.PoolTimeout,
+	}
+
+	switch cfg.Mode {
+	case RedisModeAuto:
+		return redis.NewUniversalClient(opts), nil
+	case RedisModeStandalone:
+		if len(cfg.Addrs) > 1 {
+			return nil, fmt.Errorf("redis: standalone mode takes one address, got %d", len(cfg.Addrs))
+		}
+		return redis.NewClient(opts.Simple()), nil
+	case RedisModeCluster:
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("redis: cluster mode only supports db 0")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	case RedisModeSentinel:
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("redis: sentinel mode requires a master name")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	default:
+		return nil, fmt.Errorf("redis: unknown mode %q", cfg.Mode)
+	}
+}
+
+func (cfg RedisConfig) tlsConfig() (*tls.Config, error) {
+	if !cfg.TLS {
+		return nil, nil
+	}
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+	}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis: read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis: no certificates in CA file %s", cfg.TLSCAFile)
+		}
+		tc.RootCAs = pool
+	}
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis: load client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestNewRedisClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     RedisConfig
+		want    string
+		wantErr bool
+	}{
+		{"auto single node", RedisConfig{Addrs: []string{"a:6379"}}, "*redis.Client", false},
+		{"auto cluster", RedisConfig{Addrs: []string{"a:6379", "b:6379"}}, "*redis.ClusterClient", false},
+		{"auto sentinel", RedisConfig{Addrs: []string{"s:26379"}, MasterName: "mymaster"}, "*redis.Client", false},
+		{"cluster with one seed", RedisConfig{Mode: RedisModeCluster, Addrs: []string{"a:6379"}}, "*redis.ClusterClient", false},
+		{"cluster with db", RedisConfig{Mode: RedisModeCluster, Addrs: []string{"a:6379"}, DB: 2}, "", true},
+		{"sentinel without master", RedisConfig{Mode: RedisModeSentinel, Addrs: []string{"s:26379"}}, "", true},
+		{"standalone with two addresses", RedisConfig{Mode: RedisModeStandalone, Addrs: []string{"a:6379", "b:6379"}}, "", true},
+		{"unknown mode", RedisConfig{Mode: "ring", Addrs: []string{"a:6379"}}, "", true},
+		{"no address", RedisConfig{}, "", true},
+		{"missing CA file", RedisConfig{Addrs: []string{"a:6379"}, TLS: true, TLSCAFile: "testdata/missing.pem"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewRedisClient(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer client.Close()
+			if got := fmt.Sprintf("%T", client); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlightCacheBypassesUnavailableRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	c := NewFlightCache(mr.Addr(), "", 0, time.Minute, time.Minute, WithBypassCooldown(200*time.Millisecond))
+	offers := []models.FlightOffer{{Provider: "MockAir", Price: 80}}
+
+	addr := mr.Addr()
+	mr.Close()
+	if _, _, err := c.Get(ctx, "k"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable with redis down, got %v", err)
+	}
+
+	// While the breaker is open Redis is not contacted at all.
+	if err := mr.StartAddr(addr); err != nil {
+		t.Fatalf("restart miniredis: %v", err)
+	}
+	if err := c.Set(ctx, "k", offers, 0); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected the breaker to short-circuit, got %v", err)
+	}
+	if mr.Exists("k") {
+		t.Fatal("expected no write while bypassing")
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if err := c.Set(ctx, "k", offers, 0); err != nil {
+		t.Fatalf("expected redis to be used again after the cooldown, got %v", err)
+	}
+	if _, found, err := c.Get(ctx, "k"); err != nil || !found {
+		t.Errorf("expected the entry after recovery, found=%v err=%v", found, err)
+	}
+}
+
+func TestFlightCacheBreakerIgnoresCallerContext(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := NewFlightCache(mr.Addr(), "", 0, time.Minute, time.Minute)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	for name, ctx := range map[string]context.Context{"cancelled": cancelled, "deadline exceeded": expired} {
+		if err := c.SetNegative(ctx, "k", NegativeFailed, time.Minute); err == nil || errors.Is(err, ErrUnavailable) {
+			t.Errorf("%s: expected the caller's error, got %v", name, err)
+		}
+		if _, _, err := c.Get(context.Background(), "k"); err != nil {
+			t.Errorf("%s: expected a healthy caller to keep using redis, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+// InvalidationChannel is the Redis pub/sub channel replicas use to tell each
+// other to drop a key from their in-memory tier.
+const InvalidationChannel = "flights:invalidate"
+
+// flushAll is published instead of a key when every entry was removed.
+const flushAll = "*"
+
+type invalidation struct {
+	Key    string `json:"key"`
+	Origin string `json:"origin"`
+}
+
+// TieredCache keeps a size-bounded in-memory LRU in front of the Redis
+// FlightCache, saving the Redis round trip and JSON decode on hot keys.
+// Writes are published on InvalidationChannel so other replicas drop their
+// local copy of the key.
+type TieredCache struct {
+	remote   *FlightCache
+	local    *lru
+	localTTL time.Duration
+	nodeID   string
+	cancel   context.CancelFunc
+
+	localHits, localMisses atomic.Int64
+}
+
+// NewTieredCache wraps remote with a local tier holding up to maxEntries
+// entries for at most localTTL each, and starts listening for invalidations.
+func NewTieredCache(remote *FlightCache, maxEntries int, localTTL time.Duration) *TieredCache {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &TieredCache{
+		remote:   remote,
+		local:    newLRU(maxEntries),
+		localTTL: localTTL,
+		nodeID:   nodeID(),
+		cancel:   cancel,
+	}
+	go c.listen(ctx)
+	return c
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string) (Entry, bool, error) {
+	if entry, ok := c.local.get(key, time.Now()); ok {
+		c.localHits.Add(1)
+		return entry, true, nil
+	}
+	c.localMisses.Add(1)
+
+	entry, found, err := c.remote.Get(ctx, key)
+	if err != nil || !found {
+		return Entry{}, false, err
+	}
+	c.local.put(key, entry, c.localExpiry(entry))
+	return entry, true, nil
+}
+
+// Peek reads Redis, the source of truth, so the entry shown is the one
+// every replica sees.
+func (c *TieredCache) Peek(ctx context.Context, key string) (Entry, bool, error) {
+	return c.remote.Peek(ctx, key)
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, offers []models.FlightOffer, ttl time.Duration) error {
+	entry := NewEntry(offers, time.Now(), c.remote.freshTTL(ttl), c.remote.staleTTL)
+	if err := c.remote.setEntry(ctx, key, entry); err != nil {
+		return err
+	}
+	c.local.put(key, entry, c.localExpiry(entry))
+	c.publish(ctx, key)
+	return nil
+}
+
+func (c *TieredCache) SetNegative(ctx context.Context, key string, kind NegativeKind, ttl time.Duration) error {
+	entry := NewNegativeEntry(kind, time.Now(), ttl)
+	if err := c.remote.setEntry(ctx, key, entry); err != nil {
+		return err
+	}
+	c.local.put(key, entry, c.localExpiry(entry))
+	c.publish(ctx, key)
+	return nil
+}
+
+// Lock delegates to Redis so leases are shared by every replica.
+func (c *TieredCache) Lock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	return c.remote.Lock(ctx, key, ttl)
+}
+
+func (c *TieredCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+	return c.remote.Keys(ctx, pattern)
+}
+
+func (c *TieredCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.remote.TTL(ctx, key)
+}
+
+func (c *TieredCache) Delete(ctx context.Context, keys ...string) (int64, error) {
+	n, err := c.remote.Delete(ctx, keys...)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		c.local.remove(key)
+		c.publish(ctx, key)
+	}
+	return n, nil
+}
+
+func (c *TieredCache) Flush(ctx context.Context) (int64, error) {
+	n, err := c.remote.Flush(ctx)
+	if err != nil {
+		return 0, err
+	}
+	c.local.clear()
+	c.publish(ctx, flushAll)
+	return n, nil
+}
+
+func (c *TieredCache) Stats(ctx context.Context) (Stats, error) {
+	stats, err := c.remote.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	local := newTierStats(c.localHits.Load(), c.localMisses.Load())
+	stats.Local = &local
+	// A request only reaches Redis after missing locally, so overall hits
+	// are the sum of both tiers' hits over all local lookups.
+	stats.HitRatio = ratio(local.Hits+stats.Redis.Hits, local.Hits+local.Misses)
+	return stats, nil
+}
+
+func (c *TieredCache) Track(ctx context.Context, search models.FlightSearch) error {
+	return c.remote.Track(ctx, search)
+}
+
+func (c *TieredCache) Popular(ctx context.Context, n int) ([]PopularSearch, error) {
+	return c.remote.Popular(ctx, n)
+}
+
+// Close stops listening for invalidations.
+func (c *TieredCache) Close() {
+	c.cancel()
+}
+
+// localExpiry bounds the local copy by both the local TTL and the entry's
+// own stale deadline, so the local tier never outlives Redis.
+func (c *TieredCache) localExpiry(entry Entry) time.Time {
+	expiry := time.Now().Add(c.localTTL)
+	if entry.StaleUntil.Before(expiry) {
+		return entry.StaleUntil
+	}
+	return expiry
+}
+
+func (c *TieredCache) publish(ctx context.Context, key string) {
+	if c.remote.breaker.allow() != nil {
+		return
+	}
+	msg, _ := json.Marshal(invalidation{Key: key, Origin: c.nodeID})
+	if err := c.remote.client.Publish(ctx, InvalidationChannel, msg).Err(); err != nil {
+		log.Printf("cache invalidation publish %s error: %v", key, err)
+	}
+}
+
+func (c *TieredCache) listen(ctx context.Context) {
+	sub := c.remote.client.Subscribe(ctx, InvalidationChannel)
+	defer sub.Close()
+
+	for msg := range sub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			log.Printf("cache invalidation decode error: %v", err)
+			continue
+		}
+		switch {
+		case inv.Origin == c.nodeID:
+		case inv.Key == flushAll:
+			c.local.clear()
+		default:
+			c.local.remove(inv.Key)
+		}
+	}
+}
+
+func nodeID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("node-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// lru is a size-bounded, expiring, concurrency-safe LRU of cache entries.
+type lru struct {
+	max   int
+	order *list.List
+	items map[string]*list.Element
+	mu    sync.Mutex
+}
+
+type lruItem struct {
+	key     string
+	entry   Entry
+	expires time.Time
+}
+
+func newLRU(max int) *lru {
+	return &lru{max: max, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *lru) get(key string, now time.Time) (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	item := el.Value.(*lruItem)
+	if !now.Before(item.expires) {
+		l.order.Remove(el)
+		delete(l.items, key)
+		return Entry{}, false
+	}
+	l.order.MoveToFront(el)
+	return item.entry, true
+}
+
+func (l *lru) put(key string, entry Entry, expires time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		el.Value = &lruItem{key: key, entry: entry, expires: expires}
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruItem{key: key, entry: entry, expires: expires})
+	for l.order.Len() > l.max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+func (l *lru) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		l.order.Remove(el)
+		delete(l.items, key)
+	}
+}
+
+func (l *lru) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.order.Init()
+	clear(l.items)
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestTieredCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	newReplica := func() *TieredCache {
+		c := NewTieredCache(NewFlightCache(mr.Addr(), "", 0, time.Minute, time.Minute), 10, time.Minute)
+		t.Cleanup(c.Close)
+		return c
+	}
+	a, b := newReplica(), newReplica()
+	time.Sleep(50 * time.Millisecond) // let both subscriptions start
+
+	first := []models.FlightOffer{{Provider: "MockAir", Price: 80}}
+	if err := a.Set(ctx, "k", first, 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	// Peeking neither counts nor fills the local tier, so the Get below
+	// still misses locally.
+	if _, found, _ := b.Peek(ctx, "k"); !found {
+		t.Fatal("expected replica b to peek the entry in redis")
+	}
+	if _, found, _ := b.Get(ctx, "k"); !found {
+		t.Fatal("expected replica b to find the entry in redis")
+	}
+	if _, found, _ := b.Get(ctx, "k"); !found {
+		t.Fatal("expected replica b to find the entry locally")
+	}
+	got, err := b.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if got.Local == nil || got.Local.Hits != 1 || got.Local.Misses != 1 || got.Redis.Hits != 1 || got.HitRatio != 1 {
+		t.Errorf("unexpected stats on b: %+v", got)
+	}
+
+	second := []models.FlightOffer{{Provider: "MockAir", Price: 95}}
+	if err := a.Set(ctx, "k", second, 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		entry, _, _ := b.Get(ctx, "k")
+		if len(entry.Offers) == 1 && entry.Offers[0].Price == 95 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected replica b to be invalidated, still serving %+v", entry.Offers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLRUEvictsOldest(t *testing.T) {
+	l := newLRU(2)
+	now := time.Now()
+	l.put("a", Entry{}, now.Add(time.Minute))
+	l.put("b", Entry{}, now.Add(time.Minute))
+	l.get("a", now)
+	l.put("c", Entry{}, now.Add(time.Minute))
+
+	if _, ok := l.get("b", now); ok {
+		t.Error("expected least recently used key b to be evicted")
+	}
+	if _, ok := l.get("a", now); !ok {
+		t.Error("expected recently used key a to be kept")
+	}
+	if _, ok := l.get("c", now.Add(2*time.Minute)); ok {
+		t.Error("expected expired key c to be dropped")
+	}
+}
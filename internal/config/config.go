@@ -32,3 +32,23 @@ func GetEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// GetEnvFloat reads an environment variable into a float or returns the fallback value.
+func GetEnvFloat(key string, fallback float64) float64 {
+	if v, ok := os.LookupEnv(key); ok {
+		if fv, err := strconv.ParseFloat(v, 64); err == nil {
+			return fv
+		}
+	}
+	return fallback
+}
+
+// GetEnvBool reads an environment variable into a bool or returns the fallback value.
+func GetEnvBool(key string, fallback bool) bool {
+	if v, ok := os.LookupEnv(key); ok {
+		if bv, err := strconv.ParseBool(v); err == nil {
+			return bv
+		}
+	}
+	return fallback
+}
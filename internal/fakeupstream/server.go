@@ -0,0 +1,190 @@
+package fakeupstream
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/pkg/models"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+const dateLayout = "2006-01-02"
+
+// NewRouter returns a handler impersonating the Amadeus, SerpAPI and PriceLine
+// endpoints used by the provider clients. All three share one listener, so
+// every *_BASE_URL can point at the same address.
+func NewRouter() *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/v1/security/oauth2/token", amadeusToken).Methods(http.MethodPost)
+	r.HandleFunc("/v2/shopping/flight-offers", amadeusFlightOffers).Methods(http.MethodGet)
+	r.HandleFunc("/search.json", serpAPISearch).Methods(http.MethodGet)
+	r.HandleFunc("/flights/search-one-way", priceLineSearch).Methods(http.MethodGet)
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"status": "OK", "service": "fake-upstreams"})
+	}).Methods(http.MethodGet)
+
+	return r
+}
+
+func amadeusToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("client_id") == "" || r.PostForm.Get("client_secret") == "" {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]any{
+		"type":         "amadeusOAuth2Token",
+		"token_type":   "Bearer",
+		"access_token": "fake-" + randomHex(12),
+		"expires_in":   1799,
+		"state":        "approved",
+	})
+}
+
+func amadeusFlightOffers(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]any{"errors": []map[string]any{{"status": 401, "title": "Invalid access token"}}})
+		return
+	}
+	q := r.URL.Query()
+	origin, destination, date, err := route(q.Get("originLocationCode"), q.Get("destinationLocationCode"), q.Get("departureDate"))
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]any{"errors": []map[string]any{{"status": 400, "title": err.Error()}}})
+		return
+	}
+
+	its := generate("amadeus", origin, destination, date)
+	if max, err := strconv.Atoi(q.Get("max")); err == nil && max > 0 && max < len(its) {
+		its = its[:max]
+	}
+	var returns []itinerary
+	if ret, err := time.Parse(dateLayout, q.Get("returnDate")); err == nil {
+		returns = generate("amadeus", destination, origin, ret)
+	}
+
+	resp := models.AmadeusFlightResponse{Data: make([]models.AmadeusFlightOffer, 0, len(its))}
+	for i, it := range its {
+		offer := models.AmadeusFlightOffer{
+			Itineraries: []models.AmadeusItinerary{amadeusItinerary(it)},
+			Price:       models.AmadeusPrice{Total: fmt.Sprintf("%.2f", it.Price)},
+		}
+		if len(returns) > 0 {
+			ret := returns[i%len(returns)]
+			offer.Itineraries = append(offer.Itineraries, amadeusItinerary(ret))
+			offer.Price.Total = fmt.Sprintf("%.2f", it.Price+ret.Price)
+		}
+		resp.Data = append(resp.Data, offer)
+	}
+	utils.RespondJSON(w, http.StatusOK, resp)
+}
+
+func amadeusItinerary(it itinerary) models.AmadeusItinerary {
+	out := models.AmadeusItinerary{Duration: isoDuration(it.Duration)}
+	for _, l := range it.Legs {
+		out.Segments = append(out.Segments, models.AmadeusSegment{
+			Departure: models.AmadeusLocation{IataCode: l.From, At: l.Depart.Format("2006-01-02T15:04:05")},
+			Arrival:   models.AmadeusLocation{IataCode: l.To, At: l.Arrive.Format("2006-01-02T15:04:05")},
+			Duration:  isoDuration(l.Duration),
+		})
+	}
+	return out
+}
+
+func serpAPISearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("api_key") == "" {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"error": "Invalid API key. Your API key should be here: https://serpapi.com/manage-api-key"})
+		return
+	}
+	if q.Get("engine") != "google_flights" {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": "Unsupported engine"})
+		return
+	}
+	origin, destination, date, err := route(q.Get("departure_id"), q.Get("arrival_id"), q.Get("outbound_date"))
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	its := generate("serpapi", origin, destination, date)
+	resp := models.SerAPIResponse{BestFlights: make([]models.FlightOption, 0, len(its))}
+	for _, it := range its {
+		opt := models.FlightOption{TotalDuration: int(it.Duration.Minutes()), Price: int(it.Price)}
+		for _, l := range it.Legs {
+			opt.Flights = append(opt.Flights, models.FlightSegment{
+				DepartureAirport: models.AirportInfo{ID: l.From, Time: l.Depart.Format("2006-01-02 15:04")},
+				ArrivalAirport:   models.AirportInfo{ID: l.To, Time: l.Arrive.Format("2006-01-02 15:04")},
+				Duration:         int(l.Duration.Minutes()),
+			})
+		}
+		resp.BestFlights = append(resp.BestFlights, opt)
+	}
+	utils.RespondJSON(w, http.StatusOK, resp)
+}
+
+func priceLineSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("x-rapidapi-key") == "" {
+		utils.RespondJSON(w, http.StatusUnauthorized, map[string]string{"message": "Invalid API key. Go to https://docs.rapidapi.com/docs/keys for more info."})
+		return
+	}
+	q := r.URL.Query()
+	origin, destination, date, err := route(q.Get("originAirportCode"), q.Get("destinationAirportCode"), q.Get("departureDate"))
+	if err != nil {
+		utils.RespondJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	its := generate("priceline", origin, destination, date)
+	resp := models.PriceLineAPIResponse{Data: models.PriceLineData{Listings: make([]models.PriceLineListing, 0, len(its))}}
+	for _, it := range its {
+		slice := models.PriceLineSlice{DurationInMinutes: strconv.Itoa(int(it.Duration.Minutes()))}
+		for _, l := range it.Legs {
+			slice.Segments = append(slice.Segments, models.PriceLineSegment{
+				DepartInfo: models.DepartInfo{
+					Airport: models.PriceLineAirport{Code: l.From},
+					Time:    models.PriceLineTime{DateTime: l.Depart.Format("2006-01-02T15:04:05")},
+				},
+				ArrivalInfo: models.PriceLineArrivalInfo{Airport: models.PriceLineAirport{Code: l.To}},
+			})
+		}
+		resp.Data.Listings = append(resp.Data.Listings, models.PriceLineListing{
+			TotalPriceWithDecimal: models.TotalPriceWithDecimal{Price: it.Price},
+			Slices:                []models.PriceLineSlice{slice},
+			Airlines:              []models.PriceLineAirline{{Name: it.Legs[0].Carrier}},
+		})
+	}
+	utils.RespondJSON(w, http.StatusOK, resp)
+}
+
+// route validates the route parameters shared by all three APIs.
+func route(origin, destination, dateStr string) (string, string, time.Time, error) {
+	if len(origin) != 3 || len(destination) != 3 {
+		return "", "", time.Time{}, fmt.Errorf("origin and destination must be IATA codes")
+	}
+	date, err := time.Parse(dateLayout, dateStr)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("invalid date %q", dateStr)
+	}
+	return strings.ToUpper(origin), strings.ToUpper(destination), date, nil
+}
+
+func isoDuration(d time.Duration) string {
+	minutes := int(d.Minutes())
+	return fmt.Sprintf("PT%dH%dM", minutes/60, minutes%60)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,66 @@
+package fakeupstream
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/providers"
+	"github.com/fehepe/flight-price-service/internal/providers/amadeus"
+	"github.com/fehepe/flight-price-service/internal/providers/priceline"
+	"github.com/fehepe/flight-price-service/internal/providers/serpapi"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestClientsAgainstFakeUpstreams(t *testing.T) {
+	srv := httptest.NewServer(NewRouter())
+	defer srv.Close()
+
+	search := models.FlightSearch{
+		Origin:        "JFK",
+		Destination:   "LHR",
+		DepartureDate: time.Now().AddDate(0, 0, 14).Truncate(24 * time.Hour),
+	}
+
+	clients := []providers.Provider{
+		amadeus.New("key", "secret", srv.URL, "5", srv.Client()),
+		serpapi.New("key", srv.URL, srv.Client()),
+		priceline.New("key", srv.URL, srv.Client()),
+	}
+
+	for _, c := range clients {
+		t.Run(providers.NameOf(c), func(t *testing.T) {
+			offers, err := c.GetFlights(context.Background(), search)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(offers) == 0 {
+				t.Fatal("expected generated offers, got none")
+			}
+			for _, o := range offers {
+				if o.Origin != "JFK" || o.Price <= 0 || o.Duration == "" || o.Date != search.DepartureDate.Format("2006-01-02") {
+					t.Errorf("unexpected offer %+v", o)
+				}
+			}
+
+			again, _ := c.GetFlights(context.Background(), search)
+			if len(again) != len(offers) || again[0] != offers[0] {
+				t.Errorf("expected deterministic results for the same search")
+			}
+		})
+	}
+}
+
+func TestFakeUpstreamsRequireCredentials(t *testing.T) {
+	srv := httptest.NewServer(NewRouter())
+	defer srv.Close()
+
+	search := models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: time.Now().AddDate(0, 0, 1)}
+	if _, err := serpapi.New("", srv.URL, srv.Client()).GetFlights(context.Background(), search); err == nil {
+		t.Error("expected SerpAPI without api_key to fail")
+	}
+	if _, err := priceline.New("", srv.URL, srv.Client()).GetFlights(context.Background(), search); err == nil {
+		t.Error("expected PriceLine without key to fail")
+	}
+}
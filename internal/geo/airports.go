@@ -0,0 +1,78 @@
+package geo
+
+// Region groups countries into the broad markets used for provider routing.
+type Region string
+
+const (
+	NorthAmerica Region = "north_america"
+	LatinAmerica Region = "latin_america"
+	Europe       Region = "europe"
+	MiddleEast   Region = "middle_east"
+	Africa       Region = "africa"
+	AsiaPacific  Region = "asia_pacific"
+)
+
+// Airport holds the location metadata for an IATA airport code.
+type Airport struct {
+	Code    string `json:"code"`
+	Country string `json:"country"`
+	Region  Region `json:"region"`
+}
+
+// Lookup returns the metadata for the given IATA code.
+func Lookup(code string) (Airport, bool) {
+	country, ok := airportCountries[code]
+	if !ok {
+		return Airport{}, false
+	}
+	return Airport{Code: code, Country: country, Region: countryRegions[country]}, true
+}
+
+// RegionOf returns the region of the given ISO country code, or "" if unknown.
+func RegionOf(country string) Region {
+	return countryRegions[country]
+}
+
+var airportCountries = map[string]string{
+	// North America
+	"ATL": "US", "BOS": "US", "DCA": "US", "DEN": "US", "DFW": "US", "DTW": "US",
+	"EWR": "US", "HNL": "US", "IAD": "US", "IAH": "US", "JFK": "US", "LAS": "US",
+	"LAX": "US", "LGA": "US", "MCO": "US", "MIA": "US", "MSP": "US", "ORD": "US",
+	"PHL": "US", "PHX": "US", "SAN": "US", "SEA": "US", "SFO": "US", "SLC": "US",
+	"YUL": "CA", "YVR": "CA", "YYC": "CA", "YYZ": "CA",
+	// Latin America
+	"BOG": "CO", "CUN": "MX", "EZE": "AR", "GIG": "BR", "GRU": "BR", "LIM": "PE",
+	"MEX": "MX", "PTY": "PA", "SCL": "CL", "SDQ": "DO", "SJU": "PR", "STI": "DO",
+	// Europe
+	"AMS": "NL", "ARN": "SE", "ATH": "GR", "BCN": "ES", "BRU": "BE", "CDG": "FR",
+	"CPH": "DK", "DUB": "IE", "FCO": "IT", "FRA": "DE", "HEL": "FI", "IST": "TR",
+	"LGW": "GB", "LHR": "GB", "LIS": "PT", "MAD": "ES", "MAN": "GB", "MUC": "DE",
+	"MXP": "IT", "ORY": "FR", "OSL": "NO", "PRG": "CZ", "VIE": "AT", "WAW": "PL",
+	"ZRH": "CH",
+	// Middle East
+	"AUH": "AE", "DOH": "QA", "DXB": "AE", "JED": "SA", "RUH": "SA", "TLV": "IL",
+	// Africa
+	"ADD": "ET", "CAI": "EG", "CMN": "MA", "JNB": "ZA", "LOS": "NG", "NBO": "KE",
+	// Asia-Pacific
+	"AKL": "NZ", "BKK": "TH", "BNE": "AU", "CGK": "ID", "DEL": "IN", "HKG": "HK",
+	"HND": "JP", "ICN": "KR", "KIX": "JP", "KUL": "MY", "MEL": "AU", "MNL": "PH",
+	"NRT": "JP", "PEK": "CN", "PVG": "CN", "SGN": "VN", "SIN": "SG", "SYD": "AU",
+	"TPE": "TW", "BOM": "IN",
+}
+
+var countryRegions = map[string]Region{
+	"US": NorthAmerica, "CA": NorthAmerica,
+	"AR": LatinAmerica, "BR": LatinAmerica, "CL": LatinAmerica, "CO": LatinAmerica,
+	"DO": LatinAmerica, "MX": LatinAmerica, "PA": LatinAmerica, "PE": LatinAmerica,
+	"PR": LatinAmerica,
+	"AT": Europe, "BE": Europe, "CH": Europe, "CZ": Europe, "DE": Europe, "DK": Europe,
+	"ES": Europe, "FI": Europe, "FR": Europe, "GB": Europe, "GR": Europe, "IE": Europe,
+	"IT": Europe, "NL": Europe, "NO": Europe, "PL": Europe, "PT": Europe, "SE": Europe,
+	"TR": Europe,
+	"AE": MiddleEast, "IL": MiddleEast, "QA": MiddleEast, "SA": MiddleEast,
+	"EG": Africa, "ET": Africa, "KE": Africa, "MA": Africa, "NG": Africa, "ZA": Africa,
+	"AU": AsiaPacific, "CN": AsiaPacific, "HK": AsiaPacific, "ID": AsiaPacific,
+	"IN": AsiaPacific, "JP": AsiaPacific, "KR": AsiaPacific, "MY": AsiaPacific,
+	"NZ": AsiaPacific, "PH": AsiaPacific, "SG": AsiaPacific, "TH": AsiaPacific,
+	"TW": AsiaPacific, "VN": AsiaPacific,
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/internal/middleware"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// APIKeyHandler serves the API key administration endpoints.
+type APIKeyHandler struct {
+	keys *auth.APIKeys
+}
+
+func NewAPIKeyHandler(keys *auth.APIKeys) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// CreateAPIKeyRequest is the payload of POST /admin/api-keys.
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+	// Tenant assigns the key to a tenant. Only callers outside any tenant may
+	// set it; keys created by a tenant's caller always belong to that tenant.
+	Tenant string `json:"tenant"`
+}
+
+// APIKeyResponse describes a key without its secret. Key is only set in
+// the response to its creation.
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(k auth.APIKey, now time.Time) APIKeyResponse {
+	optional := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	return APIKeyResponse{
+		ID:         k.ID,
+		Prefix:     k.Prefix(),
+		Name:       k.Name,
+		Owner:      k.Owner,
+		Scopes:     k.Scopes,
+		Tenant:     k.Tenant,
+		Active:     k.Active(now),
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  optional(k.ExpiresAt),
+		LastUsedAt: optional(k.LastUsedAt),
+		RevokedAt:  optional(k.RevokedAt),
+	}
+}
+
+// List returns every key, or only its tenant's keys to a tenant's caller.
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.List(r.Context())
+	if err != nil {
+		respondAPIKeyError(w, r, err)
+		return
+	}
+	now := time.Now()
+	tenant := callerTenant(r)
+	resp := make([]APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		if tenant == "" || k.Tenant == tenant {
+			resp = append(resp, newAPIKeyResponse(k, now))
+		}
+	}
+	utils.RespondJSON(w, http.StatusOK, resp)
+}
+
+// Create issues a key owned by the caller. The key is returned only here.
+// API keys cannot create keys, so a leaked key cannot outlive itself by
+// minting a successor without an expiry. OIDC callers cannot either: a key
+// must be owned by a local user so that disabling the user stops it.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	claims, _ := middleware.FromContext(r.Context())
+	if claims != nil && claims.IsAPIKey() {
+		utils.RespondError(w, http.StatusForbidden, "API keys cannot create API keys")
+		return
+	}
+	if claims != nil && claims.Method == auth.AuthOIDC {
+		utils.RespondError(w, http.StatusForbidden, "API keys must be created by a local user")
+		return
+	}
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.RespondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	scopes := normalizeScopes(req.Scopes)
+	if len(scopes) == 0 {
+		utils.RespondError(w, http.StatusBadRequest, "at least one scope is required")
+		return
+	}
+	for _, scope := range scopes {
+		if !auth.KnownScope(scope) {
+			utils.RespondError(w, http.StatusBadRequest, "unknown scope "+scope)
+			return
+		}
+		if claims != nil && !claims.HasScope(scope) {
+			utils.RespondError(w, http.StatusForbidden, "cannot grant scope "+scope+" you do not hold")
+			return
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		utils.RespondError(w, http.StatusBadRequest, "expires_in_days must not be negative")
+		return
+	}
+
+	var owner string
+	tenant := strings.TrimSpace(req.Tenant)
+	if claims != nil {
+		owner = claims.Subject
+		if claims.Tenant != "" {
+			if tenant != "" && tenant != claims.Tenant {
+				utils.RespondError(w, http.StatusForbidden, "cannot create keys for another tenant")
+				return
+			}
+			tenant = claims.Tenant
+		}
+	}
+	raw, key, err := h.keys.Create(r.Context(), req.Name, owner, tenant, scopes, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		respondAPIKeyError(w, r, err)
+		return
+	}
+	resp := newAPIKeyResponse(key, time.Now())
+	resp.Key = raw
+	utils.RespondJSON(w, http.StatusCreated, resp)
+}
+
+// Revoke disables a key immediately. The record is kept for auditing. A
+// tenant's caller can only revoke its tenant's keys; others look unknown.
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if tenant := callerTenant(r); tenant != "" {
+		key, err := h.keys.Get(r.Context(), id)
+		if err == nil && key.Tenant != tenant {
+			err = auth.ErrAPIKeyNotFound
+		}
+		if err != nil {
+			respondAPIKeyError(w, r, err)
+			return
+		}
+	}
+	if err := h.keys.Revoke(r.Context(), id); err != nil {
+		respondAPIKeyError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalizeScopes trims, drops empty and duplicate values, keeping order.
+func normalizeScopes(scopes []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func respondAPIKeyError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		utils.RespondError(w, http.StatusNotFound, "api key not found")
+		return
+	}
+	log.Printf("%s %s api key store error: %v\n", r.Method, r.RequestURI, err)
+	utils.RespondError(w, http.StatusInternalServerError, "api key store error")
+}
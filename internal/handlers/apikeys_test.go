@@ -0,0 +1,249 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/internal/handlers"
+	"github.com/fehepe/flight-price-service/internal/middleware"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+func TestAPIKeyHandler(t *testing.T) {
+	store, err := auth.NewFileKeyStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := auth.NewAPIKeys(store)
+	kh := handlers.NewAPIKeyHandler(keys)
+	r := mux.NewRouter()
+	r.HandleFunc("/admin/api-keys", kh.List).Methods(http.MethodGet)
+	r.HandleFunc("/admin/api-keys", kh.Create).Methods(http.MethodPost)
+	r.HandleFunc("/admin/api-keys/{id}", kh.Revoke).Methods(http.MethodDelete)
+
+	local, _ := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	search := middleware.Auth(local, middleware.WithAPIKeys(keys))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := middleware.FromContext(r.Context())
+		json.NewEncoder(w).Encode(claims.Scopes)
+	}))
+	searchWith := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/flights/search", nil)
+		req.Header.Set(middleware.APIKeyHeader, key)
+		rr := httptest.NewRecorder()
+		search.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for body, want := range map[string]int{
+		`{"name":"job","scopes":["flights:delete"]}`:                      http.StatusBadRequest,
+		`{"name":"","scopes":["flights:search"]}`:                         http.StatusBadRequest,
+		`{"name":"job","scopes":[" "]}`:                                   http.StatusBadRequest,
+		`{"name":"job","scopes":["flights:search"],"expires_in_days":-1}`: http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(body)))
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", body, want, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/api-keys",
+		bytes.NewBufferString(`{"name":"nightly","scopes":["flights:search","flights:search"],"expires_in_days":30}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	var created handlers.APIKeyResponse
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.Key == "" || len(created.Scopes) != 1 || created.ExpiresAt == nil {
+		t.Fatalf("unexpected key %+v", created)
+	}
+
+	if code := searchWith(created.Key); code != http.StatusOK {
+		t.Errorf("expected the new key to be accepted, got %d", code)
+	}
+	if code := searchWith(created.Key + "x"); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong key to be refused, got %d", code)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil))
+	var listed []handlers.APIKeyResponse
+	json.NewDecoder(rr.Body).Decode(&listed)
+	if len(listed) != 1 || listed[0].Key != "" || listed[0].LastUsedAt == nil || !listed[0].Active {
+		t.Errorf("expected one active, used key without its secret, got %+v", listed)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/api-keys/"+created.ID, nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rr.Code)
+	}
+	if code := searchWith(created.Key); code != http.StatusUnauthorized {
+		t.Errorf("expected the revoked key to be refused, got %d", code)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/api-keys/000000000000", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown key, got %d", rr.Code)
+	}
+}
+
+func TestAPIKeyHandler_CannotGrantUnheldScopes(t *testing.T) {
+	store, _ := auth.NewFileKeyStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	kh := handlers.NewAPIKeyHandler(auth.NewAPIKeys(store))
+
+	local, _ := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	operator, _, _ := auth.NewTokenIssuer(local, "test", time.Hour).Issue(auth.User{Username: "ops", Roles: []string{auth.RoleOperator}}, "sid")
+	create := middleware.Auth(local)(http.HandlerFunc(kh.Create))
+
+	for scope, want := range map[string]int{
+		auth.ScopeCacheRead:   http.StatusCreated,
+		auth.ScopeUsersManage: http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(`{"name":"job","scopes":["`+scope+`"]}`))
+		req.Header.Set("Authorization", "Bearer "+operator)
+		rr := httptest.NewRecorder()
+		create.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", scope, want, rr.Code)
+		}
+	}
+}
+
+func TestAPIKeyHandler_APIKeysCannotCreateKeys(t *testing.T) {
+	store, _ := auth.NewFileKeyStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	keys := auth.NewAPIKeys(store)
+	kh := handlers.NewAPIKeyHandler(keys)
+	raw, _, err := keys.Create(context.Background(), "admin job", "", "", []string{auth.ScopeAPIKeysManage}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, _ := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	create := middleware.Auth(local, middleware.WithAPIKeys(keys))(http.HandlerFunc(kh.Create))
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(`{"name":"successor","scopes":["api-keys:manage"]}`))
+	req.Header.Set(middleware.APIKeyHeader, raw)
+	rr := httptest.NewRecorder()
+	create.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected an API key to be refused creating keys, got %d", rr.Code)
+	}
+	if list, _ := keys.List(context.Background()); len(list) != 1 {
+		t.Errorf("expected no new key, got %d keys", len(list))
+	}
+}
+
+// oidcVerifier accepts any bearer token as an OIDC caller holding every scope.
+type oidcVerifier struct{}
+
+func (oidcVerifier) Verify(context.Context, string) (*auth.Claims, error) {
+	return &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "alice@example.com", Issuer: "https://sso.example.com"},
+		Roles:            []string{auth.RoleAdmin},
+		Scopes:           auth.ScopesForRoles([]string{auth.RoleAdmin}),
+		Method:           auth.AuthOIDC,
+	}, nil
+}
+
+func TestAPIKeyHandler_OIDCCallersCannotCreateKeys(t *testing.T) {
+	store, _ := auth.NewFileKeyStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	keys := auth.NewAPIKeys(store)
+	create := middleware.Auth(oidcVerifier{})(http.HandlerFunc(handlers.NewAPIKeyHandler(keys).Create))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(`{"name":"job","scopes":["flights:search"]}`))
+	req.Header.Set("Authorization", "Bearer sso-token")
+	rr := httptest.NewRecorder()
+	create.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected an OIDC caller to be refused creating keys, got %d", rr.Code)
+	}
+	if list, _ := keys.List(context.Background()); len(list) != 0 {
+		t.Errorf("expected no key, got %d keys", len(list))
+	}
+}
+
+func TestAPIKeyHandler_KeysInheritTenant(t *testing.T) {
+	store, _ := auth.NewFileKeyStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	kh := handlers.NewAPIKeyHandler(auth.NewAPIKeys(store))
+
+	local, _ := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	partner, _, _ := auth.NewTokenIssuer(local, "test", time.Hour).Issue(auth.User{Username: "ops", Roles: []string{auth.RoleAdmin}, Tenant: "acme"}, "sid")
+	create := middleware.Auth(local)(http.HandlerFunc(kh.Create))
+
+	tests := []struct {
+		body       string
+		want       int
+		wantTenant string
+	}{
+		{`{"name":"job","scopes":["flights:search"]}`, http.StatusCreated, "acme"},
+		{`{"name":"job","scopes":["flights:search"],"tenant":"acme"}`, http.StatusCreated, "acme"},
+		{`{"name":"job","scopes":["flights:search"],"tenant":"globex"}`, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(tt.body))
+		req.Header.Set("Authorization", "Bearer "+partner)
+		rr := httptest.NewRecorder()
+		create.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.body, tt.want, rr.Code)
+			continue
+		}
+		var created handlers.APIKeyResponse
+		json.NewDecoder(rr.Body).Decode(&created)
+		if created.Tenant != tt.wantTenant {
+			t.Errorf("%s: expected tenant %q, got %q", tt.body, tt.wantTenant, created.Tenant)
+		}
+	}
+}
+
+func TestAPIKeyHandler_TenantScoping(t *testing.T) {
+	store, _ := auth.NewFileKeyStore(filepath.Join(t.TempDir(), "api-keys.json"))
+	keys := auth.NewAPIKeys(store)
+	kh := handlers.NewAPIKeyHandler(keys)
+	local, _ := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	r := mux.NewRouter()
+	r.Use(middleware.Auth(local))
+	r.HandleFunc("/admin/api-keys", kh.List).Methods(http.MethodGet)
+	r.HandleFunc("/admin/api-keys/{id}", kh.Revoke).Methods(http.MethodDelete)
+
+	ctx := context.Background()
+	_, global, _ := keys.Create(ctx, "global", "root", "", []string{auth.ScopeFlightsSearch}, 0)
+	_, globex, _ := keys.Create(ctx, "globex", "ops", "globex", []string{auth.ScopeFlightsSearch}, 0)
+	_, acme, _ := keys.Create(ctx, "acme", "ops", "acme", []string{auth.ScopeFlightsSearch}, 0)
+
+	partner, _, _ := auth.NewTokenIssuer(local, "test", time.Hour).Issue(auth.User{Username: "ops", Roles: []string{auth.RoleAdmin}, Tenant: "acme"}, "sid")
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+partner)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var listed []handlers.APIKeyResponse
+	json.NewDecoder(do(http.MethodGet, "/admin/api-keys").Body).Decode(&listed)
+	if len(listed) != 1 || listed[0].ID != acme.ID {
+		t.Errorf("expected only the tenant's key, got %+v", listed)
+	}
+	for _, other := range []auth.APIKey{global, globex} {
+		if rr := do(http.MethodDelete, "/admin/api-keys/"+other.ID); rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected another tenant's key to look unknown, got %d", other.Name, rr.Code)
+		}
+	}
+	if rr := do(http.MethodDelete, "/admin/api-keys/"+acme.ID); rr.Code != http.StatusNoContent {
+		t.Errorf("expected the tenant's own key to be revoked, got %d", rr.Code)
+	}
+	if k, _ := keys.Get(ctx, global.ID); !k.RevokedAt.IsZero() {
+		t.Error("expected the global key to stay active")
+	}
+}
@@ -3,20 +3,67 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/fehepe/flight-price-service/internal/config"
+	"github.com/fehepe/flight-price-service/internal/audit"
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/internal/middleware"
 	"github.com/fehepe/flight-price-service/pkg/models"
 	"github.com/fehepe/flight-price-service/pkg/utils"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-// GenerateToken validates credentials and issues a JWT.
-func GenerateToken(w http.ResponseWriter, r *http.Request) {
+// AuthHandler issues, refreshes and revokes tokens for users in the user store.
+type AuthHandler struct {
+	auth     *auth.Authenticator
+	sessions *auth.Sessions
+	throttle *auth.LoginThrottle
+	audit    *audit.Logger
+	// trustForwarded takes client IPs from X-Forwarded-For.
+	trustForwarded bool
+}
+
+// AuthHandlerOption configures optional AuthHandler dependencies.
+type AuthHandlerOption func(*AuthHandler)
+
+// WithLoginThrottle locks out IPs and usernames after repeated failed logins.
+func WithLoginThrottle(t *auth.LoginThrottle) AuthHandlerOption {
+	return func(h *AuthHandler) {
+		h.throttle = t
+	}
+}
+
+// WithAudit records logins, refreshes and logouts.
+func WithAudit(l *audit.Logger) AuthHandlerOption {
+	return func(h *AuthHandler) {
+		h.audit = l
+	}
+}
+
+// WithTrustedForwarding takes client IPs from X-Forwarded-For, which is only
+// safe behind a proxy that sets it.
+func WithTrustedForwarding(trust bool) AuthHandlerOption {
+	return func(h *AuthHandler) {
+		h.trustForwarded = trust
+	}
+}
+
+func NewAuthHandler(authenticator *auth.Authenticator, sessions *auth.Sessions, opts ...AuthHandlerOption) *AuthHandler {
+	h := &AuthHandler{auth: authenticator, sessions: sessions}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// GenerateToken validates credentials and issues a JWT with a refresh token.
+func (h *AuthHandler) GenerateToken(w http.ResponseWriter, r *http.Request) {
 	// Ensure JSON content
 	contentType := r.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "application/json") {
@@ -44,44 +91,161 @@ func GenerateToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Authenticate user (replace with real validation)
-	validUser := config.Get("AUTH_USERNAME", "user")
-	validPass := config.Get("AUTH_PASSWORD", "pass")
-	if req.Username != validUser || req.Password != validPass {
+	ip := middleware.ClientIP(r, h.trustForwarded)
+	if wait := h.throttled(r, ip, req.Username); wait > 0 {
+		h.record(r, audit.Event{Type: audit.TypeLoginThrottled, Outcome: audit.OutcomeDenied, Actor: req.Username, IP: ip})
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		utils.RespondError(w, http.StatusTooManyRequests, "too many failed logins; try again later")
+		return
+	}
+
+	// Authenticate user
+	user, err := h.auth.Authenticate(r.Context(), req.Username, req.Password)
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		h.fail(r, ip, req.Username, "invalid credentials")
 		utils.RespondError(w, http.StatusUnauthorized, "invalid username or password")
 		return
+	case errors.Is(err, auth.ErrAccountLocked):
+		h.fail(r, ip, req.Username, "account locked")
+		utils.RespondError(w, http.StatusLocked, "account temporarily locked after repeated failed logins")
+		return
+	case errors.Is(err, auth.ErrAccountDisabled):
+		h.record(r, audit.Event{Type: audit.TypeLoginFailed, Outcome: audit.OutcomeDenied, Actor: req.Username, IP: ip,
+			Details: map[string]string{"reason": "account disabled"}})
+		utils.RespondError(w, http.StatusForbidden, "account disabled")
+		return
+	case err != nil:
+		log.Printf("%s %s authenticate error: %v\n", r.Method, r.RequestURI, err)
+		utils.RespondError(w, http.StatusInternalServerError, "could not authenticate")
+		return
 	}
 
-	// Load JWT secret
-	secret := config.Get("JWT_SECRET", "")
-	if secret == "" {
-		utils.RespondError(w, http.StatusInternalServerError, "server configuration error: missing JWT_SECRET")
+	pair, err := h.sessions.Start(r.Context(), user)
+	if err != nil {
+		log.Printf("%s %s start session error: %v\n", r.Method, r.RequestURI, err)
+		utils.RespondError(w, http.StatusInternalServerError, "could not issue token")
 		return
 	}
+	if h.throttle != nil {
+		if err := h.throttle.Succeed(r.Context(), req.Username); err != nil {
+			log.Printf("%s %s login throttle error: %v\n", r.Method, r.RequestURI, err)
+		}
+	}
+	h.record(r, tokenEvent(audit.TypeTokenIssued, pair.Claims, ip))
+	utils.RespondJSON(w, http.StatusOK, newTokenResponse(pair))
+}
 
-	// Parse expiry
-	expiresIn, err := strconv.Atoi(config.Get("JWT_EXPIRY_HOURS", "1"))
-	if err != nil || expiresIn <= 0 {
-		expiresIn = 1
+// Refresh exchanges a refresh token for a new token pair. The refresh token
+// is single use; presenting it again revokes the whole session.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+	if strings.TrimSpace(req.RefreshToken) == "" {
+		utils.RespondError(w, http.StatusBadRequest, "refresh_token is required")
+		return
 	}
 
-	// Create claims
-	claims := jwt.RegisteredClaims{
-		Subject:   req.Username,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expiresIn) * time.Hour)),
-		Issuer:    config.Get("JWT_ISSUER", "flight-service"),
+	ip := middleware.ClientIP(r, h.trustForwarded)
+	pair, err := h.sessions.Refresh(r.Context(), req.RefreshToken)
+	switch {
+	case errors.Is(err, auth.ErrRefreshTokenReused):
+		log.Printf("%s %s refresh token reuse detected; session revoked\n", r.Method, r.RequestURI)
+		h.record(r, audit.Event{Type: audit.TypeRefreshReused, Outcome: audit.OutcomeDenied, IP: ip,
+			Details: map[string]string{"action": "session revoked"}})
+		utils.RespondError(w, http.StatusUnauthorized, "refresh token already used; session revoked")
+		return
+	case errors.Is(err, auth.ErrInvalidRefreshToken):
+		utils.RespondError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	case errors.Is(err, auth.ErrAccountDisabled):
+		utils.RespondError(w, http.StatusForbidden, "account disabled")
+		return
+	case err != nil:
+		log.Printf("%s %s refresh error: %v\n", r.Method, r.RequestURI, err)
+		utils.RespondError(w, http.StatusInternalServerError, "could not refresh token")
+		return
 	}
+	h.record(r, tokenEvent(audit.TypeTokenRefreshed, pair.Claims, ip))
+	utils.RespondJSON(w, http.StatusOK, newTokenResponse(pair))
+}
 
-	// Sign token
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := t.SignedString([]byte(secret))
-	if err != nil {
-		utils.RespondError(w, http.StatusInternalServerError, "could not sign token")
+// Logout revokes the caller's access token and every token of its session.
+// It must run behind middleware.Auth.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.FromContext(r.Context())
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "missing token claims")
+		return
+	}
+	if err := h.sessions.Logout(r.Context(), claims); err != nil {
+		log.Printf("%s %s logout error: %v\n", r.Method, r.RequestURI, err)
+		utils.RespondError(w, http.StatusInternalServerError, "could not revoke token")
 		return
 	}
+	h.record(r, tokenEvent(audit.TypeTokenRevoked, *claims, middleware.ClientIP(r, h.trustForwarded)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// throttled returns how long ip or username is locked out. When the
+// throttle cannot be checked logins go ahead; the per-account lockout in
+// the user store still applies.
+func (h *AuthHandler) throttled(r *http.Request, ip, username string) time.Duration {
+	if h.throttle == nil {
+		return 0
+	}
+	wait, err := h.throttle.Check(r.Context(), ip, username)
+	if err != nil {
+		log.Printf("%s %s login throttle unavailable, allowing: %v\n", r.Method, r.RequestURI, err)
+	}
+	return wait
+}
 
-	// Respond with token
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	utils.RespondJSON(w, http.StatusOK, models.TokenResponse{Token: signedToken})
+// fail counts a failed login against ip and username and records it.
+func (h *AuthHandler) fail(r *http.Request, ip, username, reason string) {
+	details := map[string]string{"reason": reason}
+	if h.throttle != nil {
+		wait, err := h.throttle.Fail(r.Context(), ip, username)
+		if err != nil {
+			log.Printf("%s %s login throttle error: %v\n", r.Method, r.RequestURI, err)
+		} else if wait > 0 {
+			details["locked_for"] = wait.String()
+		}
+	}
+	h.record(r, audit.Event{Type: audit.TypeLoginFailed, Outcome: audit.OutcomeFailure, Actor: username, IP: ip, Details: details})
+}
+
+func (h *AuthHandler) record(r *http.Request, e audit.Event) {
+	h.audit.Record(r.Context(), e)
+}
+
+// tokenEvent describes a successful token operation for claims.
+func tokenEvent(eventType string, claims auth.Claims, ip string) audit.Event {
+	details := map[string]string{}
+	if claims.ID != "" {
+		details["jti"] = claims.ID
+	}
+	if claims.SessionID != "" {
+		details["sid"] = claims.SessionID
+	}
+	return audit.Event{Type: eventType, Outcome: audit.OutcomeSuccess, Actor: claims.Subject, Tenant: claims.Tenant, IP: ip, Details: details}
+}
+
+func newTokenResponse(pair auth.TokenPair) models.TokenResponse {
+	return models.TokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    int64(pair.ExpiresIn.Seconds()),
+	}
+}
+
+// JWKS serves the public keys tokens can be verified with.
+func JWKS(keys *auth.KeySet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		utils.RespondJSON(w, http.StatusOK, keys.JWKS())
+	}
 }
@@ -2,37 +2,85 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fehepe/flight-price-service/internal/audit"
+	"github.com/fehepe/flight-price-service/internal/auth"
 	"github.com/fehepe/flight-price-service/internal/handlers"
+	"github.com/fehepe/flight-price-service/internal/middleware"
 	"github.com/fehepe/flight-price-service/pkg/models"
+	"github.com/redis/go-redis/v9"
 )
 
-func setupAuthEnv() {
-	os.Setenv("AUTH_USERNAME", "user")
-	os.Setenv("AUTH_PASSWORD", "pass")
-	os.Setenv("JWT_SECRET", "testsecret")
-	os.Setenv("JWT_EXPIRY_HOURS", "1")
-	os.Setenv("JWT_ISSUER", "test-service")
+// newAuthHandler returns a handler backed by a file store holding user/pass.
+func newAuthHandler(t *testing.T, opts ...auth.AuthenticatorOption) *handlers.AuthHandler {
+	return newAuthEnv(t, opts...).handler
 }
 
-func TestGenerateToken_ValidCredentials(t *testing.T) {
-	setupAuthEnv()
+// authEnv is an AuthHandler together with the session service, whose store
+// is on an in-process Redis, and the keys it signs with.
+type authEnv struct {
+	handler  *handlers.AuthHandler
+	authn    *auth.Authenticator
+	sessions *auth.Sessions
+	keys     *auth.KeySet
+	redis    *redis.Client
+}
 
-	payload := models.TokenRequest{
-		Username: "user",
-		Password: "pass",
+func newAuthEnv(t *testing.T, opts ...auth.AuthenticatorOption) authEnv {
+	t.Helper()
+	store, err := auth.NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := auth.PasswordHasher{Algorithm: auth.AlgorithmBcrypt, BcryptCost: 4}
+	if _, err := auth.Bootstrap(context.Background(), store, hasher, "user", "pass"); err != nil {
+		t.Fatal(err)
 	}
-	body, _ := json.Marshal(payload)
+	keys, err := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	sessions := auth.NewSessions(
+		auth.NewTokenIssuer(keys, "test-service", time.Hour),
+		auth.NewRedisSessionStore(client),
+		store,
+		24*time.Hour,
+	)
+	authn := auth.NewAuthenticator(store, hasher, opts...)
+	return authEnv{
+		handler:  handlers.NewAuthHandler(authn, sessions),
+		authn:    authn,
+		sessions: sessions,
+		keys:     keys,
+		redis:    client,
+	}
+}
+
+func tokenRequest(username, password string) *http.Request {
+	body, _ := json.Marshal(models.TokenRequest{Username: username, Password: password})
 	req := httptest.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
-	rr := httptest.NewRecorder()
+	return req
+}
+
+func TestGenerateToken_ValidCredentials(t *testing.T) {
+	h := newAuthHandler(t)
 
-	handlers.GenerateToken(rr, req)
+	rr := httptest.NewRecorder()
+	h.GenerateToken(rr, tokenRequest("user", "pass"))
 
 	if rr.Code != http.StatusOK {
 		t.Errorf("expected 200 OK, got %d", rr.Code)
@@ -49,20 +97,235 @@ func TestGenerateToken_ValidCredentials(t *testing.T) {
 }
 
 func TestGenerateToken_InvalidCredentials(t *testing.T) {
-	setupAuthEnv()
+	h := newAuthHandler(t)
+
+	rr := httptest.NewRecorder()
+	h.GenerateToken(rr, tokenRequest("invalid", "invalid"))
 
-	payload := models.TokenRequest{
-		Username: "invalid",
-		Password: "invalid",
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 Unauthorized, got %d", rr.Code)
 	}
-	body, _ := json.Marshal(payload)
-	req := httptest.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
+}
+
+func TestGenerateToken_LocksOutAfterRepeatedFailures(t *testing.T) {
+	h := newAuthHandler(t, auth.WithLockout(3, time.Minute))
+
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusLocked}
+	for i, code := range want {
+		rr := httptest.NewRecorder()
+		h.GenerateToken(rr, tokenRequest("user", "wrong"))
+		if rr.Code != code {
+			t.Errorf("attempt %d: expected %d, got %d", i+1, code, rr.Code)
+		}
+	}
+
 	rr := httptest.NewRecorder()
+	h.GenerateToken(rr, tokenRequest("user", "pass"))
+	if rr.Code != http.StatusLocked {
+		t.Errorf("expected the correct password to be refused while locked, got %d", rr.Code)
+	}
+}
 
-	handlers.GenerateToken(rr, req)
+func TestGenerateToken_ThrottlesByUsernameAndAudits(t *testing.T) {
+	env := newAuthEnv(t)
+	var log bytes.Buffer
+	logger, err := audit.NewLogger(audit.NewWriterSink(&log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	throttle := auth.NewLoginThrottle(env.redis,
+		auth.WithThrottleThresholds(100, 2),
+		auth.WithThrottleLockout(time.Minute, time.Hour, time.Hour),
+	)
+	h := handlers.NewAuthHandler(env.authn, env.sessions, handlers.WithLoginThrottle(throttle), handlers.WithAudit(logger))
+
+	want := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	for i, code := range want {
+		rr := httptest.NewRecorder()
+		h.GenerateToken(rr, tokenRequest("user", "wrong"))
+		if rr.Code != code {
+			t.Errorf("attempt %d: expected %d, got %d", i+1, code, rr.Code)
+		}
+	}
 
+	rr := httptest.NewRecorder()
+	h.GenerateToken(rr, tokenRequest("user", "pass"))
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("expected the correct password to be throttled with Retry-After, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	rr = httptest.NewRecorder()
+	h.GenerateToken(rr, tokenRequest("other", "wrong"))
 	if rr.Code != http.StatusUnauthorized {
-		t.Errorf("expected 401 Unauthorized, got %d", rr.Code)
+		t.Errorf("expected other usernames to be unaffected, got %d", rr.Code)
+	}
+
+	var types []string
+	dec := json.NewDecoder(bytes.NewReader(log.Bytes()))
+	for {
+		var e audit.Event
+		if err := dec.Decode(&e); err != nil {
+			break
+		}
+		types = append(types, e.Type)
+	}
+	wantTypes := []string{audit.TypeLoginFailed, audit.TypeLoginFailed, audit.TypeLoginThrottled, audit.TypeLoginThrottled, audit.TypeLoginFailed}
+	if !slices.Equal(types, wantTypes) {
+		t.Errorf("expected events %v, got %v", wantTypes, types)
+	}
+	if n, err := audit.Verify(&log, nil, audit.Anchor{}); err != nil || n != len(wantTypes) {
+		t.Errorf("expected an intact chain of %d events, got %d: %v", len(wantTypes), n, err)
+	}
+}
+
+func TestAuthHandler_AuditsTokenLifecycle(t *testing.T) {
+	env := newAuthEnv(t)
+	var log bytes.Buffer
+	logger, _ := audit.NewLogger(audit.NewWriterSink(&log))
+	h := handlers.NewAuthHandler(env.authn, env.sessions, handlers.WithAudit(logger))
+
+	first := login(t, h)
+	refresh(h, first.RefreshToken)
+	refresh(h, first.RefreshToken)
+
+	var events []audit.Event
+	dec := json.NewDecoder(&log)
+	for {
+		var e audit.Event
+		if err := dec.Decode(&e); err != nil {
+			break
+		}
+		events = append(events, e)
+	}
+	want := []string{audit.TypeTokenIssued, audit.TypeTokenRefreshed, audit.TypeRefreshReused}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, e := range events {
+		if e.Type != want[i] {
+			t.Errorf("event %d: expected %s, got %s", i, want[i], e.Type)
+		}
+	}
+	if events[0].Actor != "user" || events[0].Details["sid"] == "" || events[0].Details["sid"] != events[1].Details["sid"] {
+		t.Errorf("expected issue and refresh to name the user and session, got %+v", events[:2])
+	}
+	if events[2].Outcome != audit.OutcomeDenied {
+		t.Errorf("expected reuse to be denied, got %s", events[2].Outcome)
+	}
+}
+
+func login(t *testing.T, h *handlers.AuthHandler) models.TokenResponse {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h.GenerateToken(rr, tokenRequest("user", "pass"))
+	var resp models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.RefreshToken == "" {
+		t.Fatalf("login failed: %d %v", rr.Code, err)
+	}
+	return resp
+}
+
+func refresh(h *handlers.AuthHandler, token string) (*httptest.ResponseRecorder, models.TokenResponse) {
+	body, _ := json.Marshal(models.RefreshRequest{RefreshToken: token})
+	rr := httptest.NewRecorder()
+	h.Refresh(rr, httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBuffer(body)))
+	var resp models.TokenResponse
+	json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&resp)
+	return rr, resp
+}
+
+// authorized reports the status of a request carrying token through the
+// revocation-checking Auth middleware.
+func authorized(env authEnv, token string) int {
+	protected := middleware.Auth(env.keys, middleware.WithRevocation(env.sessions))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/flights/search", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestRefresh_RotatesAndDetectsReuse(t *testing.T) {
+	env := newAuthEnv(t)
+	h := env.handler
+	first := login(t, h)
+
+	rr, second := refresh(h, first.RefreshToken)
+	if rr.Code != http.StatusOK || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("expected a rotated refresh token, got %d %+v", rr.Code, second)
+	}
+	if code := authorized(env, second.Token); code != http.StatusOK {
+		t.Errorf("expected the refreshed access token to be accepted, got %d", code)
+	}
+
+	if rr, _ := refresh(h, first.RefreshToken); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected reuse of a rotated token to be refused, got %d", rr.Code)
+	}
+	if rr, _ := refresh(h, second.RefreshToken); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected reuse to revoke the latest refresh token too, got %d", rr.Code)
+	}
+	if code := authorized(env, second.Token); code != http.StatusUnauthorized {
+		t.Errorf("expected reuse to revoke the session's access tokens, got %d", code)
+	}
+}
+
+func TestRefresh_InvalidToken(t *testing.T) {
+	h := newAuthHandler(t)
+
+	if rr, _ := refresh(h, "not-a-token"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rr.Code)
+	}
+	if rr, _ := refresh(h, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a token, got %d", rr.Code)
+	}
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	env := newAuthEnv(t)
+	h := env.handler
+	tokens := login(t, h)
+	other := login(t, h)
+
+	logout := middleware.Auth(env.keys, middleware.WithRevocation(env.sessions))(http.HandlerFunc(h.Logout))
+	req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.Token)
+	rr := httptest.NewRecorder()
+	logout.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+
+	if code := authorized(env, tokens.Token); code != http.StatusUnauthorized {
+		t.Errorf("expected the logged out token to be revoked, got %d", code)
+	}
+	if rr, _ := refresh(h, tokens.RefreshToken); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected the logged out refresh token to be refused, got %d", rr.Code)
+	}
+	if code := authorized(env, other.Token); code != http.StatusOK {
+		t.Errorf("expected other sessions to stay valid, got %d", code)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signing, _ := auth.NewPrivateKey("current", priv)
+	keys, err := auth.NewKeySet(signing, auth.NewHMACKey([]byte("testsecret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handlers.JWKS(keys)(rr, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	var set auth.JWKS
+	if err := json.NewDecoder(rr.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != "current" || set.Keys[0].Kty != "OKP" {
+		t.Errorf("expected only the Ed25519 key to be published, got %+v", set.Keys)
 	}
 }
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/cache"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+)
+
+// CacheHandler serves cache administration endpoints.
+type CacheHandler struct {
+	cache cache.FlightCacher
+}
+
+func NewCacheHandler(cache cache.FlightCacher) *CacheHandler {
+	return &CacheHandler{cache: cache}
+}
+
+// CacheEntryResponse is a single cached entry with its remaining TTL and age.
+type CacheEntryResponse struct {
+	Key        string      `json:"key"`
+	Status     string      `json:"status"`
+	TTLSeconds float64     `json:"ttl_seconds"`
+	AgeSeconds float64     `json:"age_seconds"`
+	Entry      cache.Entry `json:"entry"`
+}
+
+// Keys lists cached keys, optionally filtered by origin, destination and date.
+func (h *CacheHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	pattern, _, err := routePattern(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	keys, err := h.cache.Keys(r.Context(), pattern)
+	if err != nil {
+		respondCacheError(w, r, "keys", err)
+		return
+	}
+	sort.Strings(keys)
+	utils.RespondJSON(w, http.StatusOK, map[string]any{"pattern": pattern, "count": len(keys), "keys": keys})
+}
+
+// Entry returns the entry stored under the key query parameter. It peeks, so
+// inspecting the cache does not skew its hit ratio or fill the local tier.
+func (h *CacheHandler) Entry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if !strings.HasPrefix(key, cache.KeyNamespace+":") {
+		utils.RespondError(w, http.StatusBadRequest, "key must be a flight cache key")
+		return
+	}
+	entry, found, err := h.cache.Peek(r.Context(), key)
+	if err != nil {
+		respondCacheError(w, r, "get", err)
+		return
+	}
+	if !found {
+		utils.RespondError(w, http.StatusNotFound, "cache entry not found")
+		return
+	}
+	ttl, err := h.cache.TTL(r.Context(), key)
+	if err != nil {
+		respondCacheError(w, r, "ttl", err)
+		return
+	}
+	now := time.Now()
+	utils.RespondJSON(w, http.StatusOK, CacheEntryResponse{
+		Key:        key,
+		Status:     string(entry.Status(now)),
+		TTLSeconds: ttl.Seconds(),
+		AgeSeconds: now.Sub(entry.StoredAt).Seconds(),
+		Entry:      entry,
+	})
+}
+
+// Purge deletes the keys matching origin, destination and date. At least one
+// filter is required; use Flush to remove everything.
+func (h *CacheHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	pattern, filtered, err := routePattern(r)
+	if err != nil {
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !filtered {
+		utils.RespondError(w, http.StatusBadRequest, "at least one of origin, destination or date is required")
+		return
+	}
+	keys, err := h.cache.Keys(r.Context(), pattern)
+	if err == nil && len(keys) > 0 {
+		_, err = h.cache.Delete(r.Context(), keys...)
+	}
+	if err != nil {
+		respondCacheError(w, r, "purge", err)
+		return
+	}
+	log.Printf("%s %s purged %d cache keys\n", r.Method, r.RequestURI, len(keys))
+	utils.RespondJSON(w, http.StatusOK, map[string]any{"pattern": pattern, "deleted": len(keys)})
+}
+
+// Flush removes every cached flight search.
+func (h *CacheHandler) Flush(w http.ResponseWriter, r *http.Request) {
+	n, err := h.cache.Flush(r.Context())
+	if err != nil {
+		respondCacheError(w, r, "flush", err)
+		return
+	}
+	log.Printf("%s %s flushed %d cache keys\n", r.Method, r.RequestURI, n)
+	utils.RespondJSON(w, http.StatusOK, map[string]int64{"deleted": n})
+}
+
+// Stats returns entry count, memory use and hit ratio of each cache tier.
+func (h *CacheHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.cache.Stats(r.Context())
+	if err != nil {
+		respondCacheError(w, r, "stats", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, stats)
+}
+
+// respondCacheError answers 503 while the cache is unavailable and 500 for
+// any other cache failure.
+func respondCacheError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	if errors.Is(err, cache.ErrUnavailable) {
+		utils.RespondError(w, http.StatusServiceUnavailable, "cache unavailable")
+		return
+	}
+	log.Printf("%s %s cache %s error: %v\n", r.Method, r.RequestURI, op, err)
+	utils.RespondError(w, http.StatusInternalServerError, "cache error")
+}
+
+// routePattern builds the key pattern for the origin, destination and date
+// query parameters and reports whether any of them was given.
+func routePattern(r *http.Request) (string, bool, error) {
+	q := r.URL.Query()
+	origin := strings.ToUpper(q.Get("origin"))
+	destination := strings.ToUpper(q.Get("destination"))
+	date := q.Get("date")
+
+	if (origin != "" && !iataRegex.MatchString(origin)) || (destination != "" && !iataRegex.MatchString(destination)) {
+		return "", false, errors.New("invalid IATA code format; expected 3 letters")
+	}
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return "", false, errors.New("invalid date format; expected YYYY-MM-DD")
+		}
+	}
+	filtered := origin != "" || destination != "" || date != ""
+	return cache.RoutePattern(origin, destination, date), filtered, nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/cache"
+	cachemock "github.com/fehepe/flight-price-service/internal/cache/mock"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestCacheHandler(t *testing.T) {
+	ctx := context.Background()
+	c := cachemock.NewMockCache()
+	date := time.Now().AddDate(0, 0, 7)
+	other := date.AddDate(0, 0, 1)
+	for _, s := range []models.FlightSearch{
+		{Origin: "JFK", Destination: "LAX", DepartureDate: date},
+		{Origin: "JFK", Destination: "LAX", DepartureDate: other},
+		{Origin: "JFK", Destination: "SFO", DepartureDate: date},
+	} {
+		c.Set(ctx, cache.ProviderKey(s, "MockAir"), []models.FlightOffer{{Provider: "MockAir", Price: 80}}, 0)
+	}
+	h := NewCacheHandler(c)
+	day := date.Format("2006-01-02")
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		query   string
+		code    int
+		count   float64
+	}{
+		{"list by route", h.Keys, http.MethodGet, "?origin=JFK&destination=LAX", http.StatusOK, 2},
+		{"list by date", h.Keys, http.MethodGet, "?date=" + day, http.StatusOK, 2},
+		{"invalid code", h.Keys, http.MethodGet, "?origin=JFKX", http.StatusBadRequest, 0},
+		{"purge needs a filter", h.Purge, http.MethodDelete, "", http.StatusBadRequest, 0},
+		{"purge by route and date", h.Purge, http.MethodDelete, "?origin=jfk&destination=lax&date=" + day, http.StatusOK, 1},
+		{"list after purge", h.Keys, http.MethodGet, "", http.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(tt.method, "/admin/cache/keys"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.code, rec.Body)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var body map[string]any
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			got := body["count"]
+			if got == nil {
+				got = body["deleted"]
+			}
+			if got != tt.count {
+				t.Errorf("got %v keys, want %v", got, tt.count)
+			}
+		})
+	}
+
+	t.Run("entry", func(t *testing.T) {
+		key := cache.ProviderKey(models.FlightSearch{Origin: "JFK", Destination: "SFO", DepartureDate: date}, "MockAir")
+		rec := httptest.NewRecorder()
+		h.Entry(rec, httptest.NewRequest(http.MethodGet, "/admin/cache/entry?key="+key, nil))
+		var body CacheEntryResponse
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if rec.Code != http.StatusOK || body.Status != "fresh" || body.TTLSeconds <= 0 || len(body.Entry.Offers) != 1 {
+			t.Errorf("unexpected entry response %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.Flush(rec, httptest.NewRequest(http.MethodDelete, "/admin/cache", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d", rec.Code)
+		}
+		if stats, _ := c.Stats(ctx); stats.Entries != 0 {
+			t.Errorf("expected an empty cache after flush, got %d entries", stats.Entries)
+		}
+	})
+}
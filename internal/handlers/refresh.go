@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/cache"
+	"github.com/fehepe/flight-price-service/internal/providers"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+const leasePollInterval = 50 * time.Millisecond
+
+// errRecentFailure is reported for a provider whose last call for the same
+// search failed and is still negatively cached.
+var errRecentFailure = errors.New("provider failed recently; cached failure")
+
+// providerResult is one provider's contribution to a search.
+type providerResult struct {
+	provider string
+	offers   []models.FlightOffer
+	status   cache.Status
+	err      error
+}
+
+// collect gathers offers for a search from each provider's cache entry,
+// fetching only the providers whose entries are missing. Stale entries are
+// served and refreshed in the background. A provider whose entry cannot be
+// read is fetched directly, bypassing the cache; provider errors are
+// reported per result.
+func (h *FlightHandler) collect(ctx context.Context, search models.FlightSearch, eligible []providers.Provider) []providerResult {
+	results := make([]providerResult, len(eligible))
+	var missing []int
+
+	for i, p := range eligible {
+		name := providers.NameOf(p)
+		key := h.namespace.ProviderKey(search, name)
+		entry, found, err := h.cache.Get(ctx, key)
+		if err != nil {
+			logCacheError(err, "cache get %s error, bypassing cache: %v", key, err)
+			results[i] = providerResult{provider: name, status: cache.StatusBypass}
+			missing = append(missing, i)
+			continue
+		}
+		if !found {
+			results[i] = providerResult{provider: name, status: cache.StatusMiss}
+			missing = append(missing, i)
+			continue
+		}
+		if entry.Negative == cache.NegativeFailed && h.recoveredSince(ctx, name, entry.StoredAt) {
+			results[i] = providerResult{provider: name, status: cache.StatusMiss}
+			missing = append(missing, i)
+			continue
+		}
+		status := entry.Status(time.Now())
+		if status == cache.StatusStale {
+			h.revalidate(key, p, search)
+		}
+		offers, err := fromEntry(entry)
+		results[i] = providerResult{provider: name, offers: offers, status: status, err: err}
+	}
+
+	var wg sync.WaitGroup
+	for _, i := range missing {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := eligible[i]
+			results[i].offers, results[i].err = h.refresh(ctx, h.namespace.ProviderKey(search, results[i].provider), p, search)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// refresh fetches one provider's offers for a cache miss and stores them.
+// Concurrent requests for the same key in this process share one fetch, and
+// when the cache supports leases only one replica fetches while the others
+// wait for its result to land in the cache.
+func (h *FlightHandler) refresh(ctx context.Context, key string, p providers.Provider, search models.FlightSearch) ([]models.FlightOffer, error) {
+	ch := h.group.DoChan(key, func() (interface{}, error) {
+		// Detach from the first caller so its cancellation does not fail the
+		// other requests sharing this fetch.
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.fetchTimeout)
+		defer cancel()
+		return h.fetchWithLease(fetchCtx, key, p, search)
+	})
+
+	select {
+	case res := <-ch:
+		if res.Shared {
+			log.Printf("coalesced fetch for %s", key)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]models.FlightOffer), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// revalidate refreshes a stale entry in the background. The request that
+// found it stale is answered immediately and does not wait.
+func (h *FlightHandler) revalidate(key string, p providers.Provider, search models.FlightSearch) {
+	go func() {
+		if _, err := h.refresh(context.Background(), key, p, search); err != nil {
+			log.Printf("background refresh %s error: %v", key, err)
+		}
+	}()
+}
+
+func (h *FlightHandler) fetchWithLease(ctx context.Context, key string, p providers.Provider, search models.FlightSearch) ([]models.FlightOffer, error) {
+	if locker, ok := h.cache.(cache.Locker); ok {
+		unlock, acquired, err := locker.Lock(ctx, key, h.leaseTTL)
+		switch {
+		case err != nil:
+			logCacheError(err, "cache lease %s unavailable, fetching without it: %v", key, err)
+		case acquired:
+			defer unlock()
+		default:
+			if entry, found := h.waitForLeaseHolder(ctx, key); found {
+				return fromEntry(entry)
+			}
+			log.Printf("cache lease %s holder did not refresh within %v, fetching", key, h.leaseWait)
+		}
+	}
+
+	name := providers.NameOf(p)
+	offers, err := p.GetFlights(ctx, search)
+	// A provider that hung until the fetch timed out has used up ctx; its
+	// failure must still be cached.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheWriteTimeout)
+	defer cancel()
+	switch {
+	case errors.Is(err, providers.ErrNoFlights) || (err == nil && len(offers) == 0):
+		h.setNegative(ctx, key, cache.NegativeNoOffers, h.noOffersTTL)
+		h.markRecovered(ctx, name)
+		return nil, nil
+	case err != nil:
+		h.setNegative(ctx, key, cache.NegativeFailed, h.failedTTL)
+		h.markFailed(ctx, name)
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if err := h.cache.Set(ctx, key, offers, h.providerTTLs[name]); err != nil {
+		logCacheError(err, "cache set %s error: %v", key, err)
+	}
+	h.markRecovered(ctx, name)
+	return offers, nil
+}
+
+func (h *FlightHandler) setNegative(ctx context.Context, key string, kind cache.NegativeKind, ttl time.Duration) {
+	if err := h.cache.SetNegative(ctx, key, kind, ttl); err != nil {
+		logCacheError(err, "cache set negative %s error: %v", key, err)
+	}
+}
+
+// markFailed records when the provider last failed. Like the failed entries
+// it expires after exactly failedTTL, so it lasts as long as the newest of
+// them.
+func (h *FlightHandler) markFailed(ctx context.Context, provider string) {
+	if err := h.cache.SetNegative(ctx, h.namespace.FailureKey(provider), cache.NegativeMarker, h.failedTTL); err != nil {
+		logCacheError(err, "cache set failure %s error: %v", provider, err)
+	}
+}
+
+// markRecovered records that the provider just answered, which invalidates
+// every failed entry it left behind on any search. The marker only needs to
+// outlive those entries, and is only written when the provider failed after
+// the current marker, so a healthy provider causes no writes.
+func (h *FlightHandler) markRecovered(ctx context.Context, provider string) {
+	if !h.failedSinceRecovery(ctx, provider) {
+		return
+	}
+	if err := h.cache.SetNegative(ctx, h.namespace.RecoveryKey(provider), cache.NegativeMarker, h.failedTTL); err != nil {
+		logCacheError(err, "cache set recovery %s error: %v", provider, err)
+	}
+}
+
+// failedSinceRecovery reports whether the provider failed after its recovery
+// marker was written, or whether that cannot be told.
+func (h *FlightHandler) failedSinceRecovery(ctx context.Context, provider string) bool {
+	failure, failed, err := h.cache.Peek(ctx, h.namespace.FailureKey(provider))
+	if err != nil {
+		logCacheError(err, "cache get failure %s error: %v", provider, err)
+		return true
+	}
+	if !failed {
+		return false
+	}
+	marker, found, err := h.cache.Peek(ctx, h.namespace.RecoveryKey(provider))
+	if err != nil {
+		logCacheError(err, "cache get recovery %s error: %v", provider, err)
+		return true
+	}
+	return !found || !marker.StoredAt.After(failure.StoredAt)
+}
+
+// recoveredSince reports whether the provider has answered successfully
+// after the given time. The marker is peeked, so checking it neither counts
+// towards the hit ratio nor fills the local tier.
+func (h *FlightHandler) recoveredSince(ctx context.Context, provider string, since time.Time) bool {
+	marker, found, err := h.cache.Peek(ctx, h.namespace.RecoveryKey(provider))
+	if err != nil {
+		logCacheError(err, "cache get recovery %s error: %v", provider, err)
+		return false
+	}
+	return found && marker.StoredAt.After(since)
+}
+
+// fromEntry converts a cached entry back into a provider outcome.
+func fromEntry(entry cache.Entry) ([]models.FlightOffer, error) {
+	if entry.Negative == cache.NegativeFailed {
+		return nil, errRecentFailure
+	}
+	return entry.Offers, nil
+}
+
+// waitForLeaseHolder polls the cache until another replica stores a fresh
+// entry or the wait elapses.
+func (h *FlightHandler) waitForLeaseHolder(ctx context.Context, key string) (cache.Entry, bool) {
+	deadline := time.NewTimer(h.leaseWait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(leasePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			entry, found, err := h.cache.Get(ctx, key)
+			if err != nil {
+				logCacheError(err, "cache get %s while waiting for lease: %v", key, err)
+				return cache.Entry{}, false
+			}
+			if found && entry.Status(time.Now()) == cache.StatusFresh {
+				return entry, true
+			}
+		case <-deadline.C:
+			return cache.Entry{}, false
+		case <-ctx.Done():
+			return cache.Entry{}, false
+		}
+	}
+}
+
+// logCacheError logs a cache failure, except while the cache is bypassed:
+// the cache logs the outage once itself rather than once per call.
+func logCacheError(err error, format string, args ...any) {
+	if errors.Is(err, cache.ErrUnavailable) {
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// aggregate merges provider results. The overall status is bypass if the
+// cache could not be read, else miss if any provider was fetched, else
+// stale if any entry was stale, else fresh.
+func aggregate(results []providerResult) (offers []models.FlightOffer, status cache.Status, failed []string, errs []error) {
+	status = cache.StatusFresh
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r.provider)
+			errs = append(errs, r.err)
+		}
+		offers = append(offers, r.offers...)
+		switch {
+		case status == cache.StatusBypass:
+		case r.status == cache.StatusBypass:
+			status = cache.StatusBypass
+		case r.status == cache.StatusMiss:
+			status = cache.StatusMiss
+		case r.status == cache.StatusStale && status == cache.StatusFresh:
+			status = cache.StatusStale
+		}
+	}
+	return offers, status, failed, errs
+}
@@ -1,14 +1,18 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/fehepe/flight-price-service/internal/cache"
 	"github.com/fehepe/flight-price-service/internal/providers"
 	"github.com/fehepe/flight-price-service/internal/services/flight"
+	"github.com/fehepe/flight-price-service/pkg/models"
 	"github.com/fehepe/flight-price-service/pkg/utils"
+	"golang.org/x/sync/singleflight"
 )
 
 // HealthCheck returns detailed service status.
@@ -22,55 +26,168 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+const (
+	defaultLeaseWait = 5 * time.Second
+	defaultNoOffers  = time.Minute
+	defaultFailed    = 15 * time.Second
+	fetchTimeout     = 15 * time.Second
+	// cacheWriteTimeout bounds storing a fetch's outcome, which runs on its
+	// own context so that a fetch that timed out can still be cached.
+	cacheWriteTimeout = time.Second
+	// minLeaseTTL outlasts the slowest fetch and the cache write after it,
+	// so a lease never expires while its holder is still refreshing.
+	minLeaseTTL = fetchTimeout + 5*time.Second
+)
+
 type FlightHandler struct {
 	providers []providers.Provider
 	cache     cache.FlightCacher
+	rules     providers.RuleSet
+	namespace cache.Namespace
+	group     singleflight.Group
+	leaseTTL  time.Duration
+	leaseWait time.Duration
+	// fetchTimeout bounds each provider call; tests shorten it.
+	fetchTimeout time.Duration
+
+	providerTTLs map[string]time.Duration
+	noOffersTTL  time.Duration
+	failedTTL    time.Duration
 }
 
-func NewFlightHandler(providers []providers.Provider, cache cache.FlightCacher) *FlightHandler {
-	return &FlightHandler{providers: providers, cache: cache}
+// FlightHandlerOption configures optional FlightHandler dependencies.
+type FlightHandlerOption func(*FlightHandler)
+
+// WithRules sets the provider selection rules applied to every search.
+func WithRules(rules providers.RuleSet) FlightHandlerOption {
+	return func(h *FlightHandler) {
+		h.rules = rules
+	}
 }
 
-func (h *FlightHandler) GetFlights(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// WithCacheNamespace keeps the handler's cache entries apart from those of
+// handlers serving other tenants.
+func WithCacheNamespace(ns cache.Namespace) FlightHandlerOption {
+	return func(h *FlightHandler) {
+		h.namespace = ns
+	}
+}
 
+// WithLease sets how long a replica holds the refresh lease on a cache key
+// and how long other replicas wait for it before fetching themselves. TTLs
+// shorter than a fetch can take are raised to minLeaseTTL.
+func WithLease(ttl, wait time.Duration) FlightHandlerOption {
+	return func(h *FlightHandler) {
+		h.leaseTTL = max(ttl, minLeaseTTL)
+		h.leaseWait = wait
+	}
+}
+
+// WithProviderTTLs sets how long each provider's results stay fresh in the
+// cache, keyed by provider name. Providers not listed use the cache default.
+func WithProviderTTLs(ttls map[string]time.Duration) FlightHandlerOption {
+	return func(h *FlightHandler) {
+		h.providerTTLs = ttls
+	}
+}
+
+// WithNegativeTTLs sets how long a provider's empty answer and its failure
+// are cached before the provider is asked again.
+func WithNegativeTTLs(noOffers, failed time.Duration) FlightHandlerOption {
+	return func(h *FlightHandler) {
+		h.noOffersTTL = noOffers
+		h.failedTTL = failed
+	}
+}
+
+func NewFlightHandler(providers []providers.Provider, cache cache.FlightCacher, opts ...FlightHandlerOption) *FlightHandler {
+	h := &FlightHandler{
+		providers:    providers,
+		cache:        cache,
+		leaseTTL:     minLeaseTTL,
+		fetchTimeout: fetchTimeout,
+		leaseWait:    defaultLeaseWait,
+		noOffersTTL:  defaultNoOffers,
+		failedTTL:    defaultFailed,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// RouteProviders is a dry run of provider selection: it reports which
+// providers a search would query and why the others would be skipped.
+func (h *FlightHandler) RouteProviders(w http.ResponseWriter, r *http.Request) {
 	search, err := extractFlightSearch(r)
 	if err != nil {
 		utils.RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	utils.RespondJSON(w, http.StatusOK, providers.Plan(h.providers, h.rules, search, time.Now()))
+}
 
-	cacheKey := fmt.Sprintf("%s:%s:%s",
-		search.Origin,
-		search.Destination,
-		search.DepartureDate.Format("2006-01-02"),
-	)
+func (h *FlightHandler) GetFlights(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
-	cachedOffers, found, err := h.cache.Get(ctx, cacheKey)
+	search, err := extractFlightSearch(r)
 	if err != nil {
-		log.Printf("%s %s cache set get: %v\n", r.Method, r.RequestURI, err)
-		utils.RespondError(w, http.StatusInternalServerError, "cache error")
+		utils.RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if found {
-		utils.RespondJSON(w, http.StatusOK, flight.BuildSearchResponse(cachedOffers))
+
+	selection := providers.Plan(h.providers, h.rules, search, time.Now())
+	eligible, skipped := selection.Providers, selection.Skipped
+	if len(skipped) > 0 {
+		log.Printf("%s %s skipped providers: %s\n", r.Method, r.RequestURI, providers.SkippedSummary(skipped))
+	}
+	if len(eligible) == 0 {
+		utils.RespondJSON(w, http.StatusUnprocessableEntity, models.NoProviderResponse{
+			Error:   "no provider can serve this search",
+			Skipped: skipped,
+		})
 		return
 	}
+	h.track(ctx, search)
 
-	offers, err := flight.FetchAllFlightOffers(ctx, h.providers, search)
-	if err != nil {
-		log.Printf("%s %s error fetching flight offers: %v\n", r.Method, r.RequestURI, err)
-		utils.RespondError(w, http.StatusInternalServerError, "error fetching flight offers")
-		return
+	results := h.collect(ctx, search, eligible)
+	offers, status, failed, errs := aggregate(results)
+	if len(errs) > 0 {
+		log.Printf("%s %s error fetching flight offers: %v\n", r.Method, r.RequestURI, errors.Join(errs...))
 	}
 
 	if len(offers) == 0 {
+		if len(failed) == len(results) {
+			utils.RespondError(w, http.StatusInternalServerError, "error fetching flight offers")
+			return
+		}
 		utils.RespondError(w, http.StatusNotFound, "no flight offers found")
 		return
 	}
-	if err := h.cache.Set(ctx, cacheKey, offers); err != nil {
-		log.Printf("%s %s cache set error: %v\n", r.Method, r.RequestURI, err)
+
+	respondOffers(w, offers, skipped, failed, status)
+}
+
+// track counts the search towards route popularity when the cache supports
+// it. Tenants' searches are not counted, since warming spends the shared
+// credentials' quota.
+func (h *FlightHandler) track(ctx context.Context, search models.FlightSearch) {
+	if h.namespace != "" {
+		return
 	}
+	if tracker, ok := h.cache.(cache.PopularityTracker); ok {
+		if err := tracker.Track(ctx, search); err != nil {
+			logCacheError(err, "cache track %s error: %v", cache.SearchMember(search), err)
+		}
+	}
+}
 
-	utils.RespondJSON(w, http.StatusOK, flight.BuildSearchResponse(offers))
+// respondOffers writes the search response, reporting the cache status both
+// in the X-Cache-Status header and the cache_status field.
+func respondOffers(w http.ResponseWriter, offers []models.FlightOffer, skipped []models.SkippedProvider, failed []string, status cache.Status) {
+	resp := flight.BuildSearchResponse(offers, skipped)
+	resp.Failed = failed
+	resp.CacheStatus = string(status)
+	w.Header().Set("X-Cache-Status", string(status))
+	utils.RespondJSON(w, http.StatusOK, resp)
 }
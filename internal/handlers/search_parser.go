@@ -4,12 +4,22 @@ import (
 	"errors"
 	"net/http"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/fehepe/flight-price-service/pkg/models"
 )
 
-var iataRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+var (
+	iataRegex     = regexp.MustCompile(`^[A-Z]{3}$`)
+	currencyRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+	validCabins   = map[string]bool{
+		models.CabinEconomy:        true,
+		models.CabinPremiumEconomy: true,
+		models.CabinBusiness:       true,
+		models.CabinFirst:          true,
+	}
+)
 
 func extractFlightSearch(r *http.Request) (models.FlightSearch, error) {
 	origin := r.URL.Query().Get("origin")
@@ -33,9 +43,36 @@ func extractFlightSearch(r *http.Request) (models.FlightSearch, error) {
 		return models.FlightSearch{}, errors.New("date cannot be in the past")
 	}
 
-	return models.FlightSearch{
+	search := models.FlightSearch{
 		Origin:        origin,
 		Destination:   destination,
 		DepartureDate: departureDate,
-	}, nil
+	}
+
+	if returnStr := r.URL.Query().Get("return_date"); returnStr != "" {
+		returnDate, err := time.Parse("2006-01-02", returnStr)
+		if err != nil {
+			return models.FlightSearch{}, errors.New("invalid return_date format; expected YYYY-MM-DD")
+		}
+		if returnDate.Before(departureDate) {
+			return models.FlightSearch{}, errors.New("return_date cannot be before date")
+		}
+		search.ReturnDate = returnDate
+	}
+
+	if cabin := strings.ToLower(r.URL.Query().Get("cabin")); cabin != "" {
+		if !validCabins[cabin] {
+			return models.FlightSearch{}, errors.New("invalid cabin; expected economy, premium_economy, business or first")
+		}
+		search.Cabin = cabin
+	}
+
+	if currency := strings.ToUpper(r.URL.Query().Get("currency")); currency != "" {
+		if !currencyRegex.MatchString(currency) {
+			return models.FlightSearch{}, errors.New("invalid currency; expected a 3-letter ISO code")
+		}
+		search.Currency = currency
+	}
+
+	return search, nil
 }
@@ -9,6 +9,7 @@ import (
 
 func TestExtractFlightSearch(t *testing.T) {
 	today := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	nextWeek := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
 
 	tests := []struct {
 		name      string
@@ -58,6 +59,38 @@ func TestExtractFlightSearch(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "valid round trip with cabin and currency",
+			params: map[string]string{
+				"origin":      "JFK",
+				"destination": "LAX",
+				"date":        today,
+				"return_date": nextWeek,
+				"cabin":       "business",
+				"currency":    "eur",
+			},
+			wantError: false,
+		},
+		{
+			name: "return before departure",
+			params: map[string]string{
+				"origin":      "JFK",
+				"destination": "LAX",
+				"date":        nextWeek,
+				"return_date": today,
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid cabin",
+			params: map[string]string{
+				"origin":      "JFK",
+				"destination": "LAX",
+				"date":        today,
+				"cabin":       "steerage",
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
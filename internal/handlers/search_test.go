@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/fehepe/flight-price-service/internal/cache"
 	cachemock "github.com/fehepe/flight-price-service/internal/cache/mock"
 	"github.com/fehepe/flight-price-service/internal/providers"
 	"github.com/fehepe/flight-price-service/internal/providers/mock"
@@ -81,3 +87,382 @@ func TestGetFlights(t *testing.T) {
 		})
 	}
 }
+
+// countingProvider counts calls and delays its answer so concurrent
+// requests overlap.
+// economyProvider only serves economy searches.
+type economyProvider struct{ countingProvider }
+
+func (p *economyProvider) Name() string { return "Economy" }
+
+func (p *economyProvider) Capabilities() providers.Capabilities {
+	return providers.Capabilities{RoundTrip: true, CabinClasses: []string{models.CabinEconomy}}
+}
+
+func TestGetFlights_NoEligibleProviderListsSkipped(t *testing.T) {
+	provider := &economyProvider{}
+	h := NewFlightHandler([]providers.Provider{provider}, cachemock.NewMockCache())
+	date := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	rec := httptest.NewRecorder()
+	h.GetFlights(rec, httptest.NewRequest(http.MethodGet, "/flights/search?origin=JFK&destination=LAX&cabin=first&date="+date, nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", rec.Code)
+	}
+	var resp models.NoProviderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == "" || len(resp.Skipped) != 1 || resp.Skipped[0].Provider != "Economy" || resp.Skipped[0].Reason == "" {
+		t.Errorf("expected the error and why Economy was skipped, got %+v", resp)
+	}
+	if provider.calls.Load() != 0 {
+		t.Error("expected the skipped provider not to be called")
+	}
+}
+
+type countingProvider struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (p *countingProvider) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
+	p.calls.Add(1)
+	time.Sleep(p.delay)
+	return mock.New(false).GetFlights(ctx, search)
+}
+
+func TestGetFlights_CoalescesConcurrentMisses(t *testing.T) {
+	provider := &countingProvider{delay: 100 * time.Millisecond}
+	h := NewFlightHandler([]providers.Provider{provider}, cachemock.NewMockCache())
+	query := "/flights/search?origin=JFK&destination=LAX&date=" + time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h.GetFlights(rec, httptest.NewRequest(http.MethodGet, query, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Errorf("expected 1 provider call for concurrent identical searches, got %d", calls)
+	}
+}
+
+func TestGetFlights_LeaseSharedAcrossReplicas(t *testing.T) {
+	provider := &countingProvider{delay: 100 * time.Millisecond}
+	shared := cachemock.NewMockCache()
+	replicas := []*FlightHandler{
+		NewFlightHandler([]providers.Provider{provider}, shared),
+		NewFlightHandler([]providers.Provider{provider}, shared),
+	}
+	query := "/flights/search?origin=JFK&destination=SFO&date=" + time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	var wg sync.WaitGroup
+	for _, h := range replicas {
+		wg.Add(1)
+		go func(h *FlightHandler) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h.GetFlights(rec, httptest.NewRequest(http.MethodGet, query, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", rec.Code)
+			}
+		}(h)
+	}
+	wg.Wait()
+
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Errorf("expected the lease to allow 1 provider call across replicas, got %d", calls)
+	}
+}
+
+func TestWithLease_OutlastsFetch(t *testing.T) {
+	for _, tt := range []struct {
+		ttl, want time.Duration
+	}{
+		{0, minLeaseTTL},
+		{10 * time.Second, minLeaseTTL},
+		{time.Minute, time.Minute},
+	} {
+		h := NewFlightHandler(nil, cachemock.NewMockCache(), WithLease(tt.ttl, time.Second))
+		if h.leaseTTL != tt.want || h.leaseTTL <= fetchTimeout {
+			t.Errorf("lease %v: expected %v, got %v", tt.ttl, tt.want, h.leaseTTL)
+		}
+	}
+}
+
+func TestGetFlights_StaleWhileRevalidate(t *testing.T) {
+	provider := &countingProvider{}
+	h := NewFlightHandler([]providers.Provider{provider}, cachemock.NewMockCacheWithTTL(20*time.Millisecond, time.Minute))
+	query := "/flights/search?origin=JFK&destination=MIA&date=" + time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	get := func() string {
+		rec := httptest.NewRecorder()
+		h.GetFlights(rec, httptest.NewRequest(http.MethodGet, query, nil))
+		var resp models.SearchResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.CacheStatus != rec.Header().Get("X-Cache-Status") {
+			t.Errorf("expected header and body cache status to match, got %q and %q", rec.Header().Get("X-Cache-Status"), resp.CacheStatus)
+		}
+		return resp.CacheStatus
+	}
+
+	if status := get(); status != "miss" {
+		t.Fatalf("expected first request to miss, got %q", status)
+	}
+	if status := get(); status != "fresh" {
+		t.Fatalf("expected second request to be fresh, got %q", status)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if status := get(); status != "stale" {
+		t.Fatalf("expected expired entry to be served stale, got %q", status)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for cache := get(); cache != "fresh"; cache = get() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected background refresh to make the entry fresh, still %q", cache)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if calls := provider.calls.Load(); calls < 2 {
+		t.Errorf("expected a background provider call, got %d", calls)
+	}
+}
+
+func TestGetFlights_CachesEachProviderSeparately(t *testing.T) {
+	good := &countingProvider{}
+	failing := &countingFailingProvider{}
+	h := NewFlightHandler([]providers.Provider{good, failing}, cachemock.NewMockCache())
+	query := "/flights/search?origin=JFK&destination=BOS&date=" + time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.GetFlights(rec, httptest.NewRequest(http.MethodGet, query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected partial results with status 200, got %d", i, rec.Code)
+		}
+		var resp models.SearchResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(resp.Failed) != 1 {
+			t.Errorf("request %d: expected 1 failed provider, got %v", i, resp.Failed)
+		}
+	}
+
+	if calls := good.calls.Load(); calls != 1 {
+		t.Errorf("expected the healthy provider's result to be cached, got %d calls", calls)
+	}
+	if calls := failing.calls.Load(); calls != 1 {
+		t.Errorf("expected the failure to be negatively cached, got %d calls", calls)
+	}
+}
+
+// countingFailingProvider fails until healthy is set.
+type countingFailingProvider struct {
+	calls   atomic.Int32
+	healthy atomic.Bool
+}
+
+func (p *countingFailingProvider) Name() string { return "Failing" }
+
+func (p *countingFailingProvider) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
+	p.calls.Add(1)
+	if p.healthy.Load() {
+		return mock.New(false).GetFlights(ctx, search)
+	}
+	return nil, errors.New("upstream unavailable")
+}
+
+func TestGetFlights_NegativeCaching(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	get := func(h *FlightHandler, route string) int {
+		rec := httptest.NewRecorder()
+		h.GetFlights(rec, httptest.NewRequest(http.MethodGet, "/flights/search?"+route+"&date="+tomorrow, nil))
+		return rec.Code
+	}
+
+	t.Run("no offers", func(t *testing.T) {
+		empty := &emptyProvider{}
+		h := NewFlightHandler([]providers.Provider{empty}, cachemock.NewMockCache())
+		for i := 0; i < 2; i++ {
+			if code := get(h, "origin=JFK&destination=ORD"); code != http.StatusNotFound {
+				t.Errorf("expected 404, got %d", code)
+			}
+		}
+		if calls := empty.calls.Load(); calls != 1 {
+			t.Errorf("expected the empty answer to be cached, got %d calls", calls)
+		}
+	})
+
+	t.Run("all failed then recovered", func(t *testing.T) {
+		failing := &countingFailingProvider{}
+		flightCache := cachemock.NewMockCache()
+		h := NewFlightHandler([]providers.Provider{failing}, flightCache)
+		for i := 0; i < 2; i++ {
+			if code := get(h, "origin=JFK&destination=DEN"); code != http.StatusInternalServerError {
+				t.Errorf("expected 500, got %d", code)
+			}
+		}
+		if calls := failing.calls.Load(); calls != 1 {
+			t.Fatalf("expected the failure to be cached, got %d calls", calls)
+		}
+		if stats, _ := flightCache.Stats(context.Background()); stats.Redis.Hits+stats.Redis.Misses != 2 {
+			t.Errorf("expected only the 2 search lookups to count, got %+v", stats.Redis)
+		}
+		failure, _, _ := flightCache.Peek(context.Background(), cache.Namespace("").FailureKey("Failing"))
+		if life := failure.StaleUntil.Sub(failure.StoredAt); life != defaultFailed {
+			t.Errorf("expected the failure marker to last as long as failed entries, got %v", life)
+		}
+
+		// The provider recovers on another route, which invalidates the failure.
+		time.Sleep(time.Millisecond)
+		failing.healthy.Store(true)
+		if code := get(h, "origin=JFK&destination=SEA"); code != http.StatusOK {
+			t.Fatalf("expected 200 on recovered provider, got %d", code)
+		}
+		if code := get(h, "origin=JFK&destination=DEN"); code != http.StatusOK {
+			t.Errorf("expected cached failure to be invalidated by recovery, got %d", code)
+		}
+		if calls := failing.calls.Load(); calls != 3 {
+			t.Errorf("expected 3 provider calls, got %d", calls)
+		}
+
+		// Once recovery is recorded, further successes leave the marker alone.
+		marker, found, _ := flightCache.Peek(context.Background(), cache.RecoveryKey("Failing"))
+		if !found {
+			t.Fatal("expected the recovery to be recorded")
+		}
+		get(h, "origin=JFK&destination=BOS")
+		if again, _, _ := flightCache.Peek(context.Background(), cache.RecoveryKey("Failing")); !again.StoredAt.Equal(marker.StoredAt) {
+			t.Errorf("expected the recovery marker not to be rewritten, stored at %v then %v", marker.StoredAt, again.StoredAt)
+		}
+	})
+
+	t.Run("healthy provider writes no marker", func(t *testing.T) {
+		flightCache := cachemock.NewMockCache()
+		h := NewFlightHandler([]providers.Provider{&countingProvider{}}, flightCache)
+		get(h, "origin=JFK&destination=MIA")
+		get(h, "origin=JFK&destination=ATL")
+		keys, _ := flightCache.Keys(context.Background(), cache.KeyPrefix()+":*")
+		if slices.ContainsFunc(keys, cache.IsMarkerKey) {
+			t.Errorf("expected no provider markers without a failure, got %v", keys)
+		}
+	})
+}
+
+// hangingProvider answers only when its context is done.
+type hangingProvider struct {
+	calls atomic.Int32
+}
+
+func (p *hangingProvider) Name() string { return "Hanging" }
+
+func (p *hangingProvider) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
+	p.calls.Add(1)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// contextCache refuses writes on a done context, as Redis clients do.
+type contextCache struct {
+	*cachemock.MockCache
+}
+
+func (c contextCache) Set(ctx context.Context, key string, offers []models.FlightOffer, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.MockCache.Set(ctx, key, offers, ttl)
+}
+
+func (c contextCache) SetNegative(ctx context.Context, key string, kind cache.NegativeKind, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.MockCache.SetNegative(ctx, key, kind, ttl)
+}
+
+func TestGetFlights_CachesTimedOutFetch(t *testing.T) {
+	hanging := &hangingProvider{}
+	h := NewFlightHandler([]providers.Provider{hanging}, contextCache{cachemock.NewMockCache()})
+	h.fetchTimeout = 20 * time.Millisecond
+	query := "/flights/search?origin=JFK&destination=LAX&date=" + time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.GetFlights(rec, httptest.NewRequest(http.MethodGet, query, nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("request %d: expected 500, got %d", i, rec.Code)
+		}
+	}
+	if calls := hanging.calls.Load(); calls != 1 {
+		t.Errorf("expected the timed out fetch to be cached as a failure, got %d provider calls", calls)
+	}
+}
+
+type emptyProvider struct {
+	calls atomic.Int32
+}
+
+func (p *emptyProvider) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
+	p.calls.Add(1)
+	return nil, providers.ErrNoFlights
+}
+
+// unavailableCache fails every call the way FlightCache does while Redis is down.
+type unavailableCache struct {
+	*cachemock.MockCache
+}
+
+func (unavailableCache) Get(ctx context.Context, key string) (cache.Entry, bool, error) {
+	return cache.Entry{}, false, cache.ErrUnavailable
+}
+
+func (unavailableCache) Peek(ctx context.Context, key string) (cache.Entry, bool, error) {
+	return cache.Entry{}, false, cache.ErrUnavailable
+}
+
+func (unavailableCache) Set(ctx context.Context, key string, offers []models.FlightOffer, ttl time.Duration) error {
+	return cache.ErrUnavailable
+}
+
+func (unavailableCache) SetNegative(ctx context.Context, key string, kind cache.NegativeKind, ttl time.Duration) error {
+	return cache.ErrUnavailable
+}
+
+func (unavailableCache) Lock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	return nil, false, cache.ErrUnavailable
+}
+
+func TestGetFlights_BypassesUnavailableCache(t *testing.T) {
+	provider := &countingProvider{}
+	h := NewFlightHandler([]providers.Provider{provider}, unavailableCache{cachemock.NewMockCache()})
+	query := "/flights/search?origin=JFK&destination=LAX&date=" + time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.GetFlights(rec, httptest.NewRequest(http.MethodGet, query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d", i, rec.Code)
+		}
+		if got := rec.Header().Get("X-Cache-Status"); got != string(cache.StatusBypass) {
+			t.Errorf("request %d: expected X-Cache-Status bypass, got %q", i, got)
+		}
+	}
+	if calls := provider.calls.Load(); calls != 2 {
+		t.Errorf("expected every request to reach the provider, got %d calls", calls)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/middleware"
+	"github.com/fehepe/flight-price-service/internal/tenant"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+	"golang.org/x/sync/singleflight"
+)
+
+// callerTenant returns the tenant of the authenticated caller, or "" for
+// callers outside any tenant, who may administer every tenant.
+func callerTenant(r *http.Request) string {
+	if claims, ok := middleware.FromContext(r.Context()); ok {
+		return claims.Tenant
+	}
+	return ""
+}
+
+// tenantRetryDelay is how long a tenant whose handler failed to build is
+// answered with the failure before the build is tried again.
+const tenantRetryDelay = 30 * time.Second
+
+// TenantFlights serves searches with the FlightHandler of the caller's
+// tenant. Each tenant's handler, with its own providers and cache
+// namespace, is built on first use; callers without a tenant share one.
+type TenantFlights struct {
+	shared     *FlightHandler
+	build      func(tenantID string) (*FlightHandler, error)
+	group      singleflight.Group
+	retryDelay time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	handlers map[string]*FlightHandler
+	failures map[string]buildFailure
+}
+
+type buildFailure struct {
+	err   error
+	until time.Time
+}
+
+// NewTenantFlights dispatches to shared or to the handlers build returns.
+// build should fail with tenant.ErrNotFound for unknown tenants; other
+// failures are retried once tenantRetryDelay has passed.
+func NewTenantFlights(shared *FlightHandler, build func(tenantID string) (*FlightHandler, error)) *TenantFlights {
+	return &TenantFlights{
+		shared:     shared,
+		build:      build,
+		retryDelay: tenantRetryDelay,
+		now:        time.Now,
+		handlers:   make(map[string]*FlightHandler),
+		failures:   make(map[string]buildFailure),
+	}
+}
+
+func (t *TenantFlights) GetFlights(w http.ResponseWriter, r *http.Request) {
+	if h, ok := t.handler(w, r); ok {
+		h.GetFlights(w, r)
+	}
+}
+
+func (t *TenantFlights) RouteProviders(w http.ResponseWriter, r *http.Request) {
+	if h, ok := t.handler(w, r); ok {
+		h.RouteProviders(w, r)
+	}
+}
+
+// handler returns the caller's handler, or responds with an error.
+func (t *TenantFlights) handler(w http.ResponseWriter, r *http.Request) (*FlightHandler, bool) {
+	claims, ok := middleware.FromContext(r.Context())
+	if !ok || claims.Tenant == "" {
+		return t.shared, true
+	}
+
+	h, wait, err := t.lookup(claims.Tenant)
+	if errors.Is(err, tenant.ErrNotFound) {
+		utils.RespondError(w, http.StatusForbidden, "unknown tenant")
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("%s %s cannot set up tenant %s: %v\n", r.Method, r.RequestURI, claims.Tenant, err)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		utils.RespondError(w, http.StatusServiceUnavailable, "tenant providers unavailable")
+		return nil, false
+	}
+	return h, true
+}
+
+// lookup returns the tenant's handler, building it if needed. Builds run
+// outside the lock, one at a time per tenant, so a slow build only holds up
+// that tenant's searches. A failed build is remembered for retryDelay;
+// wait is how long remains until it is tried again.
+func (t *TenantFlights) lookup(id string) (h *FlightHandler, wait time.Duration, err error) {
+	if h, failed := t.cached(id); h != nil {
+		return h, 0, nil
+	} else if failed != nil {
+		return nil, failed.until.Sub(t.now()), failed.err
+	}
+	v, err, _ := t.group.Do(id, func() (any, error) {
+		// A build that finished while this caller waited for the group.
+		if h, failed := t.cached(id); h != nil {
+			return h, nil
+		} else if failed != nil {
+			return nil, failed.err
+		}
+		h, err := t.build(id)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		switch {
+		case err == nil:
+			t.handlers[id] = h
+			delete(t.failures, id)
+		case !errors.Is(err, tenant.ErrNotFound):
+			t.failures[id] = buildFailure{err: err, until: t.now().Add(t.retryDelay)}
+		}
+		return h, err
+	})
+	if err != nil {
+		return nil, t.retryDelay, err
+	}
+	return v.(*FlightHandler), 0, nil
+}
+
+// cached returns the tenant's built handler or its failure that is not yet
+// due for a retry, or neither.
+func (t *TenantFlights) cached(id string) (*FlightHandler, *buildFailure) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h, ok := t.handlers[id]; ok {
+		return h, nil
+	}
+	if f, ok := t.failures[id]; ok && t.now().Before(f.until) {
+		return nil, &f
+	}
+	return nil, nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/internal/cache"
+	cachemock "github.com/fehepe/flight-price-service/internal/cache/mock"
+	"github.com/fehepe/flight-price-service/internal/middleware"
+	"github.com/fehepe/flight-price-service/internal/providers"
+	"github.com/fehepe/flight-price-service/internal/tenant"
+)
+
+func TestTenantFlights(t *testing.T) {
+	flightCache := cachemock.NewMockCache()
+	sharedProvider, acmeProvider := &countingProvider{}, &countingProvider{}
+	builds := map[string]int{}
+	flights := NewTenantFlights(
+		NewFlightHandler([]providers.Provider{sharedProvider}, flightCache),
+		func(id string) (*FlightHandler, error) {
+			builds[id]++
+			switch id {
+			case "acme":
+				return NewFlightHandler([]providers.Provider{acmeProvider}, flightCache, WithCacheNamespace("acme")), nil
+			case "broken":
+				return nil, errors.New("credentials missing")
+			}
+			return nil, tenant.ErrNotFound
+		},
+	)
+
+	keys, _ := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	issuer := auth.NewTokenIssuer(keys, "test", time.Hour)
+	handler := middleware.Auth(keys)(http.HandlerFunc(flights.GetFlights))
+	query := "/flights/search?origin=JFK&destination=LAX&date=" + time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	search := func(tenantID string) int {
+		token, _, _ := issuer.Issue(auth.User{Username: "alice", Roles: []string{auth.RoleUser}, Tenant: tenantID}, "")
+		req := httptest.NewRequest(http.MethodGet, query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tt := range []struct {
+		tenant string
+		want   int
+	}{
+		{"", http.StatusOK},
+		{"acme", http.StatusOK},
+		{"acme", http.StatusOK},
+		{"initech", http.StatusForbidden},
+		{"broken", http.StatusServiceUnavailable},
+		{"broken", http.StatusServiceUnavailable},
+	} {
+		if code := search(tt.tenant); code != tt.want {
+			t.Errorf("tenant %q: expected %d, got %d", tt.tenant, tt.want, code)
+		}
+	}
+
+	if sharedProvider.calls.Load() != 1 || acmeProvider.calls.Load() != 1 {
+		t.Errorf("expected each tenant to query its own provider once, got shared=%d acme=%d",
+			sharedProvider.calls.Load(), acmeProvider.calls.Load())
+	}
+	if builds["acme"] != 1 || builds["broken"] != 1 {
+		t.Errorf("expected each tenant to be built once while its failure is remembered, got %v", builds)
+	}
+
+	now := time.Now()
+	flights.now = func() time.Time { return now.Add(tenantRetryDelay) }
+	if code := search("broken"); code != http.StatusServiceUnavailable || builds["broken"] != 2 {
+		t.Errorf("expected the failed build to be retried after the delay, got %d after %d builds", code, builds["broken"])
+	}
+}
+
+func TestTenantFlights_SlowBuildHoldsUpOnlyItsTenant(t *testing.T) {
+	flightCache := cachemock.NewMockCache()
+	release := make(chan struct{})
+	var slowBuilds atomic.Int32
+	flights := NewTenantFlights(NewFlightHandler(nil, flightCache), func(id string) (*FlightHandler, error) {
+		if id == "slow" {
+			slowBuilds.Add(1)
+			<-release
+		}
+		return NewFlightHandler([]providers.Provider{&countingProvider{}}, flightCache, WithCacheNamespace(cache.Namespace(id))), nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			flights.lookup("slow")
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		flights.lookup("fast")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("a slow tenant build held up another tenant")
+	}
+	close(release)
+	wg.Wait()
+	if n := slowBuilds.Load(); n != 1 {
+		t.Errorf("expected concurrent lookups to share one build, got %d", n)
+	}
+}
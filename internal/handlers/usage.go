@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/middleware"
+	"github.com/fehepe/flight-price-service/internal/ratelimit"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+)
+
+// UsageHandler reports callers' own rate limit and quota usage.
+type UsageHandler struct {
+	limiter *ratelimit.Limiter
+	policy  ratelimit.Policy
+}
+
+func NewUsageHandler(limiter *ratelimit.Limiter, policy ratelimit.Policy) *UsageHandler {
+	return &UsageHandler{limiter: limiter, policy: policy}
+}
+
+// UsageResponse describes an account's limits. Limits of 0 and remaining
+// counts of -1 mean unlimited. TenantQuota is the quota shared by the
+// caller's tenant, when it has one.
+type UsageResponse struct {
+	Subject     string         `json:"subject"`
+	Tenant      string         `json:"tenant,omitempty"`
+	Tier        string         `json:"tier"`
+	RateLimit   RateLimitUsage `json:"rate_limit"`
+	DailyQuota  QuotaUsage     `json:"daily_quota"`
+	TenantQuota *QuotaUsage    `json:"tenant_quota,omitempty"`
+}
+
+type RateLimitUsage struct {
+	Limit         int `json:"limit"`
+	WindowSeconds int `json:"window_seconds"`
+	Remaining     int `json:"remaining"`
+}
+
+type QuotaUsage struct {
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// Usage returns the caller's current usage without counting the request.
+func (h *UsageHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.FromContext(r.Context())
+	if !ok {
+		utils.RespondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	account := ratelimit.Account(claims)
+	tier := h.policy.Tier(claims)
+
+	window, err := h.limiter.Peek(r.Context(), account, tier)
+	if err != nil {
+		log.Printf("%s %s usage error: %v\n", r.Method, r.RequestURI, err)
+		utils.RespondError(w, http.StatusServiceUnavailable, "usage unavailable")
+		return
+	}
+	quota, err := h.limiter.Quota(r.Context(), account, tier)
+	if err != nil {
+		log.Printf("%s %s usage error: %v\n", r.Method, r.RequestURI, err)
+		utils.RespondError(w, http.StatusServiceUnavailable, "usage unavailable")
+		return
+	}
+	var tenantQuota *QuotaUsage
+	if tenantAccount, tenantTier, ok := h.policy.TenantQuota(claims); ok {
+		q, err := h.limiter.Quota(r.Context(), tenantAccount, tenantTier)
+		if err != nil {
+			log.Printf("%s %s usage error: %v\n", r.Method, r.RequestURI, err)
+			utils.RespondError(w, http.StatusServiceUnavailable, "usage unavailable")
+			return
+		}
+		usage := newQuotaUsage(q)
+		tenantQuota = &usage
+	}
+
+	resp := UsageResponse{
+		Subject: claims.Subject,
+		Tenant:  claims.Tenant,
+		Tier:    tier.Name,
+		RateLimit: RateLimitUsage{
+			Limit:         tier.Rate,
+			WindowSeconds: int(tier.Window.Seconds()),
+			Remaining:     -1,
+		},
+		DailyQuota:  newQuotaUsage(quota),
+		TenantQuota: tenantQuota,
+	}
+	if tier.Rate > 0 {
+		resp.RateLimit.Remaining = window.Remaining
+	}
+	utils.RespondJSON(w, http.StatusOK, resp)
+}
+
+func newQuotaUsage(q ratelimit.Quota) QuotaUsage {
+	return QuotaUsage{Limit: q.Limit, Used: q.Used, Remaining: q.Remaining(), ResetsAt: q.ResetsAt}
+}
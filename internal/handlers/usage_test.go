@@ -0,0 +1,65 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/internal/handlers"
+	"github.com/fehepe/flight-price-service/internal/middleware"
+	"github.com/fehepe/flight-price-service/internal/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestUsage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	limiter := ratelimit.NewLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	tiers, _ := ratelimit.ParseTiers("user=5", "user=100", time.Minute)
+	policy := ratelimit.Policy{Tiers: tiers, Tenants: map[string]ratelimit.TenantLimits{"acme": {DailyQuota: 10}}}
+	keys, _ := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	token, _, _ := auth.NewTokenIssuer(keys, "test", time.Hour).Issue(auth.User{Username: "alice", Roles: []string{auth.RoleUser}, Tenant: "acme"}, "")
+
+	requireAuth := middleware.Auth(keys)
+	search := requireAuth(middleware.RateLimit(limiter, policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	usage := requireAuth(http.HandlerFunc(handlers.NewUsageHandler(limiter, policy).Usage))
+	call := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for range 3 {
+		call(search, "/flights/search")
+	}
+	for range 2 {
+		rr := call(usage, "/account/usage")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+		var got handlers.UsageResponse
+		json.NewDecoder(rr.Body).Decode(&got)
+		if got.Subject != "alice" || got.Tenant != "acme" || got.Tier != auth.RoleUser {
+			t.Errorf("unexpected account %+v", got)
+		}
+		if got.RateLimit.Limit != 5 || got.RateLimit.Remaining != 2 || got.RateLimit.WindowSeconds != 60 {
+			t.Errorf("unexpected rate limit %+v", got.RateLimit)
+		}
+		if got.DailyQuota.Used != 3 || got.DailyQuota.Remaining != 97 {
+			t.Errorf("unexpected quota %+v", got.DailyQuota)
+		}
+		if got.TenantQuota == nil || got.TenantQuota.Used != 3 || got.TenantQuota.Remaining != 7 {
+			t.Errorf("unexpected tenant quota %+v", got.TenantQuota)
+		}
+	}
+
+	mr.Close()
+	if rr := call(usage, "/account/usage"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when redis is down, got %d", rr.Code)
+	}
+}
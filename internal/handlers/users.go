@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+const minPasswordLength = 12
+
+// UserHandler serves the user administration endpoints.
+type UserHandler struct {
+	store  auth.UserStore
+	hasher auth.PasswordHasher
+}
+
+func NewUserHandler(store auth.UserStore, hasher auth.PasswordHasher) *UserHandler {
+	return &UserHandler{store: store, hasher: hasher}
+}
+
+// UserResponse is a user as returned by the API, without its password hash.
+type UserResponse struct {
+	Username     string     `json:"username"`
+	Disabled     bool       `json:"disabled"`
+	Roles        []string   `json:"roles"`
+	Tenant       string     `json:"tenant,omitempty"`
+	Locked       bool       `json:"locked"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+	FailedLogins int        `json:"failed_logins"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// CreateUserRequest is the payload of POST /admin/users.
+type CreateUserRequest struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Disabled bool     `json:"disabled"`
+	Roles    []string `json:"roles"`
+	Tenant   string   `json:"tenant"`
+}
+
+// UpdateUserRequest is the payload of PATCH /admin/users/{username}. Only
+// the fields present are changed; an empty Tenant moves the user out of
+// its tenant and Unlock clears a lockout.
+type UpdateUserRequest struct {
+	Password *string  `json:"password"`
+	Disabled *bool    `json:"disabled"`
+	Roles    []string `json:"roles"`
+	Tenant   *string  `json:"tenant"`
+	Unlock   bool     `json:"unlock"`
+}
+
+func newUserResponse(u auth.User, now time.Time) UserResponse {
+	resp := UserResponse{
+		Username:     u.Username,
+		Disabled:     u.Disabled,
+		Roles:        u.Roles,
+		Tenant:       u.Tenant,
+		Locked:       u.Locked(now),
+		FailedLogins: u.FailedLogins,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+	if resp.Locked {
+		resp.LockedUntil = &u.LockedUntil
+	}
+	if !u.LastLoginAt.IsZero() {
+		resp.LastLoginAt = &u.LastLoginAt
+	}
+	return resp
+}
+
+// List returns every user, or only its tenant's users to a tenant's caller.
+func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
+	users, err := h.store.List(r.Context())
+	if err != nil {
+		respondUserError(w, r, err)
+		return
+	}
+	now := time.Now()
+	tenant := callerTenant(r)
+	resp := make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		if tenant == "" || u.Tenant == tenant {
+			resp = append(resp, newUserResponse(u, now))
+		}
+	}
+	utils.RespondJSON(w, http.StatusOK, resp)
+}
+
+func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user, err := h.get(r)
+	if err != nil {
+		respondUserError(w, r, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, newUserResponse(user, time.Now()))
+}
+
+func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		utils.RespondError(w, http.StatusBadRequest, "username is required")
+		return
+	}
+	if auth.ReservedUsername(req.Username) {
+		utils.RespondError(w, http.StatusBadRequest, "username must not start with apikey:")
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		utils.RespondError(w, http.StatusBadRequest, "password must be at least 12 characters")
+		return
+	}
+	if req.Roles == nil {
+		req.Roles = []string{auth.RoleUser}
+	}
+	roles, ok := validRoles(w, req.Roles)
+	if !ok {
+		return
+	}
+	tenant, ok := assignableTenant(w, r, req.Tenant)
+	if !ok {
+		return
+	}
+	hash, err := h.hasher.Hash(req.Password)
+	if err != nil {
+		respondUserError(w, r, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	user := auth.User{Username: req.Username, PasswordHash: hash, Disabled: req.Disabled, Roles: roles,
+		Tenant: tenant, CreatedAt: now, UpdatedAt: now}
+	if err := h.store.Create(r.Context(), user); err != nil {
+		respondUserError(w, r, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, newUserResponse(user, now))
+}
+
+func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+	user, err := h.get(r)
+	if err != nil {
+		respondUserError(w, r, err)
+		return
+	}
+
+	ok := true
+	if req.Password != nil {
+		if len(*req.Password) < minPasswordLength {
+			utils.RespondError(w, http.StatusBadRequest, "password must be at least 12 characters")
+			return
+		}
+		if user.PasswordHash, err = h.hasher.Hash(*req.Password); err != nil {
+			respondUserError(w, r, err)
+			return
+		}
+	}
+	if req.Disabled != nil {
+		user.Disabled = *req.Disabled
+	}
+	if req.Roles != nil {
+		if user.Roles, ok = validRoles(w, req.Roles); !ok {
+			return
+		}
+	}
+	if req.Tenant != nil {
+		if callerTenant(r) != "" && strings.TrimSpace(*req.Tenant) == "" {
+			utils.RespondError(w, http.StatusForbidden, "cannot move users out of your tenant")
+			return
+		}
+		if user.Tenant, ok = assignableTenant(w, r, *req.Tenant); !ok {
+			return
+		}
+	}
+	if req.Unlock {
+		user.LockedUntil = time.Time{}
+		user.FailedLogins = 0
+	}
+	now := time.Now().UTC()
+	user.UpdatedAt = now
+
+	if err := h.store.Update(r.Context(), user); err != nil {
+		respondUserError(w, r, err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, newUserResponse(user, now))
+}
+
+func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.get(r); err != nil {
+		respondUserError(w, r, err)
+		return
+	}
+	if err := h.store.Delete(r.Context(), mux.Vars(r)["username"]); err != nil {
+		respondUserError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// get returns the user named in the path. Users outside the caller's
+// tenant are reported as not found to a tenant's caller.
+func (h *UserHandler) get(r *http.Request) (auth.User, error) {
+	user, err := h.store.Get(r.Context(), mux.Vars(r)["username"])
+	if err != nil {
+		return auth.User{}, err
+	}
+	if tenant := callerTenant(r); tenant != "" && user.Tenant != tenant {
+		return auth.User{}, auth.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// assignableTenant returns the tenant a user may be put in. A tenant's
+// caller can only keep users in its own tenant and responds 403 otherwise.
+func assignableTenant(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	requested = strings.TrimSpace(requested)
+	tenant := callerTenant(r)
+	if tenant == "" {
+		return requested, true
+	}
+	if requested != "" && requested != tenant {
+		utils.RespondError(w, http.StatusForbidden, "cannot assign users to another tenant")
+		return "", false
+	}
+	return tenant, true
+}
+
+// validRoles deduplicates roles and responds 400 if any is unknown.
+func validRoles(w http.ResponseWriter, roles []string) ([]string, bool) {
+	roles = normalizeScopes(roles)
+	for _, role := range roles {
+		if !auth.KnownRole(role) {
+			utils.RespondError(w, http.StatusBadRequest, "unknown role "+role)
+			return nil, false
+		}
+	}
+	return roles, true
+}
+
+func respondUserError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		utils.RespondError(w, http.StatusNotFound, "user not found")
+	case errors.Is(err, auth.ErrUserExists):
+		utils.RespondError(w, http.StatusConflict, "user already exists")
+	default:
+		log.Printf("%s %s user store error: %v\n", r.Method, r.RequestURI, err)
+		utils.RespondError(w, http.StatusInternalServerError, "user store error")
+	}
+}
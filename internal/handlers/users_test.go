@@ -0,0 +1,154 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/internal/handlers"
+	"github.com/fehepe/flight-price-service/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+func newUserRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	store, err := auth.NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uh := handlers.NewUserHandler(store, auth.PasswordHasher{Algorithm: auth.AlgorithmBcrypt, BcryptCost: 4})
+	r := mux.NewRouter()
+	r.HandleFunc("/admin/users", uh.List).Methods(http.MethodGet)
+	r.HandleFunc("/admin/users", uh.Create).Methods(http.MethodPost)
+	r.HandleFunc("/admin/users/{username}", uh.Get).Methods(http.MethodGet)
+	r.HandleFunc("/admin/users/{username}", uh.Update).Methods(http.MethodPatch)
+	r.HandleFunc("/admin/users/{username}", uh.Delete).Methods(http.MethodDelete)
+	return r
+}
+
+func TestUserHandler(t *testing.T) {
+	r := newUserRouter(t)
+
+	steps := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/admin/users", `{"username":"alice","password":"short"}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/users", `{"username":"","password":"long enough pw"}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/users", `{"username":"apikey:0123abcd4567","password":"long enough pw"}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/users", `{"username":"alice","password":"long enough pw","roles":["pilot"]}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/users", `{"username":"alice","password":"long enough pw"}`, http.StatusCreated},
+		{http.MethodPost, "/admin/users", `{"username":"alice","password":"long enough pw"}`, http.StatusConflict},
+		{http.MethodGet, "/admin/users/alice", "", http.StatusOK},
+		{http.MethodPatch, "/admin/users/alice", `{"disabled":true,"unlock":true}`, http.StatusOK},
+		{http.MethodPatch, "/admin/users/alice", `{"roles":["operator","nope"]}`, http.StatusBadRequest},
+		{http.MethodPatch, "/admin/users/bob", `{"disabled":true}`, http.StatusNotFound},
+		{http.MethodGet, "/admin/users", "", http.StatusOK},
+		{http.MethodDelete, "/admin/users/alice", "", http.StatusNoContent},
+		{http.MethodGet, "/admin/users/alice", "", http.StatusNotFound},
+	}
+	for _, s := range steps {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(s.method, s.path, bytes.NewBufferString(s.body)))
+		if rr.Code != s.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", s.method, s.path, s.want, rr.Code, rr.Body)
+		}
+		if strings.Contains(rr.Body.String(), "$2a$") {
+			t.Errorf("%s %s: response exposes the password hash: %s", s.method, s.path, rr.Body)
+		}
+	}
+}
+
+func TestUserHandler_Update(t *testing.T) {
+	r := newUserRouter(t)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/users",
+		bytes.NewBufferString(`{"username":"alice","password":"long enough pw"}`)))
+
+	var created handlers.UserResponse
+	json.NewDecoder(rr.Body).Decode(&created)
+	if !reflect.DeepEqual(created.Roles, []string{auth.RoleUser}) {
+		t.Errorf("expected new users to get the user role, got %v", created.Roles)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPatch, "/admin/users/alice",
+		bytes.NewBufferString(`{"disabled":true,"roles":["operator","operator"]}`)))
+
+	var resp handlers.UserResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Disabled || !reflect.DeepEqual(resp.Roles, []string{auth.RoleOperator}) {
+		t.Errorf("expected a disabled operator, got %+v", resp)
+	}
+}
+
+func TestUserHandler_TenantScoping(t *testing.T) {
+	store, _ := auth.NewFileStore(filepath.Join(t.TempDir(), "users.json"))
+	uh := handlers.NewUserHandler(store, auth.PasswordHasher{Algorithm: auth.AlgorithmBcrypt, BcryptCost: 4})
+	local, _ := auth.NewKeySet(auth.NewHMACKey([]byte("testsecret")))
+	r := mux.NewRouter()
+	r.Use(middleware.Auth(local))
+	r.HandleFunc("/admin/users", uh.List).Methods(http.MethodGet)
+	r.HandleFunc("/admin/users", uh.Create).Methods(http.MethodPost)
+	r.HandleFunc("/admin/users/{username}", uh.Get).Methods(http.MethodGet)
+	r.HandleFunc("/admin/users/{username}", uh.Update).Methods(http.MethodPatch)
+	r.HandleFunc("/admin/users/{username}", uh.Delete).Methods(http.MethodDelete)
+
+	ctx := context.Background()
+	for _, u := range []auth.User{{Username: "root"}, {Username: "ops", Tenant: "acme"}, {Username: "eve", Tenant: "globex"}} {
+		u.Roles = []string{auth.RoleAdmin}
+		store.Create(ctx, u)
+	}
+	partner, _, _ := auth.NewTokenIssuer(local, "test", time.Hour).Issue(auth.User{Username: "ops", Roles: []string{auth.RoleAdmin}, Tenant: "acme"}, "sid")
+
+	steps := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/admin/users/root", "", http.StatusNotFound},
+		{http.MethodPatch, "/admin/users/eve", `{"password":"long enough pw"}`, http.StatusNotFound},
+		{http.MethodDelete, "/admin/users/root", "", http.StatusNotFound},
+		{http.MethodPatch, "/admin/users/ops", `{"tenant":""}`, http.StatusForbidden},
+		{http.MethodPatch, "/admin/users/ops", `{"tenant":"globex"}`, http.StatusForbidden},
+		{http.MethodPost, "/admin/users", `{"username":"mallory","password":"long enough pw","tenant":"globex"}`, http.StatusForbidden},
+		{http.MethodPost, "/admin/users", `{"username":"bob","password":"long enough pw"}`, http.StatusCreated},
+		{http.MethodPatch, "/admin/users/bob", `{"disabled":true}`, http.StatusOK},
+	}
+	for _, s := range steps {
+		req := httptest.NewRequest(s.method, s.path, bytes.NewBufferString(s.body))
+		req.Header.Set("Authorization", "Bearer "+partner)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != s.want {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", s.method, s.path, s.body, s.want, rr.Code, rr.Body)
+		}
+	}
+
+	if bob, _ := store.Get(ctx, "bob"); bob.Tenant != "acme" {
+		t.Errorf("expected a tenant admin's new user in its tenant, got %q", bob.Tenant)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+	req.Header.Set("Authorization", "Bearer "+partner)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var listed []handlers.UserResponse
+	json.NewDecoder(rr.Body).Decode(&listed)
+	for _, u := range listed {
+		if u.Tenant != "acme" {
+			t.Errorf("expected only acme users, got %+v", u)
+		}
+	}
+	if len(listed) != 2 {
+		t.Errorf("expected ops and bob, got %d users", len(listed))
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/cache"
+	"github.com/fehepe/flight-price-service/internal/providers"
+	"github.com/fehepe/flight-price-service/pkg/models"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+)
+
+const (
+	defaultWarmTop      = 200
+	defaultWarmBudget   = 100
+	defaultWarmAhead    = time.Minute
+	warmConcurrency     = 4
+	warmLockKey         = "warmer"
+	defaultWarmSeedDays = 7
+)
+
+// WarmRoute is a route kept warm regardless of how often it is searched.
+type WarmRoute struct {
+	Origin      string
+	Destination string
+}
+
+// WarmReport summarises one warming run.
+type WarmReport struct {
+	Searches   int            `json:"searches"`
+	Refreshed  int            `json:"refreshed"`
+	Fresh      int            `json:"fresh"`
+	OverBudget int            `json:"over_budget"`
+	Failed     int            `json:"failed"`
+	Calls      map[string]int `json:"calls"`
+	Duration   string         `json:"duration"`
+}
+
+// Warmer refreshes the most searched keys, plus a static seed list, before
+// their fresh window ends so popular searches keep hitting the cache. Each
+// run spends at most a fixed number of calls per provider.
+type Warmer struct {
+	h        *FlightHandler
+	top      int
+	ahead    time.Duration
+	budget   int
+	budgets  map[string]int
+	seeds    []WarmRoute
+	seedDays int
+	running  sync.Mutex
+}
+
+// WarmerOption configures a Warmer.
+type WarmerOption func(*Warmer)
+
+// WithWarmTop sets how many of the most searched searches each run considers.
+func WithWarmTop(n int) WarmerOption {
+	return func(w *Warmer) {
+		w.top = n
+	}
+}
+
+// WithWarmAhead refreshes entries whose fresh window ends within d.
+func WithWarmAhead(d time.Duration) WarmerOption {
+	return func(w *Warmer) {
+		w.ahead = d
+	}
+}
+
+// WithWarmBudgets caps the provider calls of a run: perProvider by name,
+// falling back to def for providers not listed.
+func WithWarmBudgets(def int, perProvider map[string]int) WarmerOption {
+	return func(w *Warmer) {
+		w.budget = def
+		w.budgets = perProvider
+	}
+}
+
+// WithWarmSeeds keeps the routes warm for each of the next days departure dates.
+func WithWarmSeeds(routes []WarmRoute, days int) WarmerOption {
+	return func(w *Warmer) {
+		w.seeds = routes
+		w.seedDays = days
+	}
+}
+
+func NewWarmer(h *FlightHandler, opts ...WarmerOption) *Warmer {
+	w := &Warmer{
+		h:        h,
+		top:      defaultWarmTop,
+		ahead:    defaultWarmAhead,
+		budget:   defaultWarmBudget,
+		seedDays: defaultWarmSeedDays,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Start runs the warmer every interval until ctx is done. With a shared
+// cache only one replica runs per interval.
+func (w *Warmer) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !w.claim(ctx, interval) {
+				continue
+			}
+			if report, ok := w.Run(ctx); ok {
+				log.Printf("cache warm: refreshed %d of %d searches in %s (%d over budget, %d failed)",
+					report.Refreshed, report.Searches, report.Duration, report.OverBudget, report.Failed)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// claim takes the cluster-wide warm lease for one interval. The lease is
+// left to expire rather than released, so replicas ticking later in the
+// same interval skip their run.
+func (w *Warmer) claim(ctx context.Context, interval time.Duration) bool {
+	locker, ok := w.h.cache.(cache.Locker)
+	if !ok {
+		return true
+	}
+	_, acquired, err := locker.Lock(ctx, warmLockKey, interval)
+	switch {
+	case errors.Is(err, cache.ErrUnavailable):
+		// Nothing could be stored, so warming would only spend quota.
+		return false
+	case err != nil:
+		log.Printf("cache warm lease unavailable, warming anyway: %v", err)
+		return true
+	}
+	return acquired
+}
+
+// Trigger runs the warmer immediately and reports what it did.
+func (w *Warmer) Trigger(rw http.ResponseWriter, r *http.Request) {
+	report, ok := w.Run(r.Context())
+	if !ok {
+		utils.RespondError(rw, http.StatusConflict, "cache warming already running")
+		return
+	}
+	utils.RespondJSON(rw, http.StatusOK, report)
+}
+
+// Run refreshes candidate keys once. It reports false without doing anything
+// when another run is in progress in this process.
+func (w *Warmer) Run(ctx context.Context) (WarmReport, bool) {
+	if !w.running.TryLock() {
+		return WarmReport{}, false
+	}
+	defer w.running.Unlock()
+
+	start := time.Now()
+	report := WarmReport{Calls: make(map[string]int)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, warmConcurrency)
+
+	for _, search := range w.candidates(ctx, start) {
+		report.Searches++
+		eligible := providers.Plan(w.h.providers, w.h.rules, search, start).Providers
+		for _, p := range eligible {
+			name := providers.NameOf(p)
+			key := w.h.namespace.ProviderKey(search, name)
+			if !w.due(ctx, key) {
+				report.Fresh++
+				continue
+			}
+			if report.Calls[name] >= w.budgetFor(name) {
+				report.OverBudget++
+				continue
+			}
+			report.Calls[name]++
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(p providers.Provider, search models.FlightSearch) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				_, err := w.h.refresh(ctx, key, p, search)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					log.Printf("cache warm %s error: %v", key, err)
+					report.Failed++
+					return
+				}
+				report.Refreshed++
+			}(p, search)
+		}
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	return report, true
+}
+
+// candidates returns the most searched upcoming searches followed by the
+// seed routes, without duplicates.
+func (w *Warmer) candidates(ctx context.Context, now time.Time) []models.FlightSearch {
+	var searches []models.FlightSearch
+	if tracker, ok := w.h.cache.(cache.PopularityTracker); ok {
+		popular, err := tracker.Popular(ctx, w.top)
+		if err != nil {
+			logCacheError(err, "cache warm popular searches error: %v", err)
+		}
+		for _, p := range popular {
+			searches = append(searches, p.Search)
+		}
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	for _, route := range w.seeds {
+		for d := 0; d < w.seedDays; d++ {
+			searches = append(searches, models.FlightSearch{
+				Origin:        route.Origin,
+				Destination:   route.Destination,
+				DepartureDate: today.AddDate(0, 0, d),
+			})
+		}
+	}
+
+	seen := make(map[string]bool, len(searches))
+	out := searches[:0]
+	for _, s := range searches {
+		key := cache.SearchKey(s)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// due reports whether the key is missing or leaves its fresh window within
+// the warm-ahead period. Failed entries are left alone so warming does not
+// retry a provider that is down. It peeks, so checking keys does not count
+// as cache hits or misses.
+func (w *Warmer) due(ctx context.Context, key string) bool {
+	entry, found, err := w.h.cache.Peek(ctx, key)
+	if err != nil {
+		logCacheError(err, "cache warm peek %s error: %v", key, err)
+		return false
+	}
+	if !found {
+		return true
+	}
+	if entry.Negative == cache.NegativeFailed {
+		return false
+	}
+	return time.Until(entry.FreshUntil) < w.ahead
+}
+
+func (w *Warmer) budgetFor(provider string) int {
+	if n, ok := w.budgets[provider]; ok {
+		return n
+	}
+	return w.budget
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cachemock "github.com/fehepe/flight-price-service/internal/cache/mock"
+	"github.com/fehepe/flight-price-service/internal/providers"
+)
+
+func TestWarmer(t *testing.T) {
+	ctx := context.Background()
+	date := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+
+	tests := []struct {
+		name          string
+		searches      []string
+		seeds         []WarmRoute
+		budget        int
+		ahead         time.Duration
+		wantSearches  int
+		wantRefreshed int
+		wantOver      int
+	}{
+		{
+			name:          "refreshes searched keys about to expire",
+			searches:      []string{"JFK-LAX", "JFK-LAX", "MIA-MAD"},
+			budget:        10,
+			ahead:         time.Hour,
+			wantSearches:  2,
+			wantRefreshed: 2,
+		},
+		{
+			name:         "leaves keys fresh beyond the window alone",
+			searches:     []string{"JFK-LAX"},
+			budget:       10,
+			ahead:        time.Second,
+			wantSearches: 1,
+		},
+		{
+			name:          "stops at the provider budget",
+			searches:      []string{"JFK-LAX", "JFK-LAX", "MIA-MAD", "BOS-SFO"},
+			budget:        1,
+			ahead:         time.Hour,
+			wantSearches:  3,
+			wantRefreshed: 1,
+			wantOver:      2,
+		},
+		{
+			name:          "warms seed routes that were never searched",
+			seeds:         []WarmRoute{{Origin: "JFK", Destination: "LAX"}},
+			budget:        10,
+			ahead:         time.Hour,
+			wantSearches:  2,
+			wantRefreshed: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &countingProvider{}
+			mc := cachemock.NewMockCache()
+			h := NewFlightHandler([]providers.Provider{provider}, mc)
+			for _, route := range tt.searches {
+				rec := httptest.NewRecorder()
+				h.GetFlights(rec, httptest.NewRequest(http.MethodGet, "/flights/search?origin="+route[:3]+"&destination="+route[4:]+"&date="+date, nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("search %s: expected status 200, got %d", route, rec.Code)
+				}
+			}
+			before := provider.calls.Load()
+			statsBefore, _ := mc.Stats(ctx)
+
+			w := NewWarmer(h, WithWarmBudgets(tt.budget, nil), WithWarmAhead(tt.ahead), WithWarmSeeds(tt.seeds, 2))
+			report, ok := w.Run(ctx)
+			if !ok {
+				t.Fatal("expected the run to start")
+			}
+			if report.Searches != tt.wantSearches || report.Refreshed != tt.wantRefreshed || report.OverBudget != tt.wantOver {
+				t.Errorf("unexpected report %+v", report)
+			}
+			if calls := provider.calls.Load() - before; int(calls) != tt.wantRefreshed {
+				t.Errorf("expected %d provider calls, got %d", tt.wantRefreshed, calls)
+			}
+			// Checking which keys are due must not skew the hit ratio.
+			if stats, _ := mc.Stats(ctx); stats.Redis != statsBefore.Redis {
+				t.Errorf("expected warming not to count hits or misses, got %+v then %+v", statsBefore.Redis, stats.Redis)
+			}
+		})
+	}
+}
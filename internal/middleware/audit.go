@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fehepe/flight-price-service/internal/audit"
+)
+
+// ClientIP returns the address of the caller. X-Forwarded-For is only
+// believed when trustForwarded is set, that is behind a proxy that
+// overwrites it.
+func ClientIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Audit records every request that is not a read as an admin action, with
+// the caller from Auth and the response status. Refused requests are
+// recorded too.
+func Audit(logger *audit.Logger, trustForwarded bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			event := audit.Event{
+				Type:    audit.TypeAdminAction,
+				Outcome: audit.OutcomeSuccess,
+				IP:      ClientIP(r, trustForwarded),
+				Details: map[string]string{
+					"method": r.Method,
+					"path":   r.URL.Path,
+					"status": strconv.Itoa(rec.status),
+				},
+			}
+			if claims, ok := FromContext(r.Context()); ok {
+				event.Actor, event.Tenant = claims.Subject, claims.Tenant
+			}
+			switch {
+			case rec.status == http.StatusUnauthorized || rec.status == http.StatusForbidden:
+				event.Outcome = audit.OutcomeDenied
+			case rec.status >= 400:
+				event.Outcome = audit.OutcomeFailure
+			}
+			logger.Record(r.Context(), event)
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
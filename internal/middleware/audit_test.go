@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fehepe/flight-price-service/internal/audit"
+	"github.com/fehepe/flight-price-service/internal/auth"
+)
+
+func TestAudit(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := audit.NewLogger(audit.NewWriterSink(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &auth.Claims{Tenant: "acme"}
+	admin.Subject = "ops"
+
+	tests := []struct {
+		name        string
+		method      string
+		status      int
+		wantRecord  bool
+		wantOutcome string
+	}{
+		{"read", http.MethodGet, http.StatusOK, false, ""},
+		{"write", http.MethodDelete, http.StatusNoContent, true, audit.OutcomeSuccess},
+		{"forbidden", http.MethodPost, http.StatusForbidden, true, audit.OutcomeDenied},
+		{"failed", http.MethodPatch, http.StatusBadRequest, true, audit.OutcomeFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			h := Audit(logger, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			req := httptest.NewRequest(tt.method, "/admin/cache", nil)
+			req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+			req = req.WithContext(context.WithValue(req.Context(), userContextKey, admin))
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !tt.wantRecord {
+				if out.Len() != 0 {
+					t.Errorf("expected no event, got %s", out.String())
+				}
+				return
+			}
+			var e audit.Event
+			if err := json.NewDecoder(&out).Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Type != audit.TypeAdminAction || e.Outcome != tt.wantOutcome || e.Actor != "ops" || e.Tenant != "acme" || e.IP != "203.0.113.7" {
+				t.Errorf("unexpected event %+v", e)
+			}
+			if e.Details["method"] != tt.method || e.Details["path"] != "/admin/cache" {
+				t.Errorf("expected the request in the details, got %v", e.Details)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:5000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if ip := ClientIP(req, false); ip != "192.0.2.1" {
+		t.Errorf("expected the forwarded header to be ignored, got %s", ip)
+	}
+	if ip := ClientIP(req, true); ip != "203.0.113.7" {
+		t.Errorf("expected the forwarded address, got %s", ip)
+	}
+}
@@ -2,13 +2,13 @@ package middleware
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
-	"github.com/fehepe/flight-price-service/internal/config"
+	"github.com/fehepe/flight-price-service/internal/auth"
 	"github.com/fehepe/flight-price-service/pkg/utils"
-	"github.com/golang-jwt/jwt/v4"
 )
 
 // contextKey is a private type to avoid collisions in context.
@@ -16,51 +16,104 @@ type contextKey string
 
 const userContextKey = contextKey("userClaims")
 
-// Auth is middleware that enforces a valid JWT in the Authorization header.
-func Auth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		header := r.Header.Get("Authorization")
-		if header == "" {
-			utils.RespondError(w, http.StatusUnauthorized, "missing Authorization header")
-			return
-		}
-		parts := strings.Fields(header)
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			utils.RespondError(w, http.StatusUnauthorized, "invalid Authorization header format")
-			return
-		}
-
-		tokenString := parts[1]
-		secret := config.Get("JWT_SECRET", "")
-		if secret == "" {
-			utils.RespondError(w, http.StatusInternalServerError, "JWT secret not configured")
-			return
-		}
-
-		token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+// TokenVerifier checks a bearer token and returns its claims.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*auth.Claims, error)
+}
+
+// RevocationChecker reports whether a token has been revoked before expiry.
+type RevocationChecker interface {
+	Revoked(ctx context.Context, claims *auth.Claims) (bool, error)
+}
+
+// AuthOption configures the Auth middleware.
+type AuthOption func(*authConfig)
+
+type authConfig struct {
+	revocations RevocationChecker
+	apiKeys     TokenVerifier
+}
+
+// APIKeyHeader carries an API key instead of a bearer token.
+const APIKeyHeader = "X-API-Key"
+
+// WithAPIKeys also accepts an API key in the X-API-Key header, checked by
+// verifier. A request carrying one is not checked for a bearer token.
+func WithAPIKeys(verifier TokenVerifier) AuthOption {
+	return func(cfg *authConfig) {
+		cfg.apiKeys = verifier
+	}
+}
+
+// WithRevocation rejects tokens the checker reports as revoked. If the check
+// fails the request is rejected, so a revoked token is never accepted.
+func WithRevocation(c RevocationChecker) AuthOption {
+	return func(cfg *authConfig) {
+		cfg.revocations = c
+	}
+}
+
+// Auth returns middleware that enforces a bearer token in the Authorization
+// header, accepted by verifier, or an API key when WithAPIKeys is set.
+func Auth(verifier TokenVerifier, opts ...AuthOption) func(http.Handler) http.Handler {
+	var cfg authConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := r.Header.Get(APIKeyHeader); key != "" && cfg.apiKeys != nil {
+				claims, err := cfg.apiKeys.Verify(r.Context(), key)
+				if errors.Is(err, auth.ErrInvalidAPIKey) {
+					utils.RespondError(w, http.StatusUnauthorized, "invalid API key")
+					return
+				} else if err != nil {
+					log.Printf("%s %s api key check error: %v\n", r.Method, r.RequestURI, err)
+					utils.RespondError(w, http.StatusServiceUnavailable, "cannot verify API key")
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, claims)))
+				return
+			}
+
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				utils.RespondError(w, http.StatusUnauthorized, "missing Authorization header")
+				return
+			}
+			parts := strings.Fields(header)
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				utils.RespondError(w, http.StatusUnauthorized, "invalid Authorization header format")
+				return
 			}
-			return []byte(secret), nil
+
+			claims, err := verifier.Verify(r.Context(), parts[1])
+			if err != nil {
+				utils.RespondError(w, http.StatusUnauthorized, "invalid token")
+				return
+			}
+
+			if cfg.revocations != nil {
+				revoked, err := cfg.revocations.Revoked(r.Context(), claims)
+				if err != nil {
+					log.Printf("%s %s revocation check error: %v\n", r.Method, r.RequestURI, err)
+					utils.RespondError(w, http.StatusServiceUnavailable, "cannot verify token revocation")
+					return
+				}
+				if revoked {
+					utils.RespondError(w, http.StatusUnauthorized, "token revoked")
+					return
+				}
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), userContextKey, claims))
+			next.ServeHTTP(w, r)
 		})
-		if err != nil || !token.Valid {
-			utils.RespondError(w, http.StatusUnauthorized, "invalid token")
-			return
-		}
-
-		claims, ok := token.Claims.(*jwt.RegisteredClaims)
-		if !ok {
-			utils.RespondError(w, http.StatusUnauthorized, "invalid token claims")
-			return
-		}
-
-		r = r.WithContext(context.WithValue(r.Context(), userContextKey, claims))
-		next.ServeHTTP(w, r)
-	})
+	}
 }
 
 // FromContext retrieves JWT claims stored in the context.
-func FromContext(ctx context.Context) (*jwt.RegisteredClaims, bool) {
-	claims, ok := ctx.Value(userContextKey).(*jwt.RegisteredClaims)
+func FromContext(ctx context.Context) (*auth.Claims, bool) {
+	claims, ok := ctx.Value(userContextKey).(*auth.Claims)
 	return claims, ok
 }
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+)
+
+// RequireScope rejects requests whose claims, set by Auth, lack scope.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return require(func(c *auth.Claims) bool { return c.HasScope(scope) }, "missing scope "+scope)
+}
+
+// RequireRole rejects requests whose claims, set by Auth, lack role.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return require(func(c *auth.Claims) bool { return c.HasRole(role) }, "missing role "+role)
+}
+
+// RequireNoTenant rejects callers that belong to a tenant, for endpoints
+// whose effects reach across tenants.
+func RequireNoTenant() func(http.Handler) http.Handler {
+	return require(func(c *auth.Claims) bool { return c.Tenant == "" }, "not available to tenant callers")
+}
+
+func require(allowed func(*auth.Claims) bool, message string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				utils.RespondError(w, http.StatusUnauthorized, "not authenticated")
+				return
+			}
+			if !allowed(claims) {
+				utils.RespondError(w, http.StatusForbidden, message)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
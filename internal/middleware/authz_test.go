@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+)
+
+func TestRequireScopeAndRole(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	operator := &auth.Claims{Roles: []string{auth.RoleOperator}, Scopes: auth.ScopesForRoles([]string{auth.RoleOperator})}
+	apiKey := &auth.Claims{Scopes: []string{auth.ScopeFlightsSearch}}
+	partner := &auth.Claims{Roles: []string{auth.RoleAdmin}, Tenant: "acme"}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		claims  *auth.Claims
+		want    int
+	}{
+		{"scope held", RequireScope(auth.ScopeCacheWrite)(ok), operator, http.StatusOK},
+		{"scope missing", RequireScope(auth.ScopeUsersManage)(ok), operator, http.StatusForbidden},
+		{"api key scope", RequireScope(auth.ScopeFlightsSearch)(ok), apiKey, http.StatusOK},
+		{"role held", RequireRole(auth.RoleOperator)(ok), operator, http.StatusOK},
+		{"role missing", RequireRole(auth.RoleAdmin)(ok), operator, http.StatusForbidden},
+		{"api keys have no role", RequireRole(auth.RoleUser)(ok), apiKey, http.StatusForbidden},
+		{"no tenant", RequireNoTenant()(ok), operator, http.StatusOK},
+		{"tenant caller", RequireNoTenant()(ok), partner, http.StatusForbidden},
+		{"not authenticated", RequireScope(auth.ScopeFlightsSearch)(ok), nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.claims != nil {
+				req = req.WithContext(context.WithValue(req.Context(), userContextKey, tt.claims))
+			}
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
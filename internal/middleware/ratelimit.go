@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/ratelimit"
+	"github.com/fehepe/flight-price-service/pkg/utils"
+)
+
+// RateLimit limits each account, identified by the claims set by Auth, to
+// its tier's request rate and daily quota, and a tenant's accounts together
+// to the tenant's daily quota. Every response carries the X-RateLimit-*
+// headers; refused requests get 429 with Retry-After. If Redis cannot be
+// reached requests are let through, as the cache does.
+func RateLimit(limiter *ratelimit.Limiter, policy ratelimit.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				utils.RespondError(w, http.StatusUnauthorized, "not authenticated")
+				return
+			}
+			account := ratelimit.Account(claims)
+			tier := policy.Tier(claims)
+
+			res, err := limiter.Allow(r.Context(), account, tier)
+			if err != nil {
+				log.Printf("%s %s rate limit unavailable, allowing: %v\n", r.Method, r.RequestURI, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if tier.Rate > 0 {
+				w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+				w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			}
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.Reset)))
+				utils.RespondError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+
+			if tier.DailyQuota > 0 && !useQuota(w, r, limiter, account, tier, "daily search quota exceeded") {
+				return
+			}
+			if tenantAccount, tenantTier, ok := policy.TenantQuota(claims); ok &&
+				!useQuota(w, r, limiter, tenantAccount, tenantTier, "tenant daily search quota exceeded") {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// useQuota counts a search against a daily quota and responds 429 with
+// message when it is spent.
+func useQuota(w http.ResponseWriter, r *http.Request, limiter *ratelimit.Limiter, account string, tier ratelimit.Tier, message string) bool {
+	quota, err := limiter.UseQuota(r.Context(), account, tier)
+	if err != nil {
+		log.Printf("%s %s daily quota unavailable, allowing: %v\n", r.Method, r.RequestURI, err)
+		return true
+	}
+	if !quota.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(time.Until(quota.ResetsAt))))
+		utils.RespondError(w, http.StatusTooManyRequests, message)
+		return false
+	}
+	return true
+}
+
+// ceilSeconds rounds d up to whole seconds, and to at least 1.
+func ceilSeconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
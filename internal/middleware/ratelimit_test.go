@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/internal/ratelimit"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRateLimit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	limiter := ratelimit.NewLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	tiers := ratelimit.Tiers{
+		auth.RoleUser:  {Name: auth.RoleUser, Rate: 2, Window: time.Minute, DailyQuota: 10},
+		auth.RoleAdmin: {Name: auth.RoleAdmin, DailyQuota: 1},
+	}
+	policy := ratelimit.Policy{Tiers: tiers, Tenants: map[string]ratelimit.TenantLimits{
+		"acme": {Tiers: tiers.Override(map[string]int{auth.RoleUser: 0}, nil, time.Minute), DailyQuota: 3},
+	}}
+	handler := RateLimit(limiter, policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	call := func(sub, role string, tenant ...string) *httptest.ResponseRecorder {
+		claims := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: sub}, Roles: []string{role}}
+		if len(tenant) > 0 {
+			claims.Tenant = tenant[0]
+		}
+		req := httptest.NewRequest(http.MethodGet, "/flights/search", nil)
+		req = req.WithContext(context.WithValue(req.Context(), userContextKey, claims))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := range 2 {
+		rr := call("alice", auth.RoleUser)
+		if rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: got %d with headers %v", i, rr.Code, rr.Header())
+		}
+	}
+	rr := call("alice", auth.RoleUser)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" || rr.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("missing rate limit headers: %v", rr.Header())
+	}
+
+	if rr := call("root", auth.RoleAdmin); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("expected an unlimited rate without headers, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := call("root", auth.RoleAdmin); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("expected the daily quota to refuse, got %d", rr.Code)
+	}
+
+	// acme lifts the user rate limit but caps its accounts at 3 searches a day.
+	for i, sub := range []string{"carol", "carol", "carol", "dave"} {
+		want := http.StatusOK
+		if i == 3 {
+			want = http.StatusTooManyRequests
+		}
+		if rr := call(sub, auth.RoleUser, "acme"); rr.Code != want {
+			t.Errorf("tenant request %d: expected %d, got %d", i, want, rr.Code)
+		}
+	}
+
+	mr.Close()
+	if rr := call("bob", auth.RoleUser); rr.Code != http.StatusOK {
+		t.Errorf("expected requests to pass when redis is down, got %d", rr.Code)
+	}
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 
 	"github.com/fehepe/flight-price-service/pkg/models"
 )
@@ -10,3 +11,6 @@ import (
 type Provider interface {
 	GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error)
 }
+
+// ErrNoFlights is returned by providers that report an empty result as an error.
+var ErrNoFlights = errors.New("no flight offers found")
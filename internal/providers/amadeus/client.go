@@ -9,41 +9,67 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fehepe/flight-price-service/internal/providers"
 	"github.com/fehepe/flight-price-service/pkg/models"
+	"golang.org/x/sync/singleflight"
 )
 
 type token struct {
 	AccessToken string
 	ExpiresAt   time.Time
+	// ClientID is the API key the token was issued to.
+	ClientID string
 }
 
+// tokenTimeout bounds a token request, which runs detached from the search
+// that started it because concurrent searches share it.
+const tokenTimeout = 10 * time.Second
+
 type Client struct {
-	apiKey           string
-	apiSecret        string
+	keys             func() (apiKey, apiSecret string)
 	baseURL          string
 	maxFlightResults string
 	httpClient       *http.Client
-	token            *token
+	tokenFetches     singleflight.Group
+
+	mu    sync.Mutex
+	token *token
 }
 
 func New(apiKey, apiSecret, baseURL, maxResults string, httpClient *http.Client) providers.Provider {
+	return NewRotating(func() (string, string) { return apiKey, apiSecret }, baseURL, maxResults, httpClient)
+}
+
+// NewRotating returns a client that asks keys for the API key and secret
+// whenever it needs a token, so rotated credentials are picked up without
+// rebuilding it. A cached token issued to another key is discarded.
+func NewRotating(keys func() (apiKey, apiSecret string), baseURL, maxResults string, httpClient *http.Client) providers.Provider {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: 10 * time.Second}
 	}
 	return &Client{
-		apiKey:           apiKey,
-		apiSecret:        apiSecret,
+		keys:             keys,
 		baseURL:          baseURL,
 		maxFlightResults: maxResults,
 		httpClient:       httpClient,
 	}
 }
 
+// Name returns the provider's display name.
+func (c *Client) Name() string {
+	return "Amadeus"
+}
+
+// Capabilities reports that Amadeus serves every cabin, currency and region.
+func (c *Client) Capabilities() providers.Capabilities {
+	return providers.Capabilities{RoundTrip: true}
+}
+
 func (c *Client) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
-	token, err := c.getToken()
+	token, err := c.getToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("token retrieval failed: %w", err)
 	}
@@ -59,6 +85,15 @@ func (c *Client) GetFlights(ctx context.Context, search models.FlightSearch) ([]
 	params.Set("departureDate", search.DepartureDate.Format("2006-01-02"))
 	params.Set("adults", "1")
 	params.Set("max", c.maxFlightResults)
+	if search.IsRoundTrip() {
+		params.Set("returnDate", search.ReturnDate.Format("2006-01-02"))
+	}
+	if search.Cabin != "" {
+		params.Set("travelClass", strings.ToUpper(search.Cabin))
+	}
+	if search.Currency != "" {
+		params.Set("currencyCode", search.Currency)
+	}
 	u.RawQuery = params.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
@@ -91,7 +126,7 @@ func (c *Client) GetFlights(ctx context.Context, search models.FlightSearch) ([]
 		}
 		seg := d.Itineraries[0].Segments[0]
 		offers = append(offers, models.FlightOffer{
-			Provider:    "Amadeus",
+			Provider:    c.Name(),
 			Price:       parsePrice(d.Price.Total),
 			Duration:    d.Itineraries[0].Duration,
 			Origin:      seg.Departure.IataCode,
@@ -103,20 +138,40 @@ func (c *Client) GetFlights(ctx context.Context, search models.FlightSearch) ([]
 	return offers, nil
 }
 
-func (c *Client) getToken() (string, error) {
-	if c.token != nil && time.Now().Before(c.token.ExpiresAt) {
-		return c.token.AccessToken, nil
+// getToken returns the cached token while it is valid for the current key.
+// Otherwise concurrent callers share one token request per key.
+func (c *Client) getToken(ctx context.Context) (string, error) {
+	apiKey, apiSecret := c.keys()
+	c.mu.Lock()
+	t := c.token
+	c.mu.Unlock()
+	if t != nil && t.ClientID == apiKey && time.Now().Before(t.ExpiresAt) {
+		return t.AccessToken, nil
+	}
+
+	ch := c.tokenFetches.DoChan(apiKey, func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenTimeout)
+		defer cancel()
+		return c.fetchNewToken(fetchCtx, apiKey, apiSecret)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
 	}
-	return c.fetchNewToken()
 }
 
-func (c *Client) fetchNewToken() (string, error) {
+func (c *Client) fetchNewToken(ctx context.Context, apiKey, apiSecret string) (string, error) {
 	form := url.Values{}
 	form.Set("grant_type", "client_credentials")
-	form.Set("client_id", c.apiKey)
-	form.Set("client_secret", c.apiSecret)
+	form.Set("client_id", apiKey)
+	form.Set("client_secret", apiSecret)
 
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/v1/security/oauth2/token", c.baseURL), strings.NewReader(form.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/v1/security/oauth2/token", c.baseURL), strings.NewReader(form.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("token request creation failed: %w", err)
 	}
@@ -144,8 +199,11 @@ func (c *Client) fetchNewToken() (string, error) {
 	t := &token{
 		AccessToken: tr.AccessToken,
 		ExpiresAt:   time.Now().Add(time.Duration(tr.ExpiresIn-30) * time.Second),
+		ClientID:    apiKey,
 	}
+	c.mu.Lock()
 	c.token = t
+	c.mu.Unlock()
 	return t.AccessToken, nil
 }
 
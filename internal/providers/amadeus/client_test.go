@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -109,3 +111,69 @@ func TestGetFlights_TokenError(t *testing.T) {
 		t.Errorf("expected token retrieval failed, got: %v", err)
 	}
 }
+
+func TestGetFlights_RotatedKeyFetchesNewToken(t *testing.T) {
+	var clientIDs []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Path, "/token") {
+			r.ParseForm()
+			clientIDs = append(clientIDs, r.PostForm.Get("client_id"))
+			w.Write([]byte(`{"access_token":"token-` + r.PostForm.Get("client_id") + `","expires_in":3600}`))
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	t.Cleanup(mockServer.Close)
+
+	key := "old-key"
+	client := amadeus.NewRotating(func() (string, string) { return key, "secret" }, mockServer.URL, "10", mockServer.Client())
+	search := models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: time.Now().AddDate(0, 0, 1)}
+
+	client.GetFlights(context.Background(), search)
+	client.GetFlights(context.Background(), search)
+	key = "new-key"
+	client.GetFlights(context.Background(), search)
+
+	if len(clientIDs) != 2 || clientIDs[0] != "old-key" || clientIDs[1] != "new-key" {
+		t.Errorf("expected one token per key, got requests for %v", clientIDs)
+	}
+}
+
+func TestGetFlights_ConcurrentSearchesShareToken(t *testing.T) {
+	var tokenRequests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Path, "/token") {
+			tokenRequests.Add(1)
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte(`{"access_token":"mock-token","expires_in":3600}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer mock-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	t.Cleanup(mockServer.Close)
+
+	client := amadeus.New("fake-api-key", "fake-api-secret", mockServer.URL, "10", mockServer.Client())
+	search := models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: time.Now().AddDate(0, 0, 1)}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetFlights(context.Background(), search); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("expected concurrent searches to share one token request, got %d", n)
+	}
+}
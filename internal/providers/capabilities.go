@@ -0,0 +1,90 @@
+package providers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fehepe/flight-price-service/internal/geo"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+// Capabilities describes which searches a provider is able to serve.
+// Empty lists mean the provider places no restriction on that dimension.
+type Capabilities struct {
+	RoundTrip    bool         `json:"round_trip"`
+	CabinClasses []string     `json:"cabin_classes,omitempty"`
+	Currencies   []string     `json:"currencies,omitempty"`
+	Regions      []geo.Region `json:"regions,omitempty"`
+}
+
+// CapabilityReporter is implemented by providers that declare their capabilities.
+// Providers that do not implement it are assumed to serve every search.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// Named is implemented by providers that expose a display name.
+type Named interface {
+	Name() string
+}
+
+// NameOf returns the provider's display name, falling back to its Go type.
+func NameOf(p Provider) string {
+	if n, ok := p.(Named); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// Supports reports whether the capabilities allow the given search.
+// When they do not, the returned reason explains why.
+func (c Capabilities) Supports(search models.FlightSearch) (bool, string) {
+	if search.IsRoundTrip() && !c.RoundTrip {
+		return false, "round-trip searches not supported"
+	}
+	if search.Cabin != "" && len(c.CabinClasses) > 0 && !slices.Contains(c.CabinClasses, search.Cabin) {
+		return false, fmt.Sprintf("cabin %q not supported", search.Cabin)
+	}
+	if search.Currency != "" && len(c.Currencies) > 0 && !slices.Contains(c.Currencies, search.Currency) {
+		return false, fmt.Sprintf("currency %q not supported", search.Currency)
+	}
+	if len(c.Regions) > 0 {
+		for _, code := range []string{search.Origin, search.Destination} {
+			airport, ok := geo.Lookup(code)
+			if ok && !slices.Contains(c.Regions, airport.Region) {
+				return false, fmt.Sprintf("region %q of %s not served", airport.Region, code)
+			}
+		}
+	}
+	return true, ""
+}
+
+// Select splits the providers into those able to serve the search and those
+// skipped, recording the reason for each skipped provider.
+func Select(providerList []Provider, search models.FlightSearch) ([]Provider, []models.SkippedProvider) {
+	eligible := make([]Provider, 0, len(providerList))
+	var skipped []models.SkippedProvider
+	for _, p := range providerList {
+		cr, ok := p.(CapabilityReporter)
+		if !ok {
+			eligible = append(eligible, p)
+			continue
+		}
+		if ok, reason := cr.Capabilities().Supports(search); !ok {
+			skipped = append(skipped, models.SkippedProvider{Provider: NameOf(p), Reason: reason})
+			continue
+		}
+		eligible = append(eligible, p)
+	}
+	return eligible, skipped
+}
+
+// SkippedSummary formats skipped providers for logging.
+func SkippedSummary(skipped []models.SkippedProvider) string {
+	parts := make([]string, len(skipped))
+	for i, s := range skipped {
+		parts[i] = s.Provider + ": " + s.Reason
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,85 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/geo"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+type stubProvider struct {
+	name string
+	caps *Capabilities
+}
+
+func (s stubProvider) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
+	return nil, nil
+}
+
+func (s stubProvider) Name() string { return s.name }
+
+type capableStub struct{ stubProvider }
+
+func (c capableStub) Capabilities() Capabilities { return *c.caps }
+
+func TestSelect(t *testing.T) {
+	date := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	oneWayOnly := capableStub{stubProvider{name: "OneWay", caps: &Capabilities{CabinClasses: []string{models.CabinEconomy}}}}
+	americas := capableStub{stubProvider{name: "Americas", caps: &Capabilities{RoundTrip: true, Regions: []geo.Region{geo.NorthAmerica, geo.LatinAmerica}}}}
+	anything := stubProvider{name: "Anything"}
+	all := []Provider{oneWayOnly, americas, anything}
+
+	tests := []struct {
+		name        string
+		search      models.FlightSearch
+		wantNames   []string
+		wantSkipped int
+	}{
+		{
+			name:      "domestic one-way reaches everyone",
+			search:    models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: date},
+			wantNames: []string{"OneWay", "Americas", "Anything"},
+		},
+		{
+			name:        "round trip skips one-way provider",
+			search:      models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: date, ReturnDate: date.AddDate(0, 0, 3)},
+			wantNames:   []string{"Americas", "Anything"},
+			wantSkipped: 1,
+		},
+		{
+			name:        "business cabin and asia skip restricted providers",
+			search:      models.FlightSearch{Origin: "SYD", Destination: "BKK", DepartureDate: date, Cabin: models.CabinBusiness},
+			wantNames:   []string{"Anything"},
+			wantSkipped: 2,
+		},
+		{
+			name:      "unknown airports are not excluded by region",
+			search:    models.FlightSearch{Origin: "XXX", Destination: "YYY", DepartureDate: date},
+			wantNames: []string{"OneWay", "Americas", "Anything"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eligible, skipped := Select(all, tt.search)
+			if len(skipped) != tt.wantSkipped {
+				t.Errorf("expected %d skipped, got %d (%s)", tt.wantSkipped, len(skipped), SkippedSummary(skipped))
+			}
+			if len(eligible) != len(tt.wantNames) {
+				t.Fatalf("expected %d eligible providers, got %d", len(tt.wantNames), len(eligible))
+			}
+			for i, p := range eligible {
+				if NameOf(p) != tt.wantNames[i] {
+					t.Errorf("expected provider %q at %d, got %q", tt.wantNames[i], i, NameOf(p))
+				}
+			}
+			for _, s := range skipped {
+				if s.Reason == "" {
+					t.Errorf("expected a reason for skipped provider %s", s.Provider)
+				}
+			}
+		})
+	}
+}
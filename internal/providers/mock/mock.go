@@ -3,29 +3,96 @@ package mock
 import (
 	"context"
 	"errors"
+	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/fehepe/flight-price-service/internal/providers"
 	"github.com/fehepe/flight-price-service/pkg/models"
 )
 
+// ErrInjected is returned when a chaos scenario injects a provider failure.
+var ErrInjected = errors.New("mock provider injected error")
+
 // MockProvider returns dummy flight data for testing and local dev.
+// When a Scenario is set it injects latency and faults on every call.
 type MockProvider struct {
 	ShouldFail bool
+	Scenario   *Scenario
+
+	mu  sync.Mutex
+	rng *rand.Rand
 }
 
 func New(shouldFail bool) providers.Provider {
 	return &MockProvider{ShouldFail: shouldFail}
 }
 
+// NewChaos returns a mock provider that behaves according to the scenario.
+// The seed makes fault injection reproducible across runs.
+func NewChaos(scenario Scenario, seed int64) *MockProvider {
+	return &MockProvider{Scenario: &scenario, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Name returns the scenario name, so several chaos providers can run side by side.
+func (m *MockProvider) Name() string {
+	if m.Scenario != nil && m.Scenario.Name != "" {
+		return m.Scenario.Name
+	}
+	return "Mock"
+}
+
 // GetFlights returns mock flights or simulates an error.
 func (m *MockProvider) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
 	if m.ShouldFail {
 		return nil, errors.New("mock provider error")
 	}
+	if m.Scenario != nil {
+		return m.chaos(ctx, search)
+	}
+	return mockOffers(search), nil
+}
 
+// chaos applies the scenario: wait out the sampled latency, then roll for
+// a hang, an error, an empty result or malformed offers, in that order.
+func (m *MockProvider) chaos(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
+	s := m.Scenario
+	m.mu.Lock()
+	delay := s.Latency.sample(m.rng)
+	roll := m.rng.Float64()
+	m.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	switch {
+	case roll < s.HangRate:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case roll < s.HangRate+s.ErrorRate:
+		return nil, ErrInjected
+	case roll < s.HangRate+s.ErrorRate+s.EmptyRate:
+		return []models.FlightOffer{}, nil
+	case roll < s.HangRate+s.ErrorRate+s.EmptyRate+s.MalformedRate:
+		return malformedOffers(search), nil
+	}
+
+	offers := mockOffers(search)
+	if s.Name != "" {
+		for i := range offers {
+			offers[i].Provider = s.Name
+		}
+	}
+	return offers, nil
+}
+
+func mockOffers(search models.FlightSearch) []models.FlightOffer {
 	date := search.DepartureDate.Format("2006-01-02")
 
-	offers := []models.FlightOffer{
+	return []models.FlightOffer{
 		{
 			Provider:    "MockAir",
 			Price:       80.00,
@@ -43,6 +110,13 @@ func (m *MockProvider) GetFlights(ctx context.Context, search models.FlightSearc
 			Date:        date,
 		},
 	}
+}
 
-	return offers, nil
+// malformedOffers returns offers with the kinds of defects seen from real
+// upstreams: missing fields, negative prices and unparsable durations.
+func malformedOffers(search models.FlightSearch) []models.FlightOffer {
+	return []models.FlightOffer{
+		{Provider: "MockBroken", Price: -1, Duration: "PT16H30M", Origin: search.Origin, Destination: search.Destination},
+		{Provider: "MockBroken", Price: 99.99, Duration: "not-a-duration", Origin: "", Destination: search.Destination, Date: "2025-13-45"},
+	}
 }
@@ -0,0 +1,81 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestChaosScenarios(t *testing.T) {
+	search := models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: time.Now().AddDate(0, 0, 1)}
+
+	tests := []struct {
+		name       string
+		scenario   Scenario
+		wantErr    error
+		wantOffers int
+	}{
+		{name: "healthy", scenario: Scenario{Name: "Healthy"}, wantOffers: 2},
+		{name: "always errors", scenario: Scenario{ErrorRate: 1}, wantErr: ErrInjected},
+		{name: "always empty", scenario: Scenario{EmptyRate: 1}, wantOffers: 0},
+		{name: "always malformed", scenario: Scenario{MalformedRate: 1}, wantOffers: 2},
+		{name: "always hangs", scenario: Scenario{HangRate: 1}, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			offers, err := NewChaos(tt.scenario, 1).GetFlights(ctx, search)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if len(offers) != tt.wantOffers {
+				t.Errorf("expected %d offers, got %d", tt.wantOffers, len(offers))
+			}
+		})
+	}
+}
+
+func TestLatencyIsClamped(t *testing.T) {
+	p := NewChaos(Scenario{Latency: Latency{Distribution: LatencyNormal, MeanMS: 1000, StdDevMS: 1000, MinMS: 5, MaxMS: 20}}, 7)
+	start := time.Now()
+	if _, err := p.GetFlights(context.Background(), models.FlightSearch{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("expected latency clamped to [5ms, 20ms], took %v", elapsed)
+	}
+}
+
+func TestValidateRejectsBadRates(t *testing.T) {
+	if err := (Scenario{Name: "Flaky", ErrorRate: 0.7, EmptyRate: 0.5}).Validate(); err == nil {
+		t.Error("expected error when rates sum above 1")
+	}
+	if err := (Scenario{Name: "Slow", Latency: Latency{Distribution: "pareto"}}).Validate(); err == nil {
+		t.Error("expected error for unknown distribution")
+	}
+	if err := (Scenario{ErrorRate: 0.1}).Validate(); err == nil {
+		t.Error("expected error for a scenario without a name")
+	}
+}
+
+func TestLoadScenarios(t *testing.T) {
+	for body, wantErr := range map[string]bool{
+		`[{"name":"Slow"},{"name":"Flaky","error_rate":0.5}]`: false,
+		`[{"name":"Slow"},{"error_rate":0.5}]`:                true,
+		`[{"name":"Slow"},{"name":"Slow","error_rate":0.5}]`:  true,
+	} {
+		path := filepath.Join(t.TempDir(), "scenarios.json")
+		os.WriteFile(path, []byte(body), 0o600)
+		if _, err := LoadScenarios(path); (err != nil) != wantErr {
+			t.Errorf("%s: expected error %v, got %v", body, wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/config"
+)
+
+// Latency distributions supported by a Scenario.
+const (
+	LatencyFixed       = "fixed"
+	LatencyUniform     = "uniform"
+	LatencyNormal      = "normal"
+	LatencyExponential = "exponential"
+)
+
+// Scenario controls the faults a chaos MockProvider injects. Rates are
+// probabilities between 0 and 1 and are checked in the order hang, error,
+// empty, malformed; their sum must not exceed 1.
+type Scenario struct {
+	Name          string  `json:"name"`
+	Latency       Latency `json:"latency"`
+	HangRate      float64 `json:"hang_rate"`
+	ErrorRate     float64 `json:"error_rate"`
+	EmptyRate     float64 `json:"empty_rate"`
+	MalformedRate float64 `json:"malformed_rate"`
+}
+
+// Latency describes the delay injected before each call completes.
+// Fixed uses MeanMS, uniform draws between MinMS and MaxMS, normal uses
+// MeanMS and StdDevMS and exponential uses MeanMS. Samples are clamped to
+// [MinMS, MaxMS] when MaxMS is set.
+type Latency struct {
+	Distribution string `json:"distribution"`
+	MeanMS       int    `json:"mean_ms"`
+	StdDevMS     int    `json:"stddev_ms"`
+	MinMS        int    `json:"min_ms"`
+	MaxMS        int    `json:"max_ms"`
+}
+
+// Validate checks the scenario's name, rates and latency settings. The
+// name is required: it names the provider, and with it its cache entries.
+func (s Scenario) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("scenario name is required")
+	}
+	for name, rate := range map[string]float64{
+		"hang_rate": s.HangRate, "error_rate": s.ErrorRate,
+		"empty_rate": s.EmptyRate, "malformed_rate": s.MalformedRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("scenario %q: %s must be between 0 and 1", s.Name, name)
+		}
+	}
+	if s.HangRate+s.ErrorRate+s.EmptyRate+s.MalformedRate > 1 {
+		return fmt.Errorf("scenario %q: rates must not sum to more than 1", s.Name)
+	}
+	switch s.Latency.Distribution {
+	case "", LatencyFixed, LatencyUniform, LatencyNormal, LatencyExponential:
+	default:
+		return fmt.Errorf("scenario %q: unknown latency distribution %q", s.Name, s.Latency.Distribution)
+	}
+	return nil
+}
+
+// LoadScenarios reads a JSON array of scenarios from path. Names must be
+// unique, since providers sharing a name share cache entries and rules.
+func LoadScenarios(path string) ([]Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenarios: %w", err)
+	}
+	var scenarios []Scenario
+	if err := json.Unmarshal(data, &scenarios); err != nil {
+		return nil, fmt.Errorf("unmarshal scenarios: %w", err)
+	}
+	seen := make(map[string]bool, len(scenarios))
+	for _, s := range scenarios {
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("duplicate scenario name %q", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return scenarios, nil
+}
+
+// ScenarioFromEnv builds a single scenario from CHAOS_* environment variables.
+func ScenarioFromEnv() (Scenario, error) {
+	s := Scenario{
+		Name: config.Get("CHAOS_PROVIDER_NAME", "Chaos"),
+		Latency: Latency{
+			Distribution: config.Get("CHAOS_LATENCY_DISTRIBUTION", LatencyFixed),
+			MeanMS:       config.GetEnvInt("CHAOS_LATENCY_MEAN_MS", 0),
+			StdDevMS:     config.GetEnvInt("CHAOS_LATENCY_STDDEV_MS", 0),
+			MinMS:        config.GetEnvInt("CHAOS_LATENCY_MIN_MS", 0),
+			MaxMS:        config.GetEnvInt("CHAOS_LATENCY_MAX_MS", 0),
+		},
+		HangRate:      config.GetEnvFloat("CHAOS_HANG_RATE", 0),
+		ErrorRate:     config.GetEnvFloat("CHAOS_ERROR_RATE", 0),
+		EmptyRate:     config.GetEnvFloat("CHAOS_EMPTY_RATE", 0),
+		MalformedRate: config.GetEnvFloat("CHAOS_MALFORMED_RATE", 0),
+	}
+	return s, s.Validate()
+}
+
+func (l Latency) sample(rng *rand.Rand) time.Duration {
+	var ms float64
+	switch l.Distribution {
+	case LatencyUniform:
+		ms = float64(l.MinMS)
+		if l.MaxMS > l.MinMS {
+			ms += rng.Float64() * float64(l.MaxMS-l.MinMS)
+		}
+	case LatencyNormal:
+		ms = rng.NormFloat64()*float64(l.StdDevMS) + float64(l.MeanMS)
+	case LatencyExponential:
+		ms = rng.ExpFloat64() * float64(l.MeanMS)
+	default:
+		ms = float64(l.MeanMS)
+	}
+
+	ms = math.Max(ms, float64(l.MinMS))
+	if l.MaxMS > 0 {
+		ms = math.Min(ms, float64(l.MaxMS))
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
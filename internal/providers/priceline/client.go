@@ -22,12 +22,18 @@ const (
 )
 
 type Client struct {
-	apiKey  string
+	apiKey  func() string
 	baseURL string
 	client  *http.Client
 }
 
 func New(apiKey, baseURL string, httpClient *http.Client) providers.Provider {
+	return NewRotating(func() string { return apiKey }, baseURL, httpClient)
+}
+
+// NewRotating returns a client that asks apiKey for the key on every
+// request, so a rotated key is picked up without rebuilding it.
+func NewRotating(apiKey func() string, baseURL string, httpClient *http.Client) providers.Provider {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: defaultTimeout}
 	}
@@ -38,7 +44,21 @@ func New(apiKey, baseURL string, httpClient *http.Client) providers.Provider {
 	}
 }
 
-var ErrNoFlights = fmt.Errorf("no flight offers found")
+var ErrNoFlights = providers.ErrNoFlights
+
+// Name returns the provider's display name.
+func (c *Client) Name() string {
+	return providerName
+}
+
+// Capabilities reports that the one-way search endpoint only prices economy in USD.
+func (c *Client) Capabilities() providers.Capabilities {
+	return providers.Capabilities{
+		RoundTrip:    false,
+		CabinClasses: []string{models.CabinEconomy},
+		Currencies:   []string{"USD"},
+	}
+}
 
 func (c *Client) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
 	listings, err := c.fetchListings(ctx, search)
@@ -67,7 +87,7 @@ func (c *Client) fetchListings(ctx context.Context, search models.FlightSearch)
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("x-rapidapi-host", u.Host)
-	req.Header.Set("x-rapidapi-key", c.apiKey)
+	req.Header.Set("x-rapidapi-key", c.apiKey())
 
 	res, err := c.client.Do(req)
 	if err != nil {
@@ -0,0 +1,68 @@
+package recorder
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/providers"
+	"github.com/fehepe/flight-price-service/internal/providers/amadeus"
+	"github.com/fehepe/flight-price-service/internal/providers/priceline"
+	"github.com/fehepe/flight-price-service/internal/providers/serpapi"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+// fixturesDir holds the committed, redacted fixtures that
+// PROVIDER_HTTP_MODE=replay serves by default.
+const fixturesDir = "../../../testdata/fixtures"
+
+// TestCommittedFixtures runs every real client in replay mode against the
+// committed fixtures, recorded for JFK to LAX on 2027-03-15 with the base
+// URLs from .env.example.
+func TestCommittedFixtures(t *testing.T) {
+	search := models.FlightSearch{
+		Origin:        "JFK",
+		Destination:   "LAX",
+		DepartureDate: time.Date(2027, 3, 15, 0, 0, 0, 0, time.UTC),
+	}
+	client := &http.Client{Transport: NewReplayer(fixturesDir)}
+
+	tests := []struct {
+		provider providers.Provider
+		count    int
+		first    models.FlightOffer
+	}{
+		{
+			provider: amadeus.New("key", "secret", "https://test.api.amadeus.com", "10", client),
+			count:    3,
+			first:    models.FlightOffer{Provider: "Amadeus", Price: 198.34, Duration: "PT6H25M", Origin: "JFK", Destination: "LAX", Date: "2027-03-15"},
+		},
+		{
+			provider: serpapi.New("key", "https://serpapi.com", client),
+			count:    2,
+			first:    models.FlightOffer{Provider: "SerpAPI", Price: 219, Duration: "PT6H25M", Origin: "JFK", Destination: "LAX", Date: "2027-03-15"},
+		},
+		{
+			// The recorded response has a third listing without airlines, which is skipped.
+			provider: priceline.New("key", "https://priceline-com2.p.rapidapi.com", client),
+			count:    2,
+			first:    models.FlightOffer{Provider: "PriceLine", Price: 187.4, Duration: "PT6H11M", Origin: "JFK", Destination: "LAX", Date: "2027-03-15"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(providers.NameOf(tt.provider), func(t *testing.T) {
+			offers, err := tt.provider.GetFlights(context.Background(), search)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(offers) != tt.count {
+				t.Fatalf("expected %d offers, got %d: %v", tt.count, len(offers), offers)
+			}
+			if offers[0] != tt.first {
+				t.Errorf("expected first offer %v, got %v", tt.first, offers[0])
+			}
+		})
+	}
+}
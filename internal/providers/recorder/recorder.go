@@ -0,0 +1,262 @@
+package recorder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mode selects how provider HTTP traffic is handled.
+type Mode string
+
+const (
+	ModeOff    Mode = ""
+	ModeRecord Mode = "record"
+	ModeReplay Mode = "replay"
+)
+
+const redacted = "REDACTED"
+
+// sensitive lists query params, form fields and JSON fields that carry
+// credentials and must never be written to a fixture. Request headers are
+// not recorded at all.
+var sensitive = map[string]bool{
+	"api_key":        true,
+	"client_id":      true,
+	"client_secret":  true,
+	"access_token":   true,
+	"authorization":  true,
+	"x-rapidapi-key": true,
+}
+
+// ErrNoFixture is returned in replay mode when no fixture matches a request.
+var ErrNoFixture = errors.New("no recorded fixture for request")
+
+// Fixture is a recorded upstream exchange as stored on disk.
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+type FixtureRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type FixtureResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+// New returns a transport for the given mode, or next unchanged when the mode is off.
+func New(mode Mode, dir string, next http.RoundTripper) (http.RoundTripper, error) {
+	switch mode {
+	case ModeOff:
+		return next, nil
+	case ModeRecord:
+		return NewRecorder(dir, next), nil
+	case ModeReplay:
+		return NewReplayer(dir), nil
+	default:
+		return nil, fmt.Errorf("unknown recorder mode %q", mode)
+	}
+}
+
+// Recorder is an http.RoundTripper that forwards requests upstream and
+// saves every response as a redacted fixture file.
+type Recorder struct {
+	dir  string
+	next http.RoundTripper
+	mu   sync.Mutex
+}
+
+func NewRecorder(dir string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{dir: dir, next: next}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read upstream response: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	fx := Fixture{
+		Request: FixtureRequest{
+			Method: req.Method,
+			URL:    redactURL(req.URL),
+			Body:   redactForm(string(reqBody)),
+		},
+		Response: FixtureResponse{
+			Status:  res.StatusCode,
+			Headers: map[string]string{"Content-Type": res.Header.Get("Content-Type")},
+			Body:    redactJSON(resBody),
+		},
+	}
+	if err := r.save(fixtureName(req, reqBody), fx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *Recorder) save(name string, fx Fixture) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return fmt.Errorf("create fixture dir: %w", err)
+	}
+	data, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal fixture: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("write fixture: %w", err)
+	}
+	return nil
+}
+
+// Replayer is an http.RoundTripper that serves responses from fixture files
+// without touching the network.
+type Replayer struct {
+	dir string
+}
+
+func NewReplayer(dir string) *Replayer {
+	return &Replayer{dir: dir}
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fixtureName(req, reqBody)
+	data, err := os.ReadFile(filepath.Join(r.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s %s (%s)", ErrNoFixture, req.Method, redactURL(req.URL), name)
+	} else if err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+
+	var fx Fixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		return nil, fmt.Errorf("unmarshal fixture %s: %w", name, err)
+	}
+
+	header := make(http.Header, len(fx.Response.Headers))
+	for k, v := range fx.Response.Headers {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fx.Response.Status, http.StatusText(fx.Response.Status)),
+		StatusCode:    fx.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(fx.Response.Body)),
+		ContentLength: int64(len(fx.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// fixtureName derives a stable file name from the redacted request, so the
+// same search maps to the same fixture whatever credentials are configured.
+func fixtureName(req *http.Request, body []byte) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + redactURL(req.URL) + "\n" + redactForm(string(body))))
+	slug := nonWord.ReplaceAllString(req.URL.Host+req.URL.Path, "_")
+	return fmt.Sprintf("%s_%s.json", strings.Trim(slug, "_"), hex.EncodeToString(sum[:])[:12])
+}
+
+var nonWord = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// redactURL returns the URL with sensitive query values replaced and params sorted.
+func redactURL(u *url.URL) string {
+	clone := *u
+	clone.RawQuery = redactValues(u.Query())
+	return clone.String()
+}
+
+func redactForm(body string) string {
+	if body == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return body
+	}
+	return redactValues(values)
+}
+
+func redactValues(values url.Values) string {
+	for k := range values {
+		if sensitive[strings.ToLower(k)] {
+			values.Set(k, redacted)
+		}
+	}
+	return values.Encode()
+}
+
+// redactJSON blanks credential fields in a JSON body, such as OAuth tokens.
+func redactJSON(body []byte) string {
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return string(body)
+	}
+	changed := false
+	for k := range doc {
+		if sensitive[strings.ToLower(k)] {
+			doc[k] = redacted
+			changed = true
+		}
+	}
+	if !changed {
+		return string(body)
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
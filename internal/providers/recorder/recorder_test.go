@@ -0,0 +1,70 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/providers/serpapi"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+const serpAPIBody = `{"best_flights":[{"flights":[{"departure_airport":{"id":"JFK","time":"2025-06-01 08:00"},"arrival_airport":{"id":"LAX","time":"2025-06-01 11:20"}}],"total_duration":380,"price":210}]}`
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	search := models.FlightSearch{
+		Origin:        "JFK",
+		Destination:   "LAX",
+		DepartureDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(serpAPIBody))
+	}))
+
+	recording := serpapi.New("secret-key", upstream.URL, &http.Client{Transport: NewRecorder(dir, upstream.Client().Transport)})
+	recorded, err := recording.GetFlights(context.Background(), search)
+	if err != nil {
+		t.Fatalf("record: expected no error, got %v", err)
+	}
+	upstream.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 fixture, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if strings.Contains(string(data), "secret-key") {
+		t.Errorf("expected api key to be redacted from fixture, got %s", data)
+	}
+
+	// A different key must still hit the same fixture, and the upstream is gone.
+	replaying := serpapi.New("other-key", upstream.URL, &http.Client{Transport: NewReplayer(dir)})
+	replayed, err := replaying.GetFlights(context.Background(), search)
+	if err != nil {
+		t.Fatalf("replay: expected no error, got %v", err)
+	}
+	if len(replayed) != len(recorded) || replayed[0] != recorded[0] {
+		t.Errorf("expected replayed offers %v, got %v", recorded, replayed)
+	}
+
+	search.Destination = "SFO"
+	if _, err := replaying.GetFlights(context.Background(), search); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("expected ErrNoFixture for unrecorded search, got %v", err)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	got := redactJSON([]byte(`{"access_token":"abc123","expires_in":1799}`))
+	if strings.Contains(got, "abc123") || !strings.Contains(got, redacted) {
+		t.Errorf("expected access_token to be redacted, got %s", got)
+	}
+}
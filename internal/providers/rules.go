@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/geo"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+// Rule narrows the providers queried for searches matching its conditions.
+// Only restricts the search to the listed providers; Exclude removes them.
+type Rule struct {
+	Name    string    `json:"name"`
+	Match   RuleMatch `json:"match"`
+	Only    []string  `json:"only,omitempty"`
+	Exclude []string  `json:"exclude,omitempty"`
+}
+
+// RuleMatch lists the conditions a search must meet for a rule to apply.
+// Empty conditions match every search.
+type RuleMatch struct {
+	OriginCountries      []string     `json:"origin_countries,omitempty"`
+	DestinationCountries []string     `json:"destination_countries,omitempty"`
+	OriginRegions        []geo.Region `json:"origin_regions,omitempty"`
+	DestinationRegions   []geo.Region `json:"destination_regions,omitempty"`
+	Regions              []geo.Region `json:"regions,omitempty"`
+	Cabins               []string     `json:"cabins,omitempty"`
+	MinDaysAhead         *int         `json:"min_days_ahead,omitempty"`
+	MaxDaysAhead         *int         `json:"max_days_ahead,omitempty"`
+}
+
+// RuleSet is an ordered list of rules; every matching rule is applied in turn.
+type RuleSet []Rule
+
+// Selection is the outcome of choosing providers for a search.
+type Selection struct {
+	Providers    []Provider               `json:"-"`
+	Selected     []string                 `json:"selected"`
+	Skipped      []models.SkippedProvider `json:"skipped"`
+	MatchedRules []string                 `json:"matched_rules"`
+}
+
+// LoadRules reads a JSON rule set from path.
+func LoadRules(path string) (RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+	var rules RuleSet
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("unmarshal rules: %w", err)
+	}
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		}
+		if len(r.Only) == 0 && len(r.Exclude) == 0 {
+			return nil, fmt.Errorf("rule %q: one of only or exclude is required", r.Name)
+		}
+	}
+	return rules, nil
+}
+
+// Plan selects the providers to query for a search. Providers are first
+// filtered by their declared capabilities, then by the matching rules.
+func Plan(providerList []Provider, rules RuleSet, search models.FlightSearch, now time.Time) Selection {
+	eligible, skipped := Select(providerList, search)
+	sel := Selection{Skipped: skipped, MatchedRules: []string{}}
+	if sel.Skipped == nil {
+		sel.Skipped = []models.SkippedProvider{}
+	}
+
+	for _, rule := range rules {
+		if !rule.Match.matches(search, now) {
+			continue
+		}
+		sel.MatchedRules = append(sel.MatchedRules, rule.Name)
+
+		kept := make([]Provider, 0, len(eligible))
+		for _, p := range eligible {
+			name := NameOf(p)
+			switch {
+			case len(rule.Only) > 0 && !slices.Contains(rule.Only, name):
+				sel.Skipped = append(sel.Skipped, models.SkippedProvider{Provider: name, Reason: fmt.Sprintf("rule %q allows only %v", rule.Name, rule.Only)})
+			case slices.Contains(rule.Exclude, name):
+				sel.Skipped = append(sel.Skipped, models.SkippedProvider{Provider: name, Reason: fmt.Sprintf("excluded by rule %q", rule.Name)})
+			default:
+				kept = append(kept, p)
+			}
+		}
+		eligible = kept
+	}
+
+	sel.Providers = eligible
+	sel.Selected = make([]string, len(eligible))
+	for i, p := range eligible {
+		sel.Selected[i] = NameOf(p)
+	}
+	return sel
+}
+
+func (m RuleMatch) matches(search models.FlightSearch, now time.Time) bool {
+	origin, _ := geo.Lookup(search.Origin)
+	destination, _ := geo.Lookup(search.Destination)
+
+	if len(m.OriginCountries) > 0 && !slices.Contains(m.OriginCountries, origin.Country) {
+		return false
+	}
+	if len(m.DestinationCountries) > 0 && !slices.Contains(m.DestinationCountries, destination.Country) {
+		return false
+	}
+	if len(m.OriginRegions) > 0 && !slices.Contains(m.OriginRegions, origin.Region) {
+		return false
+	}
+	if len(m.DestinationRegions) > 0 && !slices.Contains(m.DestinationRegions, destination.Region) {
+		return false
+	}
+	if len(m.Regions) > 0 && !slices.Contains(m.Regions, origin.Region) && !slices.Contains(m.Regions, destination.Region) {
+		return false
+	}
+	if len(m.Cabins) > 0 && !slices.Contains(m.Cabins, cabinOrDefault(search.Cabin)) {
+		return false
+	}
+
+	daysAhead := int(search.DepartureDate.Sub(now.Truncate(24*time.Hour)).Hours() / 24)
+	if m.MinDaysAhead != nil && daysAhead < *m.MinDaysAhead {
+		return false
+	}
+	if m.MaxDaysAhead != nil && daysAhead > *m.MaxDaysAhead {
+		return false
+	}
+	return true
+}
+
+func cabinOrDefault(cabin string) string {
+	if cabin == "" {
+		return models.CabinEconomy
+	}
+	return cabin
+}
@@ -0,0 +1,84 @@
+package providers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/geo"
+	"github.com/fehepe/flight-price-service/pkg/models"
+)
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	all := []Provider{stubProvider{name: "Amadeus"}, stubProvider{name: "SerpAPI"}, stubProvider{name: "PriceLine"}}
+	farAhead := 300
+	rules := RuleSet{
+		{
+			Name:  "us-domestic",
+			Match: RuleMatch{OriginCountries: []string{"US"}, DestinationCountries: []string{"US"}},
+			Only:  []string{"SerpAPI"},
+		},
+		{
+			Name:    "no-priceline-apac",
+			Match:   RuleMatch{Regions: []geo.Region{geo.AsiaPacific}},
+			Exclude: []string{"PriceLine"},
+		},
+		{
+			Name:  "far-future",
+			Match: RuleMatch{MinDaysAhead: &farAhead},
+			Only:  []string{"Amadeus"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		search       models.FlightSearch
+		wantSelected []string
+		wantRules    []string
+	}{
+		{
+			name:         "us domestic only serpapi",
+			search:       models.FlightSearch{Origin: "JFK", Destination: "LAX", DepartureDate: now.AddDate(0, 0, 10)},
+			wantSelected: []string{"SerpAPI"},
+			wantRules:    []string{"us-domestic"},
+		},
+		{
+			name:         "asia-pacific excludes priceline",
+			search:       models.FlightSearch{Origin: "LAX", Destination: "SYD", DepartureDate: now.AddDate(0, 0, 10)},
+			wantSelected: []string{"Amadeus", "SerpAPI"},
+			wantRules:    []string{"no-priceline-apac"},
+		},
+		{
+			name:         "far future restricted to amadeus",
+			search:       models.FlightSearch{Origin: "MAD", Destination: "CDG", DepartureDate: now.AddDate(0, 0, 320)},
+			wantSelected: []string{"Amadeus"},
+			wantRules:    []string{"far-future"},
+		},
+		{
+			name:         "no rule matches",
+			search:       models.FlightSearch{Origin: "MAD", Destination: "CDG", DepartureDate: now.AddDate(0, 0, 10)},
+			wantSelected: []string{"Amadeus", "SerpAPI", "PriceLine"},
+			wantRules:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel := Plan(all, rules, tt.search, now)
+			if len(sel.Selected) != len(tt.wantSelected) {
+				t.Fatalf("expected selected %v, got %v", tt.wantSelected, sel.Selected)
+			}
+			for i := range sel.Selected {
+				if sel.Selected[i] != tt.wantSelected[i] {
+					t.Errorf("expected selected %v, got %v", tt.wantSelected, sel.Selected)
+				}
+			}
+			if len(sel.MatchedRules) != len(tt.wantRules) {
+				t.Fatalf("expected rules %v, got %v", tt.wantRules, sel.MatchedRules)
+			}
+			if len(sel.Skipped)+len(sel.Selected) != len(all) {
+				t.Errorf("expected every provider to be selected or skipped, got %d+%d", len(sel.Selected), len(sel.Skipped))
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package serpapi
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -26,15 +25,29 @@ const (
 	defaultTimeout  = 10 * time.Second
 )
 
-var ErrNoFlights = errors.New("no flight offers found")
+var ErrNoFlights = providers.ErrNoFlights
+
+// travelClasses maps our cabin names to Google Flights travel_class values.
+var travelClasses = map[string]string{
+	models.CabinEconomy:        "1",
+	models.CabinPremiumEconomy: "2",
+	models.CabinBusiness:       "3",
+	models.CabinFirst:          "4",
+}
 
 type SerpAPIClient struct {
-	apiKey  string
+	apiKey  func() string
 	baseURL string
 	client  *http.Client
 }
 
 func New(apiKey, baseURL string, httpClient *http.Client) providers.Provider {
+	return NewRotating(func() string { return apiKey }, baseURL, httpClient)
+}
+
+// NewRotating returns a client that asks apiKey for the key on every
+// request, so a rotated key is picked up without rebuilding it.
+func NewRotating(apiKey func() string, baseURL string, httpClient *http.Client) providers.Provider {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: defaultTimeout}
 	}
@@ -45,6 +58,19 @@ func New(apiKey, baseURL string, httpClient *http.Client) providers.Provider {
 	}
 }
 
+// Name returns the provider's display name.
+func (c *SerpAPIClient) Name() string {
+	return providerName
+}
+
+// Capabilities reports the searches Google Flights via SerpAPI can serve.
+func (c *SerpAPIClient) Capabilities() providers.Capabilities {
+	return providers.Capabilities{
+		RoundTrip:    true,
+		CabinClasses: []string{models.CabinEconomy, models.CabinPremiumEconomy, models.CabinBusiness, models.CabinFirst},
+	}
+}
+
 func (c *SerpAPIClient) GetFlights(ctx context.Context, search models.FlightSearch) ([]models.FlightOffer, error) {
 	respData, err := c.doSearch(ctx, search)
 	if err != nil {
@@ -64,13 +90,24 @@ func (c *SerpAPIClient) doSearch(ctx context.Context, search models.FlightSearch
 
 	qp := u.Query()
 	qp.Set("engine", engine)
-	qp.Set("currency", defaultCurrency)
+	currency := defaultCurrency
+	if search.Currency != "" {
+		currency = search.Currency
+	}
+	qp.Set("currency", currency)
 	qp.Set("hl", defaultLocale)
-	qp.Set("api_key", c.apiKey)
+	qp.Set("api_key", c.apiKey())
 	qp.Set("departure_id", search.Origin)
 	qp.Set("arrival_id", search.Destination)
 	qp.Set("outbound_date", search.DepartureDate.Format(dateLayout))
-	qp.Set("return_date", search.DepartureDate.AddDate(0, 0, 1).Format(dateLayout))
+	returnDate := search.DepartureDate.AddDate(0, 0, 1)
+	if search.IsRoundTrip() {
+		returnDate = search.ReturnDate
+	}
+	qp.Set("return_date", returnDate.Format(dateLayout))
+	if class, ok := travelClasses[search.Cabin]; ok {
+		qp.Set("travel_class", class)
+	}
 	u.RawQuery = qp.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
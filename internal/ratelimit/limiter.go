@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	windowKeyPrefix = "ratelimit:"
+	quotaKeyPrefix  = "quota:"
+	quotaKeyTTL     = 48 * time.Hour
+)
+
+// slidingWindowScript drops requests older than the window, then records
+// this one if fewer than the limit remain. It returns whether the request
+// was allowed, the count in the window and when the oldest one expires.
+var slidingWindowScript = redis.NewScript(`
+local now, window, limit = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if limit > 0 and count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local reset = 0
+if oldest[2] then reset = tonumber(oldest[2]) + window - now end
+return {allowed, count, reset}
+`)
+
+// Result is the state of an account's rate limit window.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the oldest request leaves the window, which is also how
+	// long a refused caller must wait.
+	Reset time.Duration
+}
+
+// Quota is an account's search count for the current UTC day.
+type Quota struct {
+	Allowed  bool
+	Limit    int
+	Used     int
+	ResetsAt time.Time
+}
+
+// Remaining returns how many searches are left today, or -1 if unlimited.
+func (q Quota) Remaining() int {
+	if q.Limit == 0 {
+		return -1
+	}
+	return max(q.Limit-q.Used, 0)
+}
+
+// Limiter enforces sliding-window rate limits and daily quotas in Redis, so
+// every replica counts against the same totals.
+type Limiter struct {
+	client redis.UniversalClient
+	now    func() time.Time
+}
+
+func NewLimiter(client redis.UniversalClient) *Limiter {
+	return &Limiter{client: client, now: time.Now}
+}
+
+// Allow records a request for account if its tier's window has room.
+func (l *Limiter) Allow(ctx context.Context, account string, tier Tier) (Result, error) {
+	if tier.Rate == 0 {
+		return Result{Allowed: true}, nil
+	}
+	member := strconv.FormatInt(l.now().UnixNano(), 36) + "-" + strconv.FormatUint(rand.Uint64(), 36)
+	return l.window(ctx, account, tier, tier.Rate, member)
+}
+
+// Peek returns the state of account's window without recording a request.
+func (l *Limiter) Peek(ctx context.Context, account string, tier Tier) (Result, error) {
+	if tier.Rate == 0 {
+		return Result{Allowed: true}, nil
+	}
+	res, err := l.window(ctx, account, tier, 0, "")
+	res.Allowed = res.Remaining > 0
+	return res, err
+}
+
+func (l *Limiter) window(ctx context.Context, account string, tier Tier, limit int, member string) (Result, error) {
+	vals, err := slidingWindowScript.Run(ctx, l.client, []string{windowKeyPrefix + account},
+		l.now().UnixMilli(), tier.Window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit %s: %w", account, err)
+	}
+	count := int(vals[1])
+	return Result{
+		Allowed:   vals[0] == 1,
+		Limit:     tier.Rate,
+		Remaining: max(tier.Rate-count, 0),
+		Reset:     time.Duration(vals[2]) * time.Millisecond,
+	}, nil
+}
+
+// UseQuota counts a search against account's daily quota.
+func (l *Limiter) UseQuota(ctx context.Context, account string, tier Tier) (Quota, error) {
+	key, resets := l.quotaKey(account)
+	var incr *redis.IntCmd
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, quotaKeyTTL)
+		return nil
+	})
+	if err != nil {
+		return Quota{}, fmt.Errorf("daily quota %s: %w", account, err)
+	}
+	used := int(incr.Val())
+	return Quota{Allowed: tier.DailyQuota == 0 || used <= tier.DailyQuota, Limit: tier.DailyQuota, Used: used, ResetsAt: resets}, nil
+}
+
+// Quota returns account's daily quota without counting a search.
+func (l *Limiter) Quota(ctx context.Context, account string, tier Tier) (Quota, error) {
+	key, resets := l.quotaKey(account)
+	used, err := l.client.Get(ctx, key).Int()
+	if err != nil && err != redis.Nil {
+		return Quota{}, fmt.Errorf("daily quota %s: %w", account, err)
+	}
+	return Quota{Allowed: tier.DailyQuota == 0 || used < tier.DailyQuota, Limit: tier.DailyQuota, Used: used, ResetsAt: resets}, nil
+}
+
+// quotaKey returns today's counter key and when it stops counting.
+func (l *Limiter) quotaKey(account string) (string, time.Time) {
+	now := l.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return quotaKeyPrefix + account + ":" + day.Format(time.DateOnly), day.AddDate(0, 0, 1)
+}
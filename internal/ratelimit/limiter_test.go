@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLimiter(t *testing.T) (*Limiter, *time.Time) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	l := NewLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	now := time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiterSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	l, now := newTestLimiter(t)
+	tier := Tier{Name: "user", Rate: 2, Window: time.Minute}
+
+	for i := range 2 {
+		res, err := l.Allow(ctx, "alice", tier)
+		if err != nil || !res.Allowed || res.Remaining != 1-i {
+			t.Fatalf("request %d: got %+v, %v", i, res, err)
+		}
+		*now = now.Add(10 * time.Second)
+	}
+	res, _ := l.Allow(ctx, "alice", tier)
+	if res.Allowed || res.Remaining != 0 || res.Reset != 40*time.Second {
+		t.Errorf("expected refusal until the first request leaves the window, got %+v", res)
+	}
+	if other, _ := l.Allow(ctx, "bob", tier); !other.Allowed {
+		t.Error("accounts should not share a window")
+	}
+
+	peek, _ := l.Peek(ctx, "alice", tier)
+	if peek.Allowed || peek.Remaining != 0 {
+		t.Errorf("peek: got %+v", peek)
+	}
+
+	*now = now.Add(41 * time.Second)
+	if res, _ := l.Allow(ctx, "alice", tier); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected a slot once the oldest request expired, got %+v", res)
+	}
+	if res, _ := l.Allow(ctx, "alice", Tier{Window: time.Minute}); !res.Allowed {
+		t.Error("a zero rate should be unlimited")
+	}
+}
+
+func TestLimiterDailyQuota(t *testing.T) {
+	ctx := context.Background()
+	l, now := newTestLimiter(t)
+	tier := Tier{Name: "user", DailyQuota: 2}
+
+	for range 2 {
+		if q, err := l.UseQuota(ctx, "alice", tier); err != nil || !q.Allowed {
+			t.Fatalf("got %+v, %v", q, err)
+		}
+	}
+	q, _ := l.UseQuota(ctx, "alice", tier)
+	if q.Allowed || q.Remaining() != 0 {
+		t.Errorf("expected the quota to be spent, got %+v", q)
+	}
+	if want := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC); !q.ResetsAt.Equal(want) {
+		t.Errorf("expected reset at %v, got %v", want, q.ResetsAt)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	q, _ = l.Quota(ctx, "alice", tier)
+	if !q.Allowed || q.Used != 0 || q.Remaining() != 2 {
+		t.Errorf("expected a fresh quota the next day, got %+v", q)
+	}
+	if q, _ := l.Quota(ctx, "alice", Tier{}); q.Remaining() != -1 {
+		t.Errorf("expected unlimited, got %d", q.Remaining())
+	}
+}
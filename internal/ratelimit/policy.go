@@ -0,0 +1,39 @@
+package ratelimit
+
+import "github.com/fehepe/flight-price-service/internal/auth"
+
+// tenantAccountPrefix marks the quota counters shared by a tenant's accounts.
+const tenantAccountPrefix = "tenant:"
+
+// TenantLimits are a tenant's own tiers and the daily search quota all its
+// accounts share. A zero DailyQuota means unlimited.
+type TenantLimits struct {
+	Tiers      Tiers
+	DailyQuota int
+}
+
+// Policy decides the limits of every caller: the global tiers, or those of
+// the caller's tenant when it has its own.
+type Policy struct {
+	Tiers   Tiers
+	Tenants map[string]TenantLimits
+}
+
+// Tier returns the caller's tier.
+func (p Policy) Tier(claims *auth.Claims) Tier {
+	if limits, ok := p.Tenants[claims.Tenant]; ok && limits.Tiers != nil {
+		return limits.Tiers.For(claims)
+	}
+	return p.Tiers.For(claims)
+}
+
+// TenantQuota returns the account and tier that count the searches of the
+// caller's whole tenant. It reports false when the caller has no tenant or
+// the tenant has no shared quota.
+func (p Policy) TenantQuota(claims *auth.Claims) (string, Tier, bool) {
+	limits, ok := p.Tenants[claims.Tenant]
+	if !ok || claims.Tenant == "" || limits.DailyQuota == 0 {
+		return "", Tier{}, false
+	}
+	return tenantAccountPrefix + claims.Tenant, Tier{Name: claims.Tenant, DailyQuota: limits.DailyQuota}, true
+}
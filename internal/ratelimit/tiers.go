@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+)
+
+// Tier names besides the roles.
+const (
+	// TierAPIKey applies to API keys, which carry no roles.
+	TierAPIKey = "apikey"
+	// TierDefault applies to callers none of whose roles has a tier.
+	TierDefault = "default"
+)
+
+// Tier is the allowance of one class of caller. A zero Rate or DailyQuota
+// means unlimited.
+type Tier struct {
+	Name       string
+	Rate       int
+	Window     time.Duration
+	DailyQuota int
+}
+
+// Tiers maps tier names to allowances.
+type Tiers map[string]Tier
+
+// ParseTiers builds tiers from comma-separated name=n lists of requests per
+// window and searches per day, such as "admin=600,user=60".
+func ParseTiers(rates, quotas string, window time.Duration) (Tiers, error) {
+	parsedRates, err := parseCounts(rates)
+	if err != nil {
+		return nil, fmt.Errorf("rate limits: %w", err)
+	}
+	parsedQuotas, err := parseCounts(quotas)
+	if err != nil {
+		return nil, fmt.Errorf("daily quotas: %w", err)
+	}
+	return Tiers{}.Override(parsedRates, parsedQuotas, window), nil
+}
+
+// Override returns a copy of t with the given requests per window and
+// searches per day replaced, keyed by tier name. Tiers not in t are added
+// with window.
+func (t Tiers) Override(rates, quotas map[string]int, window time.Duration) Tiers {
+	out := make(Tiers, len(t))
+	for name, tier := range t {
+		out[name] = tier
+	}
+	tier := func(name string) Tier {
+		tier, ok := out[name]
+		if !ok {
+			tier = Tier{Name: name, Window: window}
+		}
+		return tier
+	}
+	for name, n := range rates {
+		entry := tier(name)
+		entry.Rate = n
+		out[name] = entry
+	}
+	for name, n := range quotas {
+		entry := tier(name)
+		entry.DailyQuota = n
+		out[name] = entry
+	}
+	return out
+}
+
+func parseCounts(raw string) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid entry %q, want name=n with n >= 0", pair)
+		}
+		counts[strings.TrimSpace(name)] = n
+	}
+	return counts, nil
+}
+
+// For returns the tier of the caller: TierAPIKey for API keys, otherwise
+// the most generous tier among its roles, falling back to TierDefault. A
+// caller with no tier at all is unlimited.
+func (t Tiers) For(claims *auth.Claims) Tier {
+	if claims.IsAPIKey() && len(claims.Roles) == 0 {
+		return t.named(TierAPIKey)
+	}
+	var best Tier
+	found := false
+	for _, role := range claims.Roles {
+		tier, ok := t[role]
+		if ok && (!found || moreGenerous(tier, best)) {
+			best, found = tier, true
+		}
+	}
+	if !found {
+		return t.named(TierDefault)
+	}
+	return best
+}
+
+func (t Tiers) named(name string) Tier {
+	if tier, ok := t[name]; ok {
+		return tier
+	}
+	return Tier{Name: name}
+}
+
+// moreGenerous compares rates, where 0 means unlimited.
+func moreGenerous(a, b Tier) bool {
+	if a.Rate == 0 || b.Rate == 0 {
+		return a.Rate == 0 && b.Rate != 0
+	}
+	return a.Rate > b.Rate
+}
+
+// Account returns the key limits are counted under. API keys count under
+// their own subject; other subjects are qualified by issuer so an external
+// subject cannot share a count with a local user or a key.
+func Account(claims *auth.Claims) string {
+	if claims.IsAPIKey() || claims.Issuer == "" {
+		return claims.Subject
+	}
+	return claims.Issuer + "|" + claims.Subject
+}
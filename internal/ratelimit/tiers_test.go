@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestTiersFor(t *testing.T) {
+	tiers, err := ParseTiers("admin=0,operator=300,user=60,apikey=120", "user=1000,apikey=5000", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(sub string, roles ...string) *auth.Claims {
+		return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: sub}, Roles: roles}
+	}
+	apiKey := claims("apikey:abc")
+	apiKey.Method = auth.AuthAPIKey
+
+	tests := []struct {
+		name      string
+		claims    *auth.Claims
+		want      string
+		wantRate  int
+		wantQuota int
+	}{
+		{"user", claims("alice", auth.RoleUser), auth.RoleUser, 60, 1000},
+		{"most generous role", claims("bob", auth.RoleUser, auth.RoleOperator), auth.RoleOperator, 300, 0},
+		{"unlimited role wins", claims("root", auth.RoleOperator, auth.RoleAdmin), auth.RoleAdmin, 0, 0},
+		{"api key", apiKey, TierAPIKey, 120, 5000},
+		{"subject shaped like a key", claims("apikey:abc"), TierDefault, 0, 0},
+		{"unknown role", claims("carol", "auditor"), TierDefault, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tiers.For(tt.claims)
+			if got.Name != tt.want || got.Rate != tt.wantRate || got.DailyQuota != tt.wantQuota {
+				t.Errorf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestParseTiersRejectsBadEntries(t *testing.T) {
+	for _, raw := range []string{"user", "user=x", "user=-1"} {
+		if _, err := ParseTiers(raw, "", time.Minute); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestAccount(t *testing.T) {
+	local := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "alice"}}
+	external := &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "alice", Issuer: "https://sso.example.com"}}
+	if Account(local) == Account(external) {
+		t.Error("external subjects should be qualified by issuer")
+	}
+}
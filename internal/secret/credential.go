@@ -1,9 +1,8 @@
 package secret
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
 )
 
 type Creds struct {
@@ -13,14 +12,22 @@ type Creds struct {
 	PriceLineAPIKey  string `json:"PRICE_LINE_API_KEY"`
 }
 
+// CredsFrom picks the provider keys out of a set of secrets, which use the
+// names of the credentials.json fields.
+func CredsFrom(values map[string]string) Creds {
+	return Creds{
+		AmadeusAPIKey:    values["AMADEUS_API_KEY"],
+		AmadeusAPISecret: values["AMADEUS_API_SECRET"],
+		SerAPIKey:        values["SER_API_KEY"],
+		PriceLineAPIKey:  values["PRICE_LINE_API_KEY"],
+	}
+}
+
 func LoadCreds(path string) (*Creds, error) {
-	data, err := os.ReadFile(path)
+	values, err := FileSource{Path: path}.Load(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("read creds: %w", err)
 	}
-	var c Creds
-	if err := json.Unmarshal(data, &c); err != nil {
-		return nil, fmt.Errorf("unmarshal creds: %w", err)
-	}
+	c := CredsFrom(values)
 	return &c, nil
 }
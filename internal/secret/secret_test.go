@@ -0,0 +1,154 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// vaultStub serves one KV v2 secret at /v1/secret/data/flight-service to
+// requests carrying the token "root".
+type vaultStub struct {
+	mu   sync.Mutex
+	data map[string]any
+}
+
+func (v *vaultStub) set(data map[string]any) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.data = data
+}
+
+func (v *vaultStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	if r.URL.Path != "/v1/secret/data/flight-service" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{
+		"data": map[string]any{"data": v.data, "metadata": map[string]any{"version": 1}},
+	})
+}
+
+func TestVaultSourceAndReload(t *testing.T) {
+	stub := &vaultStub{data: map[string]any{"SER_API_KEY": "serp-1", "AMADEUS_API_KEY": "ama", "PORT": 8080}}
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	source, err := ParseSources("vault:secret/flight-service", VaultConfig{Addr: srv.URL, Token: "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Creds(); got.SerAPIKey != "serp-1" || got.AmadeusAPIKey != "ama" {
+		t.Errorf("unexpected creds %+v", got)
+	}
+	if got := store.Get("PORT"); got != "8080" {
+		t.Errorf("expected non-string values as JSON, got %q", got)
+	}
+
+	var notified []string
+	store.OnChange(func(changed []string) {
+		notified = append(notified, changed...)
+		if got := store.Get("SER_API_KEY"); got != "serp-2" {
+			t.Errorf("expected the new values to be visible to callbacks, got %q", got)
+		}
+	})
+	stub.set(map[string]any{"SER_API_KEY": "serp-2", "AMADEUS_API_KEY": "ama"})
+	changed, err := store.Reload(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(changed)
+	if !slices.Equal(changed, []string{"PORT", "SER_API_KEY"}) {
+		t.Errorf("expected PORT and SER_API_KEY to change, got %v", changed)
+	}
+	if slices.Sort(notified); !slices.Equal(notified, changed) {
+		t.Errorf("expected callbacks to receive %v, got %v", changed, notified)
+	}
+	if _, err := store.Reload(context.Background()); err != nil || len(notified) != 2 {
+		t.Errorf("expected an unchanged reload not to notify, got %v after %v", notified, err)
+	}
+	if got := store.Get("SER_API_KEY"); got != "serp-2" {
+		t.Errorf("expected the rotated key, got %q", got)
+	}
+
+	srv.Close()
+	if _, err := store.Reload(context.Background()); err == nil {
+		t.Error("expected a reload from an unreachable vault to fail")
+	}
+	if got := store.Get("SER_API_KEY"); got != "serp-2" {
+		t.Errorf("expected a failed reload to keep the previous values, got %q", got)
+	}
+}
+
+func TestVaultSource_Denied(t *testing.T) {
+	srv := httptest.NewServer(&vaultStub{})
+	t.Cleanup(srv.Close)
+
+	source, _ := NewVaultSource(VaultConfig{Addr: srv.URL, Token: "wrong"}, "secret", "flight-service")
+	if _, err := source.Load(context.Background()); err == nil {
+		t.Error("expected a refused token to fail the load")
+	}
+	if _, err := NewVaultSource(VaultConfig{Addr: srv.URL}, "secret", "flight-service"); err == nil {
+		t.Error("expected a missing token to be refused")
+	}
+}
+
+func TestFileDirAndLayeredSources(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "credentials.json")
+	os.WriteFile(file, []byte(`{"AMADEUS_API_KEY":"from-file","SER_API_KEY":"serp"}`), 0o600)
+
+	mounted := filepath.Join(dir, "secrets")
+	os.MkdirAll(filepath.Join(mounted, "..data"), 0o700)
+	os.WriteFile(filepath.Join(mounted, "..data", "AMADEUS_API_KEY"), []byte("from-dir\n"), 0o600)
+	os.Symlink(filepath.Join("..data", "AMADEUS_API_KEY"), filepath.Join(mounted, "AMADEUS_API_KEY"))
+
+	t.Setenv("TEST_SECRETS_PRICE_LINE_API_KEY", "from-env")
+
+	source, err := ParseSources("file:"+file+", dir:"+mounted+", env:TEST_SECRETS_", VaultConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := source.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Creds{AmadeusAPIKey: "from-dir", SerAPIKey: "serp", PriceLineAPIKey: "from-env"}
+	if got := CredsFrom(values); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if len(values) != 3 {
+		t.Errorf("expected hidden entries and unprefixed variables to be skipped, got %v", values)
+	}
+
+	os.Remove(file)
+	if _, err := source.Load(context.Background()); err == nil {
+		t.Error("expected a missing layer to fail the load")
+	}
+}
+
+func TestParseSources_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "consul:x", "file:", "dir:", "vault:secret", "vault:secret/app"} {
+		if _, err := ParseSources(spec, VaultConfig{}); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
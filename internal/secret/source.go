@@ -0,0 +1,152 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Source supplies a set of named secrets. Load is called again on every
+// reload, so a source must return the current values, not cached ones.
+type Source interface {
+	Load(ctx context.Context) (map[string]string, error)
+}
+
+// EnvSource reads secrets from environment variables. With a Prefix only
+// the variables carrying it are read, under their name without it.
+type EnvSource struct {
+	Prefix string
+}
+
+func (s EnvSource) Load(ctx context.Context) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if name, ok := strings.CutPrefix(name, s.Prefix); ok && name != "" {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// FileSource reads a JSON object of string values, such as credentials.json.
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Load(ctx context.Context) (map[string]string, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %w", s.Path, err)
+	}
+	return values, nil
+}
+
+// DirSource reads one secret per file, named after the file, as Docker and
+// Kubernetes mount them. Hidden entries, such as the ..data link of a
+// Kubernetes volume, and directories are skipped; surrounding whitespace is
+// trimmed from the values.
+type DirSource struct {
+	Path string
+}
+
+func (s DirSource) Load(ctx context.Context) (map[string]string, error) {
+	entries, err := os.ReadDir(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(s.Path, entry.Name())
+		// Stat follows the symlinks Kubernetes mounts secrets through.
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values[entry.Name()] = strings.TrimSpace(string(data))
+	}
+	return values, nil
+}
+
+// Layered merges several sources; a secret in a later source replaces the
+// same secret in an earlier one. Any source failing fails the load, so a
+// reload never drops values a source could not be read for.
+type Layered []Source
+
+func (l Layered) Load(ctx context.Context) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, s := range l {
+		layer, err := s.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range layer {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// ParseSources builds the sources listed in spec, a comma separated list of
+// env, env:<prefix>, file:<path>, dir:<path> and vault:<mount>/<path>, in
+// increasing precedence. Vault sources use the server and token in vault.
+func ParseSources(spec string, vault VaultConfig) (Source, error) {
+	var layers Layered
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, arg, _ := strings.Cut(entry, ":")
+		switch kind {
+		case "env":
+			layers = append(layers, EnvSource{Prefix: arg})
+		case "file":
+			if arg == "" {
+				return nil, fmt.Errorf("secret source %q needs a path", entry)
+			}
+			layers = append(layers, FileSource{Path: arg})
+		case "dir":
+			if arg == "" {
+				return nil, fmt.Errorf("secret source %q needs a path", entry)
+			}
+			layers = append(layers, DirSource{Path: arg})
+		case "vault":
+			mount, path, ok := strings.Cut(arg, "/")
+			if !ok || mount == "" || path == "" {
+				return nil, fmt.Errorf("secret source %q: expected vault:<mount>/<path>", entry)
+			}
+			source, err := NewVaultSource(vault, mount, path)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, source)
+		default:
+			return nil, fmt.Errorf("unknown secret source %q", entry)
+		}
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("no secret sources in %q", spec)
+	}
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+	return layers, nil
+}
@@ -0,0 +1,116 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Store keeps the latest values of a Source. Readers always see a complete
+// set: a reload that fails keeps the previous values.
+type Store struct {
+	source Source
+
+	mu     sync.RWMutex
+	values map[string]string
+
+	watchersMu sync.Mutex
+	watchers   []func(changed []string)
+}
+
+// NewStore loads source once; it fails when that first load does.
+func NewStore(ctx context.Context, source Source) (*Store, error) {
+	s := &Store{source: source}
+	if _, err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// StaticStore holds fixed values, for tests and placeholder credentials.
+func StaticStore(values map[string]string) *Store {
+	return &Store{values: maps.Clone(values)}
+}
+
+// Get returns the current value of a secret, or "" when it is not set.
+func (s *Store) Get(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[name]
+}
+
+// Creds returns the current provider keys.
+func (s *Store) Creds() Creds {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return CredsFrom(s.values)
+}
+
+// Reload reads the source again and reports the names of the secrets that
+// changed, were added or were removed.
+func (s *Store) Reload(ctx context.Context) ([]string, error) {
+	if s.source == nil {
+		return nil, nil
+	}
+	values, err := s.source.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load secrets: %w", err)
+	}
+
+	s.mu.Lock()
+	var changed []string
+	for name, value := range values {
+		if old, ok := s.values[name]; !ok || old != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range s.values {
+		if _, ok := values[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	s.values = values
+	s.mu.Unlock()
+
+	if len(changed) > 0 {
+		s.watchersMu.Lock()
+		watchers := slices.Clone(s.watchers)
+		s.watchersMu.Unlock()
+		for _, fn := range watchers {
+			fn(changed)
+		}
+	}
+	return changed, nil
+}
+
+// OnChange registers fn to be called with the changed names after each
+// reload that changes a secret. New values are visible to Get by then.
+func (s *Store) OnChange(fn func(changed []string)) {
+	s.watchersMu.Lock()
+	defer s.watchersMu.Unlock()
+	s.watchers = append(s.watchers, fn)
+}
+
+// Watch reloads the store every interval until ctx is done. Failures are
+// logged and the previous values stay in use. Secret values are never logged.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Reload(ctx)
+			if err != nil {
+				log.Printf("secrets reload failed, keeping previous values: %v", err)
+			} else if len(changed) > 0 {
+				log.Printf("secrets reloaded; %d changed", len(changed))
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// VaultConfig locates a HashiCorp Vault compatible server.
+type VaultConfig struct {
+	Addr  string
+	Token string
+	// Namespace is sent as X-Vault-Namespace when set (Vault Enterprise).
+	Namespace string
+	// Client defaults to one with a 10 second timeout.
+	Client *http.Client
+}
+
+// VaultSource reads the secret at one path of a KV version 2 engine. Every
+// key of the secret becomes a secret of the source.
+type VaultSource struct {
+	cfg VaultConfig
+	url string
+}
+
+// NewVaultSource reads the secret at path in the KV v2 engine mounted at mount.
+func NewVaultSource(cfg VaultConfig, mount, path string) (*VaultSource, error) {
+	if cfg.Addr == "" || cfg.Token == "" {
+		return nil, errors.New("vault secret source needs VAULT_ADDR and VAULT_TOKEN")
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	u, err := url.JoinPath(cfg.Addr, "v1", strings.Trim(mount, "/"), "data", strings.Trim(path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("vault address: %w", err)
+	}
+	return &VaultSource{cfg: cfg, url: u}, nil
+}
+
+func (s *VaultSource) Load(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", s.cfg.Token)
+	if s.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.cfg.Namespace)
+	}
+	res, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		// Vault's error bodies never hold secret values.
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("vault %s [%d]: %s", s.url, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var payload struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("vault decode: %w", err)
+	}
+	values := make(map[string]string, len(payload.Data.Data))
+	for name, v := range payload.Data.Data {
+		switch v := v.(type) {
+		case string:
+			values[name] = v
+		case nil:
+		default:
+			data, _ := json.Marshal(v)
+			values[name] = string(data)
+		}
+	}
+	return values, nil
+}
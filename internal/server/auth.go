@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/audit"
+	"github.com/fehepe/flight-price-service/internal/auth"
+	"github.com/fehepe/flight-price-service/internal/cache"
+	"github.com/fehepe/flight-price-service/internal/config"
+	"github.com/fehepe/flight-price-service/internal/ratelimit"
+	"github.com/fehepe/flight-price-service/internal/secret"
+	"github.com/fehepe/flight-price-service/internal/tenant"
+)
+
+// AuthStores holds the stores behind authentication and per-account limits.
+type AuthStores struct {
+	Users    auth.UserStore
+	Sessions auth.SessionStore
+	APIKeys  auth.APIKeyStore
+	Limiter  *ratelimit.Limiter
+	Throttle *auth.LoginThrottle
+	// Secrets supplies JWT_SECRET.
+	Secrets *secret.Store
+}
+
+// MustLoadAuthStores opens the user store, the API key store named by
+// AUTH_API_KEY_STORE and the Redis session store, rate limiter and login
+// throttle.
+func MustLoadAuthStores(secrets *secret.Store) AuthStores {
+	client, err := cache.NewRedisClient(cache.RedisConfigFromEnv())
+	if err != nil {
+		log.Fatalf("invalid redis configuration: %v", err)
+	}
+	keys, err := auth.OpenAPIKeyStore(context.Background(), config.Get("AUTH_API_KEY_STORE", "file:api-keys.json"))
+	if err != nil {
+		log.Fatalf("cannot open api key store: %v", err)
+	}
+	return AuthStores{
+		Users:    MustLoadUserStore(),
+		Sessions: auth.NewRedisSessionStore(client),
+		APIKeys:  keys,
+		Limiter:  ratelimit.NewLimiter(client),
+		Secrets:  secrets,
+		Throttle: auth.NewLoginThrottle(client,
+			auth.WithThrottleThresholds(
+				config.GetEnvInt("AUTH_THROTTLE_IP_FAILURES", 20),
+				config.GetEnvInt("AUTH_THROTTLE_USER_FAILURES", 5),
+			),
+			auth.WithThrottleLockout(
+				time.Duration(max(config.GetEnvInt("AUTH_THROTTLE_BASE_SECONDS", 1), 1))*time.Second,
+				time.Duration(max(config.GetEnvInt("AUTH_THROTTLE_MAX_MINUTES", 15), 1))*time.Minute,
+				time.Duration(max(config.GetEnvInt("AUTH_THROTTLE_WINDOW_MINUTES", 60), 1))*time.Minute,
+			),
+		),
+	}
+}
+
+// mustAuditLogger writes audit events to the sinks in AUDIT_SINKS, a comma
+// separated list of stdout, file:<path> and webhook:<url>. The chain is
+// keyed with the AUDIT_HMAC_KEY secret as read at startup.
+func mustAuditLogger(secrets *secret.Store) *audit.Logger {
+	sink, err := audit.ParseSinks(config.Get("AUDIT_SINKS", "stdout"))
+	if err != nil {
+		log.Fatalf("invalid AUDIT_SINKS: %v", err)
+	}
+	var opts []audit.LoggerOption
+	if key := secrets.Get("AUDIT_HMAC_KEY"); key != "" {
+		opts = append(opts, audit.WithHMACKey([]byte(key)))
+	} else {
+		log.Printf("AUDIT_HMAC_KEY is not set; the audit chain is unkeyed and only detects accidental edits")
+	}
+	logger, err := audit.NewLogger(sink, opts...)
+	if err != nil {
+		log.Fatalf("cannot open audit log: %v", err)
+	}
+	return logger
+}
+
+// MustLoadUserStore opens the store named by AUTH_USER_STORE (a JSON file by
+// default). When the store is empty, AUTH_USERNAME and AUTH_PASSWORD seed
+// the first account as an admin; when no user is an admin, AUTH_USERNAME
+// becomes one.
+func MustLoadUserStore() auth.UserStore {
+	ctx := context.Background()
+	dsn := config.Get("AUTH_USER_STORE", "file:users.json")
+	store, err := auth.OpenUserStore(ctx, dsn)
+	if err != nil {
+		log.Fatalf("cannot open user store: %v", err)
+	}
+	created, err := auth.Bootstrap(ctx, store, mustPasswordHasher(), config.Get("AUTH_USERNAME", ""), config.Get("AUTH_PASSWORD", ""))
+	if err != nil {
+		log.Fatalf("cannot bootstrap user store: %v", err)
+	}
+	if created {
+		log.Printf("created initial user %s in empty user store", config.Get("AUTH_USERNAME", ""))
+	}
+	promoted, err := auth.EnsureAdmin(ctx, store, config.Get("AUTH_USERNAME", ""))
+	if err != nil {
+		log.Fatalf("cannot check for an admin user: %v", err)
+	}
+	if promoted {
+		log.Printf("granted admin role to %s since no user held it", config.Get("AUTH_USERNAME", ""))
+	}
+	return store
+}
+
+// mustPasswordHasher returns the hasher for AUTH_PASSWORD_HASH, argon2id by default.
+func mustPasswordHasher() auth.PasswordHasher {
+	hasher, err := auth.NewPasswordHasher(config.Get("AUTH_PASSWORD_HASH", auth.AlgorithmArgon2id))
+	if err != nil {
+		log.Fatalf("invalid AUTH_PASSWORD_HASH: %v", err)
+	}
+	return hasher
+}
+
+func authenticatorOptions() []auth.AuthenticatorOption {
+	return []auth.AuthenticatorOption{
+		auth.WithLockout(
+			config.GetEnvInt("AUTH_MAX_FAILED_LOGINS", 5),
+			time.Duration(config.GetEnvInt("AUTH_LOCKOUT_MINUTES", 15))*time.Minute,
+		),
+	}
+}
+
+// mustLoadKeySet builds the JWT keys. Tokens are signed with the private key
+// in JWT_SIGNING_KEY_FILE, or with JWT_SECRET (HS256) from secrets when it
+// is unset. The keys in JWT_VERIFY_KEY_FILES, entries of the form
+// [kid=]path, are accepted for verification only, as is JWT_SECRET next to
+// a signing key file. When a reload changes JWT_SECRET, the new secret takes
+// its place and the previous one keeps verifying for the access token TTL.
+func mustLoadKeySet(secrets *secret.Store) *auth.KeySet {
+	var signing auth.Key
+	var verify []auth.Key
+	hmacSecret := secrets.Get("JWT_SECRET")
+	if path := config.Get("JWT_SIGNING_KEY_FILE", ""); path != "" {
+		signing = mustLoadKeyFile(config.Get("JWT_SIGNING_KEY_ID", ""), path)
+		if hmacSecret != "" {
+			verify = append(verify, auth.NewHMACKey([]byte(hmacSecret)))
+		}
+	} else if hmacSecret != "" {
+		signing = auth.NewHMACKey([]byte(hmacSecret))
+	} else {
+		log.Fatalf("JWT_SIGNING_KEY_FILE or JWT_SECRET is required")
+	}
+
+	for _, entry := range strings.Split(config.Get("JWT_VERIFY_KEY_FILES", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok {
+			kid, path = "", entry
+		}
+		verify = append(verify, mustLoadKeyFile(kid, path))
+	}
+
+	keys, err := auth.NewKeySet(signing, verify...)
+	if err != nil {
+		log.Fatalf("invalid JWT keys: %v", err)
+	}
+	secrets.OnChange(func(changed []string) {
+		if !slices.Contains(changed, "JWT_SECRET") {
+			return
+		}
+		if err := keys.RotateHMAC([]byte(secrets.Get("JWT_SECRET")), accessTokenTTL()); err != nil {
+			log.Printf("JWT_SECRET not rotated: %v", err)
+			return
+		}
+		log.Printf("JWT_SECRET rotated; the previous secret verifies for %s", accessTokenTTL())
+	})
+	return keys
+}
+
+// accessTokenTTL is the lifetime of access tokens, JWT_EXPIRY_HOURS.
+func accessTokenTTL() time.Duration {
+	return time.Duration(max(config.GetEnvInt("JWT_EXPIRY_HOURS", 1), 1)) * time.Hour
+}
+
+// mustLoadVerifiers accepts tokens signed by keys and, when
+// OIDC_ISSUERS_FILE is set, by the external issuers it lists.
+func mustLoadVerifiers(keys *auth.KeySet) *auth.Verifiers {
+	path := config.Get("OIDC_ISSUERS_FILE", "")
+	if path == "" {
+		return auth.NewVerifiers(keys)
+	}
+	configs, err := auth.LoadOIDCConfigs(path)
+	if err != nil {
+		log.Fatalf("cannot load OIDC issuers: %v", err)
+	}
+	external := make([]*auth.OIDCVerifier, 0, len(configs))
+	for _, cfg := range configs {
+		v, err := auth.NewOIDCVerifier(cfg, nil)
+		if err != nil {
+			log.Fatalf("invalid OIDC issuer: %v", err)
+		}
+		external = append(external, v)
+	}
+	log.Printf("accepting tokens from %d OIDC issuers in %s", len(external), path)
+	return auth.NewVerifiers(keys, external...)
+}
+
+func mustLoadKeyFile(kid, path string) auth.Key {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("cannot read JWT key: %v", err)
+	}
+	key, err := auth.ParseKeyPEM(kid, data)
+	if err != nil {
+		log.Fatalf("invalid JWT key %s: %v", path, err)
+	}
+	return key
+}
+
+// mustSessions builds the token service from the JWT_* settings. Access
+// tokens last JWT_EXPIRY_HOURS and refresh tokens AUTH_REFRESH_TTL_HOURS.
+func mustSessions(keys *auth.KeySet, stores AuthStores) *auth.Sessions {
+	issuer := auth.NewTokenIssuer(
+		keys,
+		config.Get("JWT_ISSUER", "flight-service"),
+		accessTokenTTL(),
+	)
+	refreshTTL := time.Duration(max(config.GetEnvInt("AUTH_REFRESH_TTL_HOURS", 168), 1)) * time.Hour
+	return auth.NewSessions(issuer, stores.Sessions, stores.Users, refreshTTL)
+}
+
+// mustRatePolicy reads the per-tier limits on searches: RATE_LIMIT_TIERS
+// requests per RATE_LIMIT_WINDOW_SECONDS and SEARCH_DAILY_QUOTAS per day.
+// Tenants may override them and cap their accounts' searches together.
+func mustRatePolicy(tenants *tenant.Registry) ratelimit.Policy {
+	window := time.Duration(max(config.GetEnvInt("RATE_LIMIT_WINDOW_SECONDS", 60), 1)) * time.Second
+	tiers, err := ratelimit.ParseTiers(
+		config.Get("RATE_LIMIT_TIERS", "admin=600,operator=300,user=60,apikey=120,default=60"),
+		config.Get("SEARCH_DAILY_QUOTAS", "user=1000,apikey=5000,default=1000"),
+		window,
+	)
+	if err != nil {
+		log.Fatalf("invalid rate limit tiers: %v", err)
+	}
+	policy := ratelimit.Policy{Tiers: tiers}
+	if tenants == nil {
+		return policy
+	}
+	policy.Tenants = make(map[string]ratelimit.TenantLimits)
+	for _, t := range tenants.List() {
+		limits := ratelimit.TenantLimits{DailyQuota: t.DailyQuota}
+		if len(t.RateLimits) > 0 || len(t.DailyQuotas) > 0 {
+			limits.Tiers = tiers.Override(t.RateLimits, t.DailyQuotas, window)
+		}
+		policy.Tenants[t.ID] = limits
+	}
+	return policy
+}
@@ -1,14 +1,23 @@
 package server
 
 import (
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/fehepe/flight-price-service/internal/config"
 	"github.com/fehepe/flight-price-service/internal/providers"
 	"github.com/fehepe/flight-price-service/internal/providers/amadeus"
+	"github.com/fehepe/flight-price-service/internal/providers/mock"
 	"github.com/fehepe/flight-price-service/internal/providers/priceline"
+	"github.com/fehepe/flight-price-service/internal/providers/recorder"
 	"github.com/fehepe/flight-price-service/internal/providers/serpapi"
 	"github.com/fehepe/flight-price-service/internal/secret"
+	"github.com/fehepe/flight-price-service/internal/tenant"
 )
 
 func mustEnv(key string) string {
@@ -19,24 +28,83 @@ func mustEnv(key string) string {
 	return value
 }
 
-func MustLoadProviders() []providers.Provider {
-	// Amadeus configuration
-	amadeusBaseURL := mustEnv("AMADEUS_API_BASE_URL")
-	maxResults := mustEnv("MAX_FLIGHT_RESULTS_PER_CLIENT")
+// ProviderSets holds the providers of callers without a tenant and builds
+// those of each tenant on demand.
+type ProviderSets struct {
+	Shared  []providers.Provider
+	Tenants *tenant.Registry
+	factory *providerFactory
+}
+
+// ForTenant builds the providers of a tenant from its own credentials,
+// limited to the providers it enables.
+func (s ProviderSets) ForTenant(id string) ([]providers.Provider, tenant.Tenant, error) {
+	if s.Tenants == nil {
+		return nil, tenant.Tenant{}, fmt.Errorf("%w %s", tenant.ErrNotFound, id)
+	}
+	t, err := s.Tenants.Get(id)
+	if err != nil {
+		return nil, tenant.Tenant{}, err
+	}
+	list, err := s.factory.forTenant(t)
+	return list, t, err
+}
 
-	// SerpAPI configuration
-	serApiBaseURL := mustEnv("SER_API_BASE_URL")
+// MustLoadProviderSets loads the shared providers, whose keys come from
+// secrets, and the tenants listed in TENANTS_FILE, if set.
+func MustLoadProviderSets(secrets *secret.Store) ProviderSets {
+	factory := mustProviderFactory()
+	sets := ProviderSets{Shared: factory.mustShared(secrets), factory: factory}
+	if path := os.Getenv("TENANTS_FILE"); path != "" {
+		registry, err := tenant.Load(path)
+		if err != nil {
+			log.Fatalf("cannot load tenants: %v", err)
+		}
+		log.Printf("loaded %d tenants from %s", len(registry.List()), path)
+		sets.Tenants = registry
+	}
+	return sets
+}
 
-	// PriceLine configuration
-	priceLineBaseURL := mustEnv("PRICE_LINE_API_BASE_URL")
+// providerFactory builds provider clients for a set of credentials. In
+// chaos mode it hands out the chaos providers instead.
+type providerFactory struct {
+	amadeusBaseURL   string
+	serpAPIBaseURL   string
+	priceLineBaseURL string
+	maxResults       string
+	mode             recorder.Mode
+	httpClient       *http.Client
+	chaos            []providers.Provider
+}
 
-	// Load encrypted credentials.json
-	creds, err := secret.LoadCreds("credentials.json")
-	if err != nil {
-		log.Fatalf("cannot load credentials: %v", err)
+func mustProviderFactory() *providerFactory {
+	if os.Getenv("PROVIDER_MODE") == "chaos" {
+		return &providerFactory{chaos: mustLoadChaosProviders()}
+	}
+	// Record or replay provider traffic when requested
+	mode := recorder.Mode(os.Getenv("PROVIDER_HTTP_MODE"))
+	return &providerFactory{
+		amadeusBaseURL:   mustEnv("AMADEUS_API_BASE_URL"),
+		serpAPIBaseURL:   mustEnv("SER_API_BASE_URL"),
+		priceLineBaseURL: mustEnv("PRICE_LINE_API_BASE_URL"),
+		maxResults:       mustEnv("MAX_FLIGHT_RESULTS_PER_CLIENT"),
+		mode:             mode,
+		httpClient:       mustProviderHTTPClient(mode),
+	}
+}
+
+// mustShared builds the providers of callers without a tenant, which need
+// credentials for every provider.
+func (f *providerFactory) mustShared(secrets *secret.Store) []providers.Provider {
+	if f.chaos != nil {
+		return f.chaos
 	}
+	// Replay mode never reaches upstream, so placeholders suffice
+	secrets = f.orPlaceholders("shared providers", secrets)
 
 	// Validate loaded credentials
+	creds := secrets.Creds()
 	if creds.AmadeusAPIKey == "" || creds.AmadeusAPISecret == "" {
 		log.Fatal("amadeus credentials (API key & secret) must not be empty")
 	}
@@ -46,10 +114,165 @@ func MustLoadProviders() []providers.Provider {
 	if creds.PriceLineAPIKey == "" {
 		log.Fatal("PriceLine credential (API key) must not be empty")
 	}
+	return f.build(secrets, func(string) bool { return true })
+}
+
+func (f *providerFactory) forTenant(t tenant.Tenant) ([]providers.Provider, error) {
+	var list []providers.Provider
+	if f.chaos != nil {
+		for _, p := range f.chaos {
+			if t.Enables(providers.NameOf(p)) {
+				list = append(list, p)
+			}
+		}
+	} else {
+		secrets, err := openSecrets(t.SecretSources())
+		if err != nil && f.mode != recorder.ModeReplay {
+			return nil, fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		secrets = f.orPlaceholders("tenant "+t.ID, secrets)
+		list = f.build(secrets, t.Enables)
+		if len(list) > 0 {
+			watchSecrets(secrets)
+		}
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("tenant %s has no enabled provider with credentials", t.ID)
+	}
+	return list, nil
+}
+
+// orPlaceholders returns secrets, or placeholder keys in replay mode when
+// secrets lack some provider's keys.
+func (f *providerFactory) orPlaceholders(owner string, secrets *secret.Store) *secret.Store {
+	if f.mode != recorder.ModeReplay {
+		return secrets
+	}
+	if secrets != nil {
+		creds := secrets.Creds()
+		if creds.AmadeusAPIKey != "" && creds.AmadeusAPISecret != "" && creds.SerAPIKey != "" && creds.PriceLineAPIKey != "" {
+			return secrets
+		}
+	}
+	log.Printf("replay mode: using placeholder credentials for %s", owner)
+	return secret.StaticStore(map[string]string{
+		"AMADEUS_API_KEY":    "replay",
+		"AMADEUS_API_SECRET": "replay",
+		"SER_API_KEY":        "replay",
+		"PRICE_LINE_API_KEY": "replay",
+	})
+}
+
+// build returns a client for each provider secrets currently has keys for
+// and enabled accepts. The clients read their keys from secrets on every
+// use, so keys rotated at the source take effect on the next reload.
+func (f *providerFactory) build(secrets *secret.Store, enabled func(name string) bool) []providers.Provider {
+	var list []providers.Provider
+	creds := secrets.Creds()
+	if creds.AmadeusAPIKey != "" && creds.AmadeusAPISecret != "" {
+		keys := func() (string, string) {
+			c := secrets.Creds()
+			return c.AmadeusAPIKey, c.AmadeusAPISecret
+		}
+		list = append(list, amadeus.NewRotating(keys, f.amadeusBaseURL, f.maxResults, f.httpClient))
+	}
+	if creds.SerAPIKey != "" {
+		list = append(list, serpapi.NewRotating(func() string { return secrets.Creds().SerAPIKey }, f.serpAPIBaseURL, f.httpClient))
+	}
+	if creds.PriceLineAPIKey != "" {
+		list = append(list, priceline.NewRotating(func() string { return secrets.Creds().PriceLineAPIKey }, f.priceLineBaseURL, f.httpClient))
+	}
+	enabledList := list[:0]
+	for _, p := range list {
+		if enabled(providers.NameOf(p)) {
+			enabledList = append(enabledList, p)
+		}
+	}
+	return enabledList
+}
+
+// mustLoadChaosProviders builds fault-injecting mock providers from
+// CHAOS_SCENARIO_FILE, or a single provider from CHAOS_* variables.
+func mustLoadChaosProviders() []providers.Provider {
+	var scenarios []mock.Scenario
+	if path := os.Getenv("CHAOS_SCENARIO_FILE"); path != "" {
+		loaded, err := mock.LoadScenarios(path)
+		if err != nil {
+			log.Fatalf("cannot load chaos scenarios: %v", err)
+		}
+		scenarios = loaded
+	} else {
+		s, err := mock.ScenarioFromEnv()
+		if err != nil {
+			log.Fatalf("invalid chaos scenario: %v", err)
+		}
+		scenarios = []mock.Scenario{s}
+	}
+
+	seed := int64(config.GetEnvInt("CHAOS_SEED", int(time.Now().UnixNano())))
+	list := make([]providers.Provider, len(scenarios))
+	for i, s := range scenarios {
+		list[i] = mock.NewChaos(s, seed+int64(i))
+	}
+	log.Printf("chaos mode: running %d mock providers (seed %d)", len(list), seed)
+	return list
+}
+
+// mustProviderHTTPClient returns the HTTP client shared by the provider clients,
+// or nil to let each client use its default when recording is off.
+func mustProviderHTTPClient(mode recorder.Mode) *http.Client {
+	if mode == recorder.ModeOff {
+		return nil
+	}
+	dir := config.Get("PROVIDER_FIXTURES_DIR", "testdata/fixtures")
+	transport, err := recorder.New(mode, dir, http.DefaultTransport)
+	if err != nil {
+		log.Fatalf("invalid PROVIDER_HTTP_MODE: %v", err)
+	}
+	log.Printf("provider HTTP mode %q using fixtures in %s", mode, dir)
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// MustLoadRules loads provider selection rules from PROVIDER_RULES_FILE.
+// No rules are applied when the variable is unset.
+func MustLoadRules() providers.RuleSet {
+	path := os.Getenv("PROVIDER_RULES_FILE")
+	if path == "" {
+		return nil
+	}
+	rules, err := providers.LoadRules(path)
+	if err != nil {
+		log.Fatalf("cannot load provider rules: %v", err)
+	}
+	log.Printf("loaded %d provider rules from %s", len(rules), path)
+	return rules
+}
+
+// mustLoadProviderTTLs parses CACHE_PROVIDER_TTLS, a comma-separated list of
+// Provider=seconds pairs such as "Amadeus=30,SerpAPI=120".
+func mustLoadProviderTTLs() map[string]time.Duration {
+	ttls := make(map[string]time.Duration)
+	for name, n := range mustParseProviderInts("CACHE_PROVIDER_TTLS", "seconds") {
+		ttls[name] = time.Duration(n) * time.Second
+	}
+	return ttls
+}
 
-	return []providers.Provider{
-		amadeus.New(creds.AmadeusAPIKey, creds.AmadeusAPISecret, amadeusBaseURL, maxResults, nil),
-		serpapi.New(creds.SerAPIKey, serApiBaseURL, nil),
-		priceline.New(creds.PriceLineAPIKey, priceLineBaseURL, nil),
+// mustParseProviderInts parses a comma-separated list of Provider=n pairs
+// with positive n from the environment variable key.
+func mustParseProviderInts(key, unit string) map[string]int {
+	values := make(map[string]int)
+	raw := os.Getenv(key)
+	if raw == "" {
+		return values
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n <= 0 {
+			log.Fatalf("invalid %s entry %q; expected Provider=%s", key, pair, unit)
+		}
+		values[name] = n
 	}
+	return values
 }
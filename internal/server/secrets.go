@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/config"
+	"github.com/fehepe/flight-price-service/internal/secret"
+)
+
+// MustLoadSecrets opens the sources in SECRET_SOURCES, which supply the
+// shared provider keys and JWT_SECRET. By default they are credentials.json,
+// when present, overridden by the environment.
+func MustLoadSecrets() *secret.Store {
+	spec := config.Get("SECRET_SOURCES", "")
+	if spec == "" {
+		spec = "env"
+		if _, err := os.Stat("credentials.json"); err == nil {
+			spec = "file:credentials.json,env"
+		}
+	}
+	store, err := openSecrets(spec)
+	if err != nil {
+		log.Fatalf("cannot load secrets from %s: %v", spec, err)
+	}
+	watchSecrets(store)
+	return store
+}
+
+// openSecrets loads the sources in spec once.
+func openSecrets(spec string) (*secret.Store, error) {
+	source, err := secret.ParseSources(spec, secret.VaultConfig{
+		Addr:      config.Get("VAULT_ADDR", ""),
+		Token:     config.Get("VAULT_TOKEN", ""),
+		Namespace: config.Get("VAULT_NAMESPACE", ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secret.NewStore(context.Background(), source)
+}
+
+// watchSecrets reloads store every SECRETS_RELOAD_SECONDS; 0 disables reloading.
+func watchSecrets(store *secret.Store) {
+	if interval := time.Duration(config.GetEnvInt("SECRETS_RELOAD_SECONDS", 300)) * time.Second; interval > 0 {
+		go store.Watch(context.Background(), interval)
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
+	"github.com/fehepe/flight-price-service/internal/auth"
 	"github.com/fehepe/flight-price-service/internal/cache"
 	"github.com/fehepe/flight-price-service/internal/config"
 	"github.com/fehepe/flight-price-service/internal/handlers"
@@ -18,32 +20,115 @@ import (
 )
 
 // NewRouter sets up routes, applying logging globally and auth on protected endpoints.
-func NewRouter(providerList []providers.Provider, flightCache cache.FlightCacher) *mux.Router {
+func NewRouter(sets ProviderSets, flightCache cache.FlightCacher, rules providers.RuleSet, stores AuthStores) *mux.Router {
 	r := mux.NewRouter()
 	r.Use(middleware.Logging)
 	r.StrictSlash(true)
 
-	fh := handlers.NewFlightHandler(providerList, flightCache)
+	flightOptions := []handlers.FlightHandlerOption{
+		handlers.WithRules(rules),
+		handlers.WithLease(
+			time.Duration(config.GetEnvInt("CACHE_LEASE_TTL", 20))*time.Second,
+			time.Duration(config.GetEnvInt("CACHE_LEASE_WAIT", 5))*time.Second,
+		),
+		handlers.WithProviderTTLs(mustLoadProviderTTLs()),
+		handlers.WithNegativeTTLs(
+			time.Duration(config.GetEnvInt("NEGATIVE_CACHE_NO_OFFERS_TTL", 60))*time.Second,
+			time.Duration(config.GetEnvInt("NEGATIVE_CACHE_FAILED_TTL", 15))*time.Second,
+		),
+	}
+	fh := handlers.NewFlightHandler(sets.Shared, flightCache, flightOptions...)
+	tenantFlights := handlers.NewTenantFlights(fh, func(id string) (*handlers.FlightHandler, error) {
+		list, t, err := sets.ForTenant(id)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("tenant %s searching %d providers", id, len(list))
+		opts := append(slices.Clip(flightOptions), handlers.WithCacheNamespace(cache.Namespace(t.CacheNamespace)))
+		return handlers.NewFlightHandler(list, flightCache, opts...), nil
+	})
 
+	keys := mustLoadKeySet(stores.Secrets)
 	r.HandleFunc("/health", handlers.HealthCheck).Methods(http.MethodGet)
-	r.HandleFunc("/auth/token", handlers.GenerateToken).Methods(http.MethodPost)
+	r.HandleFunc("/.well-known/jwks.json", handlers.JWKS(keys)).Methods(http.MethodGet)
+	hasher := mustPasswordHasher()
+	sessions := mustSessions(keys, stores)
+	verifiers := mustLoadVerifiers(keys)
+	apiKeys := auth.NewAPIKeys(stores.APIKeys, auth.WithOwners(stores.Users))
+	requireAuth := middleware.Auth(verifiers, middleware.WithRevocation(sessions), middleware.WithAPIKeys(apiKeys))
+	auditLog := mustAuditLogger(stores.Secrets)
+	trustProxy := config.GetEnvBool("TRUST_PROXY_HEADERS", false)
+	ah := handlers.NewAuthHandler(auth.NewAuthenticator(stores.Users, hasher, authenticatorOptions()...), sessions,
+		handlers.WithLoginThrottle(stores.Throttle),
+		handlers.WithAudit(auditLog),
+		handlers.WithTrustedForwarding(trustProxy),
+	)
+	r.HandleFunc("/auth/token", ah.GenerateToken).Methods(http.MethodPost)
+	r.HandleFunc("/auth/refresh", ah.Refresh).Methods(http.MethodPost)
+	r.Handle("/auth/logout", requireAuth(http.HandlerFunc(ah.Logout))).Methods(http.MethodPost)
 
+	policy := mustRatePolicy(sets.Tenants)
 	flights := r.PathPrefix("/flights").Subrouter()
-	flights.Use(middleware.Auth)
-	flights.HandleFunc("/search", fh.GetFlights).Methods(http.MethodGet)
+	flights.Use(requireAuth, middleware.RateLimit(stores.Limiter, policy))
+	flights.Handle("/search", scoped(auth.ScopeFlightsSearch, tenantFlights.GetFlights)).Methods(http.MethodGet)
+
+	usage := handlers.NewUsageHandler(stores.Limiter, policy)
+	r.Handle("/account/usage", requireAuth(http.HandlerFunc(usage.Usage))).Methods(http.MethodGet)
+
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(requireAuth, middleware.Audit(auditLog, trustProxy))
+	admin.Handle("/providers/route", scoped(auth.ScopeProvidersRead, tenantFlights.RouteProviders)).Methods(http.MethodGet)
+
+	// The cache is shared by every tenant's namespace, so only callers
+	// outside any tenant may inspect or change it.
+	caches := admin.PathPrefix("/cache").Subrouter()
+	caches.Use(middleware.RequireNoTenant())
+	ch := handlers.NewCacheHandler(flightCache)
+	caches.Handle("/stats", scoped(auth.ScopeCacheRead, ch.Stats)).Methods(http.MethodGet)
+	caches.Handle("/keys", scoped(auth.ScopeCacheRead, ch.Keys)).Methods(http.MethodGet)
+	caches.Handle("/keys", scoped(auth.ScopeCacheWrite, ch.Purge)).Methods(http.MethodDelete)
+	caches.Handle("/entry", scoped(auth.ScopeCacheRead, ch.Entry)).Methods(http.MethodGet)
+	caches.Handle("", scoped(auth.ScopeCacheWrite, ch.Flush)).Methods(http.MethodDelete)
+
+	// Managing users needs the admin role itself, so no API key can do it.
+	users := admin.PathPrefix("/users").Subrouter()
+	users.Use(middleware.RequireRole(auth.RoleAdmin), middleware.RequireScope(auth.ScopeUsersManage))
+	uh := handlers.NewUserHandler(stores.Users, hasher)
+	users.HandleFunc("", uh.List).Methods(http.MethodGet)
+	users.HandleFunc("", uh.Create).Methods(http.MethodPost)
+	users.HandleFunc("/{username}", uh.Get).Methods(http.MethodGet)
+	users.HandleFunc("/{username}", uh.Update).Methods(http.MethodPatch)
+	users.HandleFunc("/{username}", uh.Delete).Methods(http.MethodDelete)
+
+	kh := handlers.NewAPIKeyHandler(apiKeys)
+	admin.Handle("/api-keys", scoped(auth.ScopeAPIKeysManage, kh.List)).Methods(http.MethodGet)
+	admin.Handle("/api-keys", scoped(auth.ScopeAPIKeysManage, kh.Create)).Methods(http.MethodPost)
+	admin.Handle("/api-keys/{id}", scoped(auth.ScopeAPIKeysManage, kh.Revoke)).Methods(http.MethodDelete)
+
+	warmer := handlers.NewWarmer(fh, warmerOptions()...)
+	caches.Handle("/warm", scoped(auth.ScopeCacheWrite, warmer.Trigger)).Methods(http.MethodPost)
+	if interval := warmInterval(); interval > 0 {
+		go warmer.Start(context.Background(), interval)
+	}
 
 	return r
 }
 
+// scoped wraps h so that it requires scope.
+func scoped(scope string, h http.HandlerFunc) http.Handler {
+	return middleware.RequireScope(scope)(h)
+}
+
 func Run(addr string) error {
 	cache := cache.NewFlightCacheFromConfig()
-	return RunWithProvider(addr, MustLoadProviders(), cache)
+	secrets := MustLoadSecrets()
+	return RunWithProvider(addr, MustLoadProviderSets(secrets), cache, MustLoadRules(), MustLoadAuthStores(secrets))
 }
 
-func RunWithProvider(addr string, providers []providers.Provider, flightCache cache.FlightCacher) error {
+func RunWithProvider(addr string, sets ProviderSets, flightCache cache.FlightCacher, rules providers.RuleSet, stores AuthStores) error {
 	srv := &http.Server{
 		Addr:           addr,
-		Handler:        NewRouter(providers, flightCache),
+		Handler:        NewRouter(sets, flightCache, rules, stores),
 		ReadTimeout:    time.Duration(config.GetEnvInt("READ_TIMEOUT", 5)) * time.Second,
 		WriteTimeout:   time.Duration(config.GetEnvInt("WRITE_TIMEOUT", 10)) * time.Second,
 		IdleTimeout:    time.Duration(config.GetEnvInt("IDLE_TIMEOUT", 120)) * time.Second,
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fehepe/flight-price-service/internal/config"
+	"github.com/fehepe/flight-price-service/internal/handlers"
+)
+
+// warmerOptions reads the cache warmer settings from the environment.
+func warmerOptions() []handlers.WarmerOption {
+	opts := []handlers.WarmerOption{
+		handlers.WithWarmTop(config.GetEnvInt("CACHE_WARM_TOP", 200)),
+		handlers.WithWarmBudgets(config.GetEnvInt("CACHE_WARM_BUDGET", 100), mustParseProviderInts("CACHE_WARM_PROVIDER_BUDGETS", "calls")),
+		handlers.WithWarmSeeds(mustLoadWarmSeeds(), config.GetEnvInt("CACHE_WARM_SEED_DAYS", 7)),
+	}
+	if interval := warmInterval(); interval > 0 {
+		opts = append(opts, handlers.WithWarmAhead(interval))
+	}
+	return opts
+}
+
+// warmInterval is how often the warmer runs in the background; 0 disables it.
+func warmInterval() time.Duration {
+	return time.Duration(config.GetEnvInt("CACHE_WARM_INTERVAL", 0)) * time.Second
+}
+
+// mustLoadWarmSeeds parses CACHE_WARM_SEEDS, a comma-separated list of
+// routes such as "JFK-LAX,MIA-MAD".
+func mustLoadWarmSeeds() []handlers.WarmRoute {
+	raw := os.Getenv("CACHE_WARM_SEEDS")
+	if raw == "" {
+		return nil
+	}
+	var routes []handlers.WarmRoute
+	for _, pair := range strings.Split(raw, ",") {
+		origin, destination, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(pair)), "-")
+		if !ok || len(origin) != 3 || len(destination) != 3 {
+			log.Fatalf("invalid CACHE_WARM_SEEDS entry %q; expected ORIGIN-DESTINATION", pair)
+		}
+		routes = append(routes, handlers.WarmRoute{Origin: origin, Destination: destination})
+	}
+	return routes
+}
@@ -27,7 +27,7 @@ func FetchAllFlightOffers(ctx context.Context, providerList []providers.Provider
 			mu.Lock()
 			defer mu.Unlock()
 			if err != nil {
-				errs = append(errs, fmt.Errorf("%T: %w", pr, err))
+				errs = append(errs, fmt.Errorf("%s: %w", providers.NameOf(pr), err))
 				return
 			}
 			all = append(all, offers...)
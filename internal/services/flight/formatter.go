@@ -8,7 +8,7 @@ import (
 	"github.com/fehepe/flight-price-service/pkg/models"
 )
 
-func BuildSearchResponse(offers []models.FlightOffer) models.SearchResponse {
+func BuildSearchResponse(offers []models.FlightOffer, skipped []models.SkippedProvider) models.SearchResponse {
 	var (
 		cheapest    = offers[0]
 		fastest     = offers[0]
@@ -33,6 +33,7 @@ func BuildSearchResponse(offers []models.FlightOffer) models.SearchResponse {
 		Cheapest:  cheapest,
 		Fastest:   fastest,
 		Providers: providerMap,
+		Skipped:   skipped,
 	}
 }
 
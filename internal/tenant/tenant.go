@@ -0,0 +1,140 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound means a token names a tenant that is not configured.
+var ErrNotFound = errors.New("unknown tenant")
+
+// validID restricts tenant IDs and cache namespaces to characters that are
+// safe inside cache keys and glob patterns.
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Tenant is a partner team searching with its own provider contracts.
+type Tenant struct {
+	ID string `json:"id"`
+	// CredentialsFile holds the tenant's provider keys in the layout of
+	// credentials.json. Providers without keys are not used.
+	CredentialsFile string `json:"credentials_file"`
+	// Credentials names secret sources instead, in the syntax of
+	// SECRET_SOURCES, such as "vault:secret/tenants/acme".
+	Credentials string `json:"credentials"`
+	// Providers lists the enabled providers by name; empty enables every
+	// provider the credentials cover.
+	Providers []string `json:"providers"`
+	// CacheNamespace keeps the tenant's cached offers apart; it defaults to ID.
+	CacheNamespace string `json:"cache_namespace"`
+	// RateLimits and DailyQuotas override the global tiers for the tenant's
+	// accounts, keyed by tier name.
+	RateLimits  map[string]int `json:"rate_limits"`
+	DailyQuotas map[string]int `json:"daily_quotas"`
+	// DailyQuota caps the searches of all the tenant's accounts together per
+	// UTC day. 0 means unlimited.
+	DailyQuota int `json:"daily_quota"`
+}
+
+// Enables reports whether the tenant may use the named provider.
+func (t Tenant) Enables(provider string) bool {
+	if len(t.Providers) == 0 {
+		return true
+	}
+	for _, p := range t.Providers {
+		if strings.EqualFold(p, provider) {
+			return true
+		}
+	}
+	return false
+}
+
+// SecretSources returns where the tenant's provider keys are read from.
+func (t Tenant) SecretSources() string {
+	if t.Credentials != "" {
+		return t.Credentials
+	}
+	return "file:" + t.CredentialsFile
+}
+
+// Registry holds the configured tenants.
+type Registry struct {
+	tenants map[string]Tenant
+}
+
+// NewRegistry validates tenants and applies defaults.
+func NewRegistry(tenants []Tenant) (*Registry, error) {
+	r := &Registry{tenants: make(map[string]Tenant, len(tenants))}
+	namespaces := make(map[string]string, len(tenants))
+	for _, t := range tenants {
+		if !validID.MatchString(t.ID) {
+			return nil, fmt.Errorf("invalid tenant id %q: use lowercase letters, digits, - and _", t.ID)
+		}
+		if _, dup := r.tenants[t.ID]; dup {
+			return nil, fmt.Errorf("duplicate tenant %s", t.ID)
+		}
+		if (t.CredentialsFile == "") == (t.Credentials == "") {
+			return nil, fmt.Errorf("tenant %s: set one of credentials_file and credentials", t.ID)
+		}
+		if t.CacheNamespace == "" {
+			t.CacheNamespace = t.ID
+		}
+		if !validID.MatchString(t.CacheNamespace) {
+			return nil, fmt.Errorf("tenant %s: invalid cache namespace %q", t.ID, t.CacheNamespace)
+		}
+		if other, dup := namespaces[t.CacheNamespace]; dup {
+			return nil, fmt.Errorf("tenants %s and %s share cache namespace %s", other, t.ID, t.CacheNamespace)
+		}
+		if t.DailyQuota < 0 {
+			return nil, fmt.Errorf("tenant %s: daily_quota must not be negative", t.ID)
+		}
+		for name, n := range t.RateLimits {
+			if n < 0 {
+				return nil, fmt.Errorf("tenant %s: rate limit of %s must not be negative", t.ID, name)
+			}
+		}
+		for name, n := range t.DailyQuotas {
+			if n < 0 {
+				return nil, fmt.Errorf("tenant %s: daily quota of %s must not be negative", t.ID, name)
+			}
+		}
+		namespaces[t.CacheNamespace] = t.ID
+		r.tenants[t.ID] = t
+	}
+	return r, nil
+}
+
+// Load reads a JSON list of tenants.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read tenants: %w", err)
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("unmarshal tenants: %w", err)
+	}
+	return NewRegistry(tenants)
+}
+
+// Get returns the tenant with the given ID.
+func (r *Registry) Get(id string) (Tenant, error) {
+	if t, ok := r.tenants[id]; ok {
+		return t, nil
+	}
+	return Tenant{}, fmt.Errorf("%w %s", ErrNotFound, id)
+}
+
+// List returns every tenant ordered by ID.
+func (r *Registry) List() []Tenant {
+	list := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
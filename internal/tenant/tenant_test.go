@@ -0,0 +1,63 @@
+package tenant
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	os.WriteFile(path, []byte(`[
+		{"id":"globex","credentials_file":"globex.json","cache_namespace":"gx"},
+		{"id":"acme","credentials":"vault:secret/tenants/acme","providers":["amadeus","SerpAPI"],"daily_quota":500}
+	]`), 0o600)
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, err := r.Get("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acme.CacheNamespace != "acme" || !acme.Enables("Amadeus") || acme.Enables("PriceLine") {
+		t.Errorf("unexpected tenant %+v", acme)
+	}
+	if src := acme.SecretSources(); src != "vault:secret/tenants/acme" {
+		t.Errorf("unexpected secret sources %q", src)
+	}
+	if globex, _ := r.Get("globex"); globex.SecretSources() != "file:globex.json" {
+		t.Errorf("expected credentials_file as a file source, got %q", globex.SecretSources())
+	}
+	if list := r.List(); len(list) != 2 || list[0].ID != "acme" || !list[1].Enables("PriceLine") {
+		t.Errorf("unexpected list %+v", list)
+	}
+	if _, err := r.Get("initech"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNewRegistryRejectsInvalidTenants(t *testing.T) {
+	tests := []struct {
+		name    string
+		tenants []Tenant
+	}{
+		{"bad id", []Tenant{{ID: "Acme Corp", CredentialsFile: "a.json"}}},
+		{"duplicate", []Tenant{{ID: "acme", CredentialsFile: "a.json"}, {ID: "acme", CredentialsFile: "b.json"}}},
+		{"no credentials", []Tenant{{ID: "acme"}}},
+		{"two credentials", []Tenant{{ID: "acme", CredentialsFile: "a.json", Credentials: "env:ACME_"}}},
+		{"bad namespace", []Tenant{{ID: "acme", CredentialsFile: "a.json", CacheNamespace: "flights:*"}}},
+		{"shared namespace", []Tenant{{ID: "acme", CredentialsFile: "a.json"}, {ID: "globex", CredentialsFile: "b.json", CacheNamespace: "acme"}}},
+		{"negative quota", []Tenant{{ID: "acme", CredentialsFile: "a.json", DailyQuota: -1}}},
+		{"negative tier quota", []Tenant{{ID: "acme", CredentialsFile: "a.json", DailyQuotas: map[string]int{"user": -5}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRegistry(tt.tenants); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
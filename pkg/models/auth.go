@@ -6,7 +6,15 @@ type TokenRequest struct {
 	Password string `json:"password"`
 }
 
+// RefreshRequest is the expected JSON payload to refresh a JWT.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 // TokenResponse is returned after successful authentication.
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ExpiresIn is the access token lifetime in seconds.
+	ExpiresIn int64 `json:"expires_in,omitempty"`
 }
@@ -4,3 +4,10 @@ package models
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
+
+// NoProviderResponse is returned when no provider can serve a search. It
+// says why each provider was skipped.
+type NoProviderResponse struct {
+	Error   string            `json:"error"`
+	Skipped []SkippedProvider `json:"skipped"`
+}
@@ -2,9 +2,25 @@ package models
 
 import "time"
 
+// Cabin classes accepted in a FlightSearch.
+const (
+	CabinEconomy        = "economy"
+	CabinPremiumEconomy = "premium_economy"
+	CabinBusiness       = "business"
+	CabinFirst          = "first"
+)
+
 // FlightSearch contains search parameters for retrieving flight offers.
 type FlightSearch struct {
 	Origin        string    `json:"origin"`
 	Destination   string    `json:"destination"`
 	DepartureDate time.Time `json:"departure_date"`
+	ReturnDate    time.Time `json:"return_date,omitempty"`
+	Cabin         string    `json:"cabin,omitempty"`
+	Currency      string    `json:"currency,omitempty"`
+}
+
+// IsRoundTrip reports whether the search includes a return date.
+func (s FlightSearch) IsRoundTrip() bool {
+	return !s.ReturnDate.IsZero()
 }
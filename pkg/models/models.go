@@ -10,7 +10,16 @@ type FlightOffer struct {
 }
 
 type SearchResponse struct {
-	Cheapest  FlightOffer              `json:"cheapest"`
-	Fastest   FlightOffer              `json:"fastest"`
-	Providers map[string][]FlightOffer `json:"providers"`
+	Cheapest    FlightOffer              `json:"cheapest"`
+	Fastest     FlightOffer              `json:"fastest"`
+	Providers   map[string][]FlightOffer `json:"providers"`
+	Skipped     []SkippedProvider        `json:"skipped,omitempty"`
+	Failed      []string                 `json:"failed,omitempty"`
+	CacheStatus string                   `json:"cache_status,omitempty"`
+}
+
+// SkippedProvider records a provider that was not queried for a search and why.
+type SkippedProvider struct {
+	Provider string `json:"provider"`
+	Reason   string `json:"reason"`
 }